@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var maintenanceTpl = newTemplateStore("maintenance.html")
+
+// adminToken guards the /admin/ endpoints, they are disabled while it is empty
+var adminToken *string
+
+// maintenance is switched by -maintenance at startup and /admin/maintenance at runtime
+var maintenance atomic.Bool
+
+// requireAdmin only lets requests carrying "Authorization: Bearer <admin token>" through
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if *adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(*adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// withMaintenance serves the maintenance page on every route except health checks, admin and assets
+func withMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !maintenance.Load() || maintenanceExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// typed before the status goes out, withSecurityHeaders decides on the CSP from it
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Retry-After", "300")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if err := maintenanceTpl.Get().Execute(w, nil); err != nil {
+			log.Println(err)
+		}
+	})
+}
+
+func maintenanceExempt(path string) bool {
+	return path == "/healthz" || path == "/readyz" ||
+		strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/debug/") || strings.HasPrefix(path, "/assets/")
+}
+
+// maintenanceHandler reports the maintenance state, a POST with enabled=true|false switches it
+func maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !parseForm(w, r) {
+			return
+		}
+		enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+			return
+		}
+		maintenance.Store(enabled)
+		log.Printf("maintenance mode set to %t", enabled)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"maintenance": maintenance.Load()})
+}
+
+// verifyKeyHandler checks the configured api key against newsapi, for operators setting up a deployment
+func verifyKeyHandler(w http.ResponseWriter, r *http.Request) {
+	check, err := newsapi.VerifyKey(r.Context())
+	if err != nil {
+		log.Println(err)
+		writeJSONError(w, http.StatusBadGateway, "newsapi could not be reached")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(check)
+}
+
+// debugQuery is what /debug/query answers: the upstream URLs a search would request, key redacted
+type debugQuery struct {
+	URL          string `json:"url"`
+	HeadlinesURL string `json:"headlinesUrl,omitempty"`
+	// Query is the q sent, after the query hooks, keywords and safe search
+	Query string `json:"query"`
+}
+
+// debugQueryHandler shows the newsapi URL a search with the same params would request, without
+// requesting it, so operators can check how filters and boolean queries are translated
+func debugQueryHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := searchParams(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	search, err := newSearch(params)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// the requests of the results page
+	search.withHeadlines = true
+
+	withKey := func(v url.Values) url.Values {
+		v.Set("apiKey", newsapi.key)
+		return v
+	}
+	debug := debugQuery{
+		URL:   redactKey(buildEverythingURL(newsapi.base, withKey(search.everythingParams()))),
+		Query: search.upstreamQuery(),
+	}
+	if search.mergesHeadlines() {
+		debug.HeadlinesURL = redactKey(buildArticlesURL(newsapi.base, endpointTopHeadlines, withKey(search.headlinesParams())))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	// the URLs are read by people, keep their & as they are
+	enc.SetEscapeHTML(false)
+	enc.Encode(debug)
+}
+
+// healthzHandler reports the process is alive, it stays green during maintenance
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+// readyzHandler reports whether we should receive traffic, which we shouldn't during maintenance
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if maintenance.Load() {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestWithMaintenance(t *testing.T) {
+	tests := []struct {
+		name       string
+		on         bool
+		path       string
+		wantStatus int
+	}{
+		{name: "off", on: false, path: "/search", wantStatus: http.StatusOK},
+		{name: "search page", on: true, path: "/search", wantStatus: http.StatusServiceUnavailable},
+		{name: "homepage", on: true, path: "/", wantStatus: http.StatusServiceUnavailable},
+		{name: "health check", on: true, path: "/healthz", wantStatus: http.StatusOK},
+		{name: "readiness", on: true, path: "/readyz", wantStatus: http.StatusOK},
+		{name: "admin", on: true, path: "/admin/maintenance", wantStatus: http.StatusOK},
+		{name: "assets", on: true, path: "/assets/style.css", wantStatus: http.StatusOK},
+		{name: "debug", on: true, path: "/debug/query", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maintenance.Store(tt.on)
+			t.Cleanup(func() { maintenance.Store(false) })
+			handler := withMaintenance(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable {
+				if got := w.Header().Get("Retry-After"); got == "" {
+					t.Error("no Retry-After")
+				}
+				if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+					t.Errorf("Content-Type = %q, want text/html", ct)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		header     string
+		wantStatus int
+	}{
+		{name: "disabled without a token", token: "", header: "Bearer ", wantStatus: http.StatusNotFound},
+		{name: "right token", token: "s3cret", header: "Bearer s3cret", wantStatus: http.StatusOK},
+		{name: "wrong token", token: "s3cret", header: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "no bearer prefix", token: "s3cret", header: "s3cret", wantStatus: http.StatusUnauthorized},
+		{name: "no header", token: "s3cret", header: "", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &adminToken, tt.token)
+			handler := requireAdmin(func(w http.ResponseWriter, r *http.Request) {})
+			r := httptest.NewRequest(http.MethodGet, "/admin/maintenance", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMaintenanceHandlerSwitches(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    string
+		wantStatus int
+		wantOn     bool
+	}{
+		{name: "on", enabled: "true", wantStatus: http.StatusOK, wantOn: true},
+		{name: "off", enabled: "false", wantStatus: http.StatusOK, wantOn: false},
+		{name: "not a bool", enabled: "maybe", wantStatus: http.StatusBadRequest, wantOn: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { maintenance.Store(false) })
+			r := httptest.NewRequest(http.MethodPost, "/admin/maintenance", strings.NewReader(url.Values{"enabled": {tt.enabled}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			maintenanceHandler(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := maintenance.Load(); got != tt.wantOn {
+				t.Errorf("maintenance = %v, want %v", got, tt.wantOn)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus int
+		want       string
+	}{
+		{name: "working key", status: http.StatusOK, wantStatus: http.StatusOK, want: `"valid":true`},
+		{name: "invalid key", status: http.StatusUnauthorized, wantStatus: http.StatusOK, want: `"valid":false`},
+		{name: "newsapi down", wantStatus: http.StatusBadGateway, want: "newsapi could not be reached"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newErrorNewsAPI(t, tt.status, `{}`)
+			if tt.status == 0 {
+				srv.Close()
+			}
+			useNewsAPI(t, srv)
+			w := get(verifyKeyHandler, "/admin/verify-key")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body = %s, want %s", w.Body, tt.want)
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+		})
+	}
+}
+
+func TestDebugQueryHandler(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		merge         bool
+		wantStatus    int
+		wantURL       string
+		wantQuery     string
+		wantHeadlines bool
+	}{
+		{
+			name:       "search",
+			query:      "q=go&pageSize=10",
+			wantStatus: http.StatusOK,
+			wantURL:    "/v2/everything?apiKey=REDACTED&language=en&page=1&pageSize=10&q=go&sortBy=publishedAt",
+			wantQuery:  "go",
+		},
+		{
+			name:       "safe search",
+			query:      "q=go&safeSearch=1",
+			wantStatus: http.StatusOK,
+			wantURL:    "/v2/everything?apiKey=REDACTED&excludeDomains=adult.example&language=en&page=1&pageSize=20&q=go+-nsfw&sortBy=publishedAt",
+			wantQuery:  "go -nsfw",
+		},
+		{
+			name:          "first page merges headlines",
+			query:         "q=go",
+			merge:         true,
+			wantStatus:    http.StatusOK,
+			wantURL:       "/v2/everything?apiKey=REDACTED&language=en&page=1&pageSize=20&q=go&sortBy=publishedAt",
+			wantQuery:     "go",
+			wantHeadlines: true,
+		},
+		{name: "invalid search", query: "q=go&pageSize=500", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeNewsAPI(t, 3)
+			useNewsAPI(t, api.Server)
+			setFlag(t, &mergeHeadlines, tt.merge)
+			setBlocklist(t, blockedWords, []string{"nsfw"})
+			setVar(t, &safeSearchDomains, []string{"adult.example"})
+			w := get(debugQueryHandler, "/debug/query?"+tt.query)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if api.hits.Load() != 0 {
+				t.Error("/debug/query asked newsapi")
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if strings.Contains(w.Body.String(), "test-key") {
+				t.Error("response has the api key")
+			}
+			var got debugQuery
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.URL != api.URL+tt.wantURL || got.Query != tt.wantQuery {
+				t.Errorf("debugQuery = %+v, want URL %s and query %q", got, api.URL+tt.wantURL, tt.wantQuery)
+			}
+			if (got.HeadlinesURL != "") != tt.wantHeadlines {
+				t.Errorf("headlinesUrl = %q, want one: %v", got.HeadlinesURL, tt.wantHeadlines)
+			}
+		})
+	}
+}
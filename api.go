@@ -0,0 +1,276 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxExportDepth bounds how many pages a single NDJSON export may pull
+const maxExportDepth = 5
+
+// maxFilenameQuery caps how much of the query goes into a download's filename
+const maxFilenameQuery = 50
+
+// openAPISpec documents the JSON endpoints, keep it in step with the params newSearch reads
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+// writeJSONError responds with {"error": message}
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// writeJSONFetchError is writeFetchError for the JSON endpoints
+func writeJSONFetchError(w http.ResponseWriter, err error) {
+	status, message := fetchErrorResponse(err)
+	writeJSONError(w, status, message)
+}
+
+// downloadFilename turns a query into a safe attachment name such as news-climate-change-p2.json,
+// anything but ASCII letters and digits becomes a dash so no path or header syntax gets through
+func downloadFilename(query string, page int) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(query) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= maxFilenameQuery {
+			break
+		}
+	}
+	name := strings.TrimSuffix(b.String(), "-")
+	if name == "" {
+		return fmt.Sprintf("news-p%d.json", page)
+	}
+	return fmt.Sprintf("news-%s-p%d.json", name, page)
+}
+
+// searchJSONHandler returns one page of results as JSON, as a file download with download=1,
+// without empty fields with compact=1 and with the images embedded with inlineImages=1. With
+// noContent=1 a page without articles is a bodiless 204 instead. Each page links the next one
+// with a cursor, which can be passed instead of the search params.
+func searchJSONHandler(w http.ResponseWriter, r *http.Request) {
+	params, err := searchParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	search, err := newSearch(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	download, err := boolParam(params, "download")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	compact, err := boolParam(params, "compact")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	inline, err := boolParam(params, "inlineImages")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	noContent, err := boolParam(params, "noContent")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if inline && inlineImagesLimiter == nil {
+		http.Error(w, "inlineImages is disabled on this server", http.StatusBadRequest)
+		return
+	}
+	// the /img proxy may fetch any https host without -image-hosts, but one inlineImages request
+	// fetches a whole page of them, so it needs the hosts to be listed
+	if inline && len(imageHosts) == 0 {
+		http.Error(w, "inlineImages needs -image-hosts on this server", http.StatusBadRequest)
+		return
+	}
+	if download {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+downloadFilename(search.DisplayQuery(), search.NextPage)+`"`)
+	}
+	if answerHead(w, r, "application/json") {
+		return
+	}
+	// before the fetch, a refused request shouldn't have used up newsapi quota
+	if inline && !inlineImagesLimiter.Allow(clientIP(r)) {
+		writeJSONError(w, http.StatusTooManyRequests, "Too many inlineImages requests, try again in a minute")
+		return
+	}
+
+	if err := search.fetch(r.Context()); err != nil {
+		if clientGone(r, err) {
+			return
+		}
+		writeJSONFetchError(w, err)
+		return
+	}
+	search.Results.NextCursor = search.nextCursor()
+	if inline {
+		search.Results.Articles = inlineImages(r.Context(), search.Results.Articles)
+	}
+
+	setCacheControl(w, "public", search.Results)
+	if notModified(w, r, search.Results) {
+		return
+	}
+	if noContent && len(search.Results.Articles) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	var body any = search.Results
+	if compact {
+		body = newCompactResults(search.Results)
+	}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Println(err)
+	}
+}
+
+// searchNDJSONHandler streams articles as newline-delimited JSON.
+// depth pulls that many consecutive pages, each one flushed as soon as it arrives.
+// noContent=1 answers 204 when the first page has no articles.
+func searchNDJSONHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	search, err := newSearch(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d, err := singleParam(params, "depth")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	depth := 1
+	if d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 || n > maxExportDepth {
+			http.Error(w, fmt.Sprintf("depth must be between 1 and %d", maxExportDepth), http.StatusBadRequest)
+			return
+		}
+		depth = n
+	}
+	compact, err := boolParam(params, "compact")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	noContent, err := boolParam(params, "noContent")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if answerHead(w, r, "application/x-ndjson") {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	for i := 0; i < depth; i++ {
+		if err := search.fetch(r.Context()); err != nil {
+			if clientGone(r, err) {
+				return
+			}
+			if i == 0 {
+				writeJSONFetchError(w, err)
+				return
+			}
+			// the stream has already started, all we can do is stop it
+			if !errors.Is(err, errPastLastPage) {
+				log.Println(err)
+			}
+			return
+		}
+
+		if i == 0 {
+			if noContent && len(search.Results.Articles) == 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		for _, article := range search.Results.Articles {
+			var line any = article
+			if compact {
+				line = newCompactArticle(article)
+			}
+			if err := enc.Encode(line); err != nil {
+				log.Println(err)
+				return
+			}
+		}
+		flusher.Flush()
+
+		// a short page from newsapi is its last, fewer articles left after filtering are not
+		if search.fetched < search.PageSize {
+			break
+		}
+		search.NextPage++
+	}
+}
+
+// countResponse is what /count returns
+type countResponse struct {
+	Total int `json:"total"`
+}
+
+// countHandler returns just the number of results for a search, fetching a single article to
+// get it so a badge doesn't cost a full page. It takes the same params as /search.json, bar paging.
+func countHandler(w http.ResponseWriter, r *http.Request) {
+	search, err := newSearch(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if answerHead(w, r, "application/json") {
+		return
+	}
+	search.NextPage = 1
+	search.PageSize = 1
+
+	if err := search.fetch(r.Context()); err != nil {
+		if clientGone(r, err) {
+			return
+		}
+		writeJSONFetchError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheControl(w, "public", search.Results)
+	if err := json.NewEncoder(w).Encode(countResponse{Total: search.Results.TotalResults}); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSearchNDJSONHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		total        int
+		displayLimit int
+		query        string
+		wantStatus   int
+		wantLines    int
+		wantHits     int32
+	}{
+		{name: "one page by default", total: 50, query: "q=go", wantStatus: http.StatusOK, wantLines: 20, wantHits: 1},
+		{name: "depth pulls consecutive pages", total: 50, query: "q=go&depth=3", wantStatus: http.StatusOK, wantLines: 50, wantHits: 3},
+		{name: "short page ends the export", total: 25, query: "q=go&depth=5", wantStatus: http.StatusOK, wantLines: 25, wantHits: 2},
+		{name: "display limit doesn't end it", total: 100, displayLimit: 5, query: "q=go&depth=3", wantStatus: http.StatusOK, wantLines: 15, wantHits: 3},
+		{name: "depth too small", total: 50, query: "q=go&depth=0", wantStatus: http.StatusBadRequest},
+		{name: "depth too large", total: 50, query: "q=go&depth=6", wantStatus: http.StatusBadRequest},
+		{name: "depth not a number", total: 50, query: "q=go&depth=all", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &displayLimit, tt.displayLimit)
+			api := newFakeNewsAPI(t, tt.total)
+			useNewsAPI(t, api.Server)
+
+			w := get(searchNDJSONHandler, "/search.ndjson?"+tt.query)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+				t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+			}
+			lines := 0
+			scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+			for scanner.Scan() {
+				var a Articles
+				if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+					t.Fatalf("line %d is not an article: %v", lines+1, err)
+				}
+				lines++
+			}
+			if lines != tt.wantLines {
+				t.Errorf("got %d lines, want %d", lines, tt.wantLines)
+			}
+			if got := api.hits.Load(); got != tt.wantHits {
+				t.Errorf("newsapi got %d requests, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	w := get(openAPIHandler, "/openapi.json")
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var spec struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("spec isn't JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", spec.OpenAPI)
+	}
+	for _, path := range []string{"/search.json", "/search.ndjson", "/facets", "/count", "/meta"} {
+		if _, ok := spec.Paths[path]; !ok {
+			t.Errorf("spec doesn't describe %s", path)
+		}
+	}
+}
+
+func TestJSONEndpointErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "rejected key", status: http.StatusUnauthorized, body: `{"status":"error","code":"apiKeyInvalid","message":"Your API key is invalid."}`, wantStatus: http.StatusServiceUnavailable, wantError: "news service not configured"},
+		{name: "rejected key without a body", status: http.StatusUnauthorized, body: "", wantStatus: http.StatusServiceUnavailable, wantError: "news service not configured"},
+		{name: "newsapi error", status: http.StatusInternalServerError, body: `{"status":"error","code":"unexpectedError","message":"Try again"}`, wantStatus: http.StatusInternalServerError, wantError: "Try again"},
+		{name: "bodiless error", status: http.StatusBadGateway, body: "", wantStatus: http.StatusInternalServerError, wantError: "Unexpected server error"},
+	}
+	handlers := map[string]http.HandlerFunc{
+		"/search.json":   searchJSONHandler,
+		"/search.ndjson": searchNDJSONHandler,
+		"/facets":        facetsHandler,
+		"/count":         countHandler,
+	}
+	for _, tt := range tests {
+		for path, handler := range handlers {
+			t.Run(tt.name+" "+path, func(t *testing.T) {
+				useNewsAPI(t, newErrorNewsAPI(t, tt.status, tt.body))
+				w := get(handler, path+"?q=go")
+				if w.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+				}
+				if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", ct)
+				}
+				var body struct{ Error string }
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error != tt.wantError {
+					t.Errorf("body = %s, want error %q", w.Body, tt.wantError)
+				}
+			})
+		}
+	}
+}
+
+func TestDownloadFilename(t *testing.T) {
+	tests := []struct {
+		query string
+		page  int
+		want  string
+	}{
+		{query: "climate change", page: 2, want: "news-climate-change-p2.json"},
+		{query: `"Big Tech" AND (EU OR US)`, page: 1, want: "news-big-tech-and-eu-or-us-p1.json"},
+		{query: "../../etc/passwd", page: 1, want: "news-etc-passwd-p1.json"},
+		{query: `a"; filename="evil.exe`, page: 1, want: "news-a-filename-evil-exe-p1.json"},
+		{query: "日本", page: 3, want: "news-p3.json"},
+		{query: strings.Repeat("a", 80), page: 1, want: "news-" + strings.Repeat("a", maxFilenameQuery) + "-p1.json"},
+	}
+	for _, tt := range tests {
+		if got := downloadFilename(tt.query, tt.page); got != tt.want {
+			t.Errorf("downloadFilename(%q, %d) = %q, want %q", tt.query, tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestSearchJSONDownload(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		want       string
+	}{
+		{name: "download", target: "/search.json?q=climate+change&page=2&download=1", wantStatus: http.StatusOK, want: `attachment; filename="news-climate-change-p2.json"`},
+		{name: "download=true", target: "/search.json?q=go&download=true", wantStatus: http.StatusOK, want: `attachment; filename="news-go-p1.json"`},
+		{name: "shown inline", target: "/search.json?q=go", wantStatus: http.StatusOK, want: ""},
+		{name: "repeated", target: "/search.json?q=go&download=1&download=1", wantStatus: http.StatusBadRequest, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, newFakeNewsAPI(t, 40).Server)
+			w := get(searchJSONHandler, tt.target)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := w.Header().Get("Content-Disposition"); got != tt.want {
+				t.Errorf("Content-Disposition = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantTotal  int
+	}{
+		{name: "count", target: "/count?q=go", wantStatus: http.StatusOK, wantTotal: 42},
+		{name: "paging ignored", target: "/count?q=go&page=4&pageSize=50", wantStatus: http.StatusOK, wantTotal: 42},
+		{name: "bad params", target: "/count?q=go&q=rust", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sent = r.URL.Query()
+				w.Write([]byte(`{"status":"ok","totalResults":42,"articles":[{"title":"Story 1","url":"https://news.example.com/a/1"}]}`))
+			}))
+			t.Cleanup(srv.Close)
+			useNewsAPI(t, srv)
+
+			w := get(countHandler, tt.target)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got countResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Total != tt.wantTotal {
+				t.Errorf("body = %s, want total %d", w.Body, tt.wantTotal)
+			}
+			if sent.Get("page") != "1" || sent.Get("pageSize") != "1" {
+				t.Errorf("newsapi was asked for page %s of %s, want a single article", sent.Get("page"), sent.Get("pageSize"))
+			}
+		})
+	}
+}
+
+func TestNoContent(t *testing.T) {
+	cursor := url.QueryEscape(encodeCursor(url.Values{"q": {"go"}, "page": {"1"}}))
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		total      int
+		target     string
+		wantStatus int
+	}{
+		{name: "JSON without articles", handler: searchJSONHandler, total: 0, target: "/search.json?q=go&noContent=1", wantStatus: http.StatusNoContent},
+		{name: "JSON without articles by default", handler: searchJSONHandler, total: 0, target: "/search.json?q=go", wantStatus: http.StatusOK},
+		{name: "JSON with articles", handler: searchJSONHandler, total: 3, target: "/search.json?q=go&noContent=1", wantStatus: http.StatusOK},
+		{name: "JSON turned off", handler: searchJSONHandler, total: 0, target: "/search.json?q=go&noContent=0", wantStatus: http.StatusOK},
+		{name: "JSON given twice", handler: searchJSONHandler, total: 0, target: "/search.json?q=go&noContent=1&noContent=0", wantStatus: http.StatusBadRequest},
+		{name: "along with a cursor", handler: searchJSONHandler, total: 0, target: "/search.json?noContent=1&cursor=" + cursor, wantStatus: http.StatusNoContent},
+		{name: "NDJSON without articles", handler: searchNDJSONHandler, total: 0, target: "/search.ndjson?q=go&noContent=1", wantStatus: http.StatusNoContent},
+		{name: "NDJSON with articles", handler: searchNDJSONHandler, total: 3, target: "/search.ndjson?q=go&noContent=1", wantStatus: http.StatusOK},
+		{name: "NDJSON given twice", handler: searchNDJSONHandler, total: 0, target: "/search.ndjson?q=go&noContent=1&noContent=0", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeNewsAPI(t, tt.total)
+			useNewsAPI(t, api.Server)
+			w := get(tt.handler, tt.target)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusNoContent && w.Body.Len() != 0 {
+				t.Errorf("204 has a body: %s", w.Body)
+			}
+			if tt.wantStatus == http.StatusOK && w.Body.Len() == 0 {
+				t.Error("200 has no body")
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// maxArticleBytes caps how much of a page the extractor downloads
+	maxArticleBytes = 2 << 20
+	articleCacheTTL = 15 * time.Minute
+	// articleCacheEntries and articleCacheBytes bound the extracted articles kept in memory
+	articleCacheEntries = 500
+	articleCacheBytes   = 16 << 20
+	// minParagraph is the shortest text that counts as body copy rather than a caption or link
+	minParagraph = 40
+)
+
+var articleTpl = newTemplateStore("article.html")
+
+var articleCache = newBoundedCache(articleCacheEntries, articleCacheBytes)
+
+// articleReader turns on /article and makes it the reader view when no -reader-prefix is set
+var articleReader *bool
+
+// extractedArticle is the readable part of a page
+type extractedArticle struct {
+	URL        string   `json:"url"`
+	Title      string   `json:"title"`
+	Paragraphs []string `json:"paragraphs"`
+}
+
+// skippedElements never hold the main text
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Nav: true, atom.Header: true,
+	atom.Footer: true, atom.Aside: true, atom.Form: true, atom.Iframe: true, atom.Svg: true,
+	atom.Button: true, atom.Figure: true,
+}
+
+// extractArticle finds the main text of a page the way reader modes do: every paragraph of
+// body copy scores a point for its parent element by length, and the paragraphs of the best
+// scoring element are the article. Pages with no such element fall back to every long paragraph.
+func extractArticle(r io.Reader) (*extractedArticle, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	article := &extractedArticle{}
+	scores := map[*html.Node]int{}
+	var paragraphs []*html.Node
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if skippedElements[n.DataAtom] {
+				return
+			}
+			switch n.DataAtom {
+			case atom.Title:
+				if article.Title == "" {
+					article.Title = nodeText(n)
+				}
+			case atom.Meta:
+				if attr(n, "property") == "og:title" && attr(n, "content") != "" {
+					article.Title = attr(n, "content")
+				}
+			case atom.P:
+				if text := nodeText(n); len(text) >= minParagraph && n.Parent != nil {
+					paragraphs = append(paragraphs, n)
+					scores[n.Parent] += 1 + len(text)/100 + strings.Count(text, ",")
+				}
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	var best *html.Node
+	for n, score := range scores {
+		if best == nil || score > scores[best] {
+			best = n
+		}
+	}
+	for _, p := range paragraphs {
+		if best == nil || p.Parent == best || scores[best] < 3 {
+			article.Paragraphs = append(article.Paragraphs, nodeText(p))
+		}
+	}
+	article.Title = cleanText(article.Title)
+	if len(article.Paragraphs) == 0 {
+		return nil, fmt.Errorf("no readable text found")
+	}
+	return article, nil
+}
+
+// nodeText is the whitespace-collapsed text inside n
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		if n.Type == html.ElementNode && skippedElements[n.DataAtom] {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// fetchArticle downloads and extracts src under ctx, reusing a recent extraction when there is one
+func fetchArticle(ctx context.Context, src string) (*extractedArticle, error) {
+	if body, ok := articleCache.Get(src); ok {
+		article := &extractedArticle{}
+		if err := json.Unmarshal(body, article); err == nil {
+			return article, nil
+		}
+	}
+
+	// tied to the visitor's request, the fetch stops when they leave or the deadline passes
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("article %s: status %d", src, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") && !strings.HasPrefix(ct, "application/xhtml") {
+		return nil, fmt.Errorf("article %s: not an html page (%s)", src, ct)
+	}
+
+	article, err := extractArticle(io.LimitReader(resp.Body, maxArticleBytes))
+	if err != nil {
+		return nil, fmt.Errorf("article %s: %w", src, err)
+	}
+	article.URL = src
+
+	if body, err := json.Marshal(article); err == nil {
+		articleCache.Set(src, body, articleCacheTTL)
+	}
+	return article, nil
+}
+
+// articleHandler renders the extracted text of ?url= in a clean reader view
+func articleHandler(w http.ResponseWriter, r *http.Request) {
+	u, err := validateRemoteURL(r.URL.Query().Get("url"), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	article, err := fetchArticle(r.Context(), u.String())
+	if clientGone(r, err) {
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "Could not load a readable version of this article", http.StatusBadGateway)
+		return
+	}
+
+	if err := articleTpl.Get().Execute(w, article); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractArticle(t *testing.T) {
+	long := func(s string) string { return s + strings.Repeat(" and more words, to make it body copy", 2) }
+	tests := []struct {
+		name      string
+		page      string
+		want      []string
+		wantTitle string
+		wantErr   bool
+	}{
+		{
+			name:      "main text wins over the sidebar",
+			page:      "<title>Page</title><article><p>" + long("First paragraph") + "</p><p>" + long("Second paragraph") + "</p><p>" + long("Third paragraph") + "</p></article><div><p>" + long("Sidebar") + "</p></div>",
+			want:      []string{long("First paragraph"), long("Second paragraph"), long("Third paragraph")},
+			wantTitle: "Page",
+		},
+		{
+			name:      "og:title and skipped elements",
+			page:      `<meta property="og:title" content="Story &amp; more"><nav><p>` + long("Menu") + `</p></nav><div><p>` + long("Body") + `</p></div>`,
+			want:      []string{long("Body")},
+			wantTitle: "Story & more",
+		},
+		{
+			name:    "short text only",
+			page:    "<p>Too short</p><p>Also short</p>",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractArticle(strings.NewReader(tt.page))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractArticle error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !slices.Equal(got.Paragraphs, tt.want) {
+				t.Errorf("paragraphs = %q, want %q", got.Paragraphs, tt.want)
+			}
+			if got.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", got.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestFetchArticle(t *testing.T) {
+	cached := "https://news.example.com/cached"
+	articleCache.Set(cached, []byte(`{"url":"https://news.example.com/cached","title":"Cached","paragraphs":["Kept"]}`), time.Minute)
+	t.Cleanup(func() { articleCache.Delete(cached) })
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		src       string
+		wantTitle string
+		wantErr   error
+	}{
+		{name: "cached extraction", ctx: context.Background(), src: cached, wantTitle: "Cached"},
+		{name: "cached even once the visitor left", ctx: canceled, src: cached, wantTitle: "Cached"},
+		{name: "visitor left before the fetch", ctx: canceled, src: "https://news.example.com/new", wantErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fetchArticle(tt.ctx, tt.src)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("fetchArticle error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", got.Title, tt.wantTitle)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+)
+
+// overlayFS serves each file from the first of its layers that has it, so a directory of
+// branding files can stand in for some of the bundled assets and leave the rest alone
+type overlayFS []http.FileSystem
+
+// Open tries the layers in order. Only a missing file falls through to the next layer, any
+// other error is the answer, so a broken override isn't silently hidden.
+func (o overlayFS) Open(name string) (http.File, error) {
+	err := error(fs.ErrNotExist)
+	for _, layer := range o {
+		var f http.File
+		f, err = layer.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// assetsFS is the bundled assets directory, under override when it is set
+func assetsFS(override string) http.FileSystem {
+	if override == "" {
+		return http.Dir("assets")
+	}
+	return overlayFS{http.Dir(override), http.Dir("assets")}
+}
@@ -0,0 +1,11 @@
+// Reports which results get used to /beacon: the event and a hash of the query, nothing else.
+document.addEventListener('click', function (event) {
+  var link = event.target.closest('a[data-beacon]');
+  if (!link || !navigator.sendBeacon) {
+    return;
+  }
+  var data = new URLSearchParams();
+  data.set('event', link.dataset.beacon);
+  data.set('query', document.querySelector('main').dataset.queryHash || '');
+  navigator.sendBeacon('/beacon', data);
+});
@@ -0,0 +1,54 @@
+// Turns the pagination into a "Load more" button that appends the next few results in place,
+// loading them by itself as the button scrolls into view. Without scripts the pagination stays.
+(function () {
+  var button = document.querySelector('button.load-more');
+  var lists = document.querySelectorAll('ul.search-results');
+  if (!button || !lists.length || !window.fetch) {
+    return;
+  }
+  var list = lists[lists.length - 1];
+  var pagination = document.querySelector('.pagination');
+  var loading = false;
+
+  function load() {
+    if (loading || !button.dataset.more) {
+      return;
+    }
+    loading = true;
+    fetch(button.dataset.more).then(function (response) {
+      if (!response.ok) {
+        throw new Error(response.statusText);
+      }
+      return response.text();
+    }).then(function (fragment) {
+      var batch = document.createElement('div');
+      batch.innerHTML = fragment;
+      batch.querySelectorAll('ul.search-results > li').forEach(function (item) {
+        list.appendChild(item);
+      });
+      var more = batch.querySelector('.more-results');
+      button.dataset.more = more ? more.dataset.next : '';
+      button.hidden = !button.dataset.more;
+      loading = false;
+    }).catch(function () {
+      // leave the button for another try, the pagination still works
+      if (pagination) {
+        pagination.hidden = false;
+      }
+      loading = false;
+    });
+  }
+
+  button.hidden = false;
+  if (pagination) {
+    pagination.hidden = true;
+  }
+  button.addEventListener('click', load);
+  if (window.IntersectionObserver) {
+    new IntersectionObserver(function (entries) {
+      if (entries[0].isIntersecting) {
+        load();
+      }
+    }).observe(button);
+  }
+})();
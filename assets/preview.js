@@ -0,0 +1,21 @@
+// Opens preview links in a dialog instead of navigating to the bare fragment.
+document.addEventListener('click', function (event) {
+  var link = event.target.closest('a.preview-link');
+  var dialog = document.getElementById('preview-dialog');
+  if (!link || !dialog || !dialog.showModal) {
+    return;
+  }
+  event.preventDefault();
+
+  fetch(link.href).then(function (response) {
+    if (!response.ok) {
+      throw new Error(response.statusText);
+    }
+    return response.text();
+  }).then(function (fragment) {
+    dialog.querySelector('.preview-body').innerHTML = fragment;
+    dialog.showModal();
+  }).catch(function () {
+    window.location = link.href;
+  });
+});
@@ -0,0 +1,35 @@
+// Keeps the "time ago" of recent articles current while the page stays open, from each time
+// element's data-timestamp. The wording follows timeAgo in timeago.go, older dates are left
+// as the server wrote them.
+(function () {
+  var minute = 60 * 1000, hour = 60 * minute, day = 24 * hour, limit = 7 * day;
+
+  function ago(n, unit) {
+    return n + ' ' + unit + (n === 1 ? '' : 's') + ' ago';
+  }
+
+  function describe(age) {
+    if (age < minute) {
+      return 'just now';
+    }
+    if (age < hour) {
+      return ago(Math.floor(age / minute), 'minute');
+    }
+    if (age < day) {
+      return ago(Math.floor(age / hour), 'hour');
+    }
+    return ago(Math.floor(age / day), 'day');
+  }
+
+  function refresh() {
+    var now = Date.now();
+    document.querySelectorAll('time[data-timestamp]').forEach(function (el) {
+      var age = now - Date.parse(el.dataset.timestamp);
+      if (age >= 0 && age < limit) {
+        el.textContent = describe(age);
+      }
+    });
+  }
+
+  setInterval(refresh, minute);
+})();
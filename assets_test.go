@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// brokenFS fails every Open with something other than a missing file
+type brokenFS struct{}
+
+func (brokenFS) Open(string) (http.File, error) { return nil, fs.ErrPermission }
+
+func TestOverlayFS(t *testing.T) {
+	override := http.FS(fstest.MapFS{"style.css": {Data: []byte("override")}})
+	bundled := http.FS(fstest.MapFS{
+		"style.css": {Data: []byte("bundled")},
+		"more.js":   {Data: []byte("bundled more")},
+	})
+	tests := []struct {
+		name    string
+		layers  overlayFS
+		file    string
+		want    string
+		wantErr error
+	}{
+		{name: "override wins", layers: overlayFS{override, bundled}, file: "/style.css", want: "override"},
+		{name: "falls through when missing", layers: overlayFS{override, bundled}, file: "/more.js", want: "bundled more"},
+		{name: "missing everywhere", layers: overlayFS{override, bundled}, file: "/none.js", wantErr: fs.ErrNotExist},
+		{name: "no layers", layers: overlayFS{}, file: "/style.css", wantErr: fs.ErrNotExist},
+		{name: "other errors don't fall through", layers: overlayFS{brokenFS{}, bundled}, file: "/style.css", wantErr: fs.ErrPermission},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := tt.layers.Open(tt.file)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Open(%s) error = %v, want %v", tt.file, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			got, _ := io.ReadAll(f)
+			if string(got) != tt.want {
+				t.Errorf("Open(%s) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssetsOverride(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "style.css"), []byte("/* branded */"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		override string
+		file     string
+		want     int
+		wantBody string
+	}{
+		{name: "bundled", file: "/more.js", want: http.StatusOK},
+		{name: "missing", file: "/none.js", want: http.StatusNotFound},
+		{name: "overridden", override: dir, file: "/style.css", want: http.StatusOK, wantBody: "/* branded */"},
+		{name: "not overridden", override: dir, file: "/more.js", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.FileServer(assetsFS(tt.override))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.file, nil))
+			if w.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.file, w.Code, tt.want)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("GET %s = %q, want %q", tt.file, w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestValidateConfigAssetsOverride(t *testing.T) {
+	dir := t.TempDir()
+	runConfigTests(t, []configTest{
+		{name: "directory", change: func(c *startupConfig) { c.assetsOverride = dir }},
+		{name: "missing", change: func(c *startupConfig) { c.assetsOverride = dir + "/missing" }, wantErr: "assets-override"},
+		{name: "a file", change: func(c *startupConfig) { c.assetsOverride = "assets/style.css" }, wantErr: "assets-override"},
+	})
+}
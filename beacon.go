@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxBeaconQueries caps how many distinct query hashes are counted, later ones only add to
+	// their event's total so the counts can't grow without bound
+	maxBeaconQueries = 1000
+	// queryHashLength is how many hex digits of the query's SHA-256 identify it
+	queryHashLength = 16
+	beaconWindow    = time.Minute
+)
+
+// beaconEvents are the events /beacon accepts
+var beaconEvents = map[string]bool{
+	"result_click":  true,
+	"preview_open":  true,
+	"reader_open":   true,
+	"source_hidden": true,
+}
+
+// beaconEnabled turns on /beacon and the script reporting to it, set by -beacon
+var beaconEnabled *bool
+
+// Beacon tells the template whether to load the beacon script
+func (s *Search) Beacon() bool {
+	return *beaconEnabled
+}
+
+// QueryHash identifies the search in beacons without sending the query itself: a short SHA-256
+// of the normalized query, so the same search counts together however it was typed. It is
+// empty on the homepage.
+func (s *Search) QueryHash() string {
+	if s.Home || strings.TrimSpace(s.DisplayQuery()) == "" {
+		return ""
+	}
+	return queryHash(s.DisplayQuery())
+}
+
+func queryHash(q string) string {
+	sum := sha256.Sum256([]byte(normalizeQuery(q)))
+	return hex.EncodeToString(sum[:])[:queryHashLength]
+}
+
+// validQueryHash accepts what QueryHash produces, empty for events outside a search
+func validQueryHash(h string) bool {
+	if h == "" {
+		return true
+	}
+	if len(h) != queryHashLength {
+		return false
+	}
+	_, err := hex.DecodeString(h)
+	return err == nil && strings.ToLower(h) == h
+}
+
+// beaconCounts aggregates beacon events, by name and by name per query hash
+type beaconCounts struct {
+	mu      sync.Mutex
+	events  map[string]int64
+	queries map[string]map[string]int64
+}
+
+func (c *beaconCounts) record(event, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.events == nil {
+		c.events = map[string]int64{}
+		c.queries = map[string]map[string]int64{}
+	}
+	c.events[event]++
+	if hash == "" {
+		return
+	}
+	byEvent, ok := c.queries[hash]
+	if !ok {
+		if len(c.queries) >= maxBeaconQueries {
+			return
+		}
+		byEvent = map[string]int64{}
+		c.queries[hash] = byEvent
+	}
+	byEvent[event]++
+}
+
+// snapshot copies the counts, so /stats can encode them while beacons keep coming in
+func (c *beaconCounts) snapshot() (map[string]int64, map[string]map[string]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	events := make(map[string]int64, len(c.events))
+	for e, n := range c.events {
+		events[e] = n
+	}
+	queries := make(map[string]map[string]int64, len(c.queries))
+	for h, byEvent := range c.queries {
+		copied := make(map[string]int64, len(byEvent))
+		for e, n := range byEvent {
+			copied[e] = n
+		}
+		queries[h] = copied
+	}
+	return events, queries
+}
+
+func (c *beaconCounts) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events, c.queries = nil, nil
+}
+
+// windowLimiter allows each client limit requests per window, counting in fixed windows
+// rather than keeping a bucket per client
+type windowLimiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	started time.Time
+	counts  map[string]int
+}
+
+func newWindowLimiter(limit int, window time.Duration) *windowLimiter {
+	return &windowLimiter{limit: limit, window: window, now: time.Now, counts: map[string]int{}}
+}
+
+// Allow counts a request from client and reports whether it is within the limit
+func (l *windowLimiter) Allow(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now := l.now(); now.Sub(l.started) >= l.window {
+		l.started = now
+		l.counts = map[string]int{}
+	}
+	l.counts[client]++
+	return l.counts[client] <= l.limit
+}
+
+// beaconLimiter is set up in main from -beacon-rate
+var beaconLimiter *windowLimiter
+
+// clientIP is who a request comes from, for rate limiting: the connection's address, or behind a
+// -trust-proxy proxy the last X-Forwarded-For entry. That one our proxy appended, the entries
+// before it arrived from the client and can say anything.
+func clientIP(r *http.Request) string {
+	if *trustProxy {
+		fwd := strings.Join(r.Header.Values("X-Forwarded-For"), ",")
+		if i := strings.LastIndex(fwd, ","); i >= 0 {
+			fwd = fwd[i+1:]
+		}
+		if last := strings.TrimSpace(fwd); last != "" {
+			return last
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// beaconHandler records a posted event and query hash, answering 204 as beacons expect
+func beaconHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "Cross-origin beacons are not accepted", http.StatusForbidden)
+		return
+	}
+	if !beaconLimiter.Allow(clientIP(r)) {
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return
+	}
+	if !parseForm(w, r) {
+		return
+	}
+
+	event, hash := r.PostForm.Get("event"), r.PostForm.Get("query")
+	if !beaconEvents[event] {
+		http.Error(w, "Unknown event", http.StatusBadRequest)
+		return
+	}
+	if !validQueryHash(hash) {
+		http.Error(w, "Invalid query hash", http.StatusBadRequest)
+		return
+	}
+	stats.beacons.record(event, hash)
+	w.WriteHeader(http.StatusNoContent)
+}
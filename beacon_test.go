@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "connection address", remoteAddr: "203.0.113.7:51234", want: "203.0.113.7"},
+		{name: "forwarded ignored without -trust-proxy", remoteAddr: "203.0.113.7:51234", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "proxy's hop", trustProxy: true, remoteAddr: "10.0.0.2:51234", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "spoofed entries before it", trustProxy: true, remoteAddr: "10.0.0.2:51234", forwarded: []string{"1.2.3.4, 5.6.7.8,198.51.100.1"}, want: "198.51.100.1"},
+		{name: "across repeated headers", trustProxy: true, remoteAddr: "10.0.0.2:51234", forwarded: []string{"1.2.3.4", "198.51.100.1"}, want: "198.51.100.1"},
+		{name: "no header behind the proxy", trustProxy: true, remoteAddr: "10.0.0.2:51234", want: "10.0.0.2"},
+		{name: "address without a port", remoteAddr: "203.0.113.7", want: "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &trustProxy, tt.trustProxy)
+			r := httptest.NewRequest(http.MethodPost, "/beacon", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, f := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", f)
+			}
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowLimiter(t *testing.T) {
+	start := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	type request struct {
+		client string
+		at     time.Duration
+		want   bool
+	}
+	tests := []struct {
+		name     string
+		requests []request
+	}{
+		{name: "up to the limit", requests: []request{{"a", 0, true}, {"a", time.Second, true}, {"a", 2 * time.Second, false}}},
+		{name: "clients counted apart", requests: []request{{"a", 0, true}, {"a", 0, true}, {"b", 0, true}, {"a", 0, false}}},
+		{name: "next window starts over", requests: []request{{"a", 0, true}, {"a", 0, true}, {"a", 0, false}, {"a", time.Minute, true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newWindowLimiter(2, time.Minute)
+			for i, req := range tt.requests {
+				l.now = func() time.Time { return start.Add(req.at) }
+				if got := l.Allow(req.client); got != req.want {
+					t.Errorf("request %d from %s: Allow() = %v, want %v", i, req.client, got, req.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidQueryHash(t *testing.T) {
+	tests := []struct {
+		hash string
+		want bool
+	}{
+		{hash: queryHash("go"), want: true},
+		{hash: "", want: true},
+		{hash: strings.ToUpper(queryHash("go")), want: false},
+		{hash: queryHash("go")[:8], want: false},
+		{hash: "zzzzzzzzzzzzzzzz", want: false},
+	}
+	for _, tt := range tests {
+		if got := validQueryHash(tt.hash); got != tt.want {
+			t.Errorf("validQueryHash(%q) = %v, want %v", tt.hash, got, tt.want)
+		}
+	}
+	if queryHash("Go  News") != queryHash("go news") {
+		t.Error("queryHash differs for the same normalized query")
+	}
+}
+
+// postBeacon posts form to /beacon from the given client address
+func postBeacon(form, origin, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "http://news.example.com/beacon", strings.NewReader(form))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	r.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	beaconHandler(w, r)
+	return w
+}
+
+func TestBeaconHandler(t *testing.T) {
+	hash := queryHash("go")
+	tests := []struct {
+		name       string
+		form       string
+		origin     string
+		wantStatus int
+		wantCount  int64
+	}{
+		{name: "counted", form: "event=result_click&query=" + hash, wantStatus: http.StatusNoContent, wantCount: 1},
+		{name: "same origin", form: "event=result_click&query=" + hash, origin: "http://news.example.com", wantStatus: http.StatusNoContent, wantCount: 1},
+		{name: "outside a search", form: "event=result_click", wantStatus: http.StatusNoContent, wantCount: 1},
+		{name: "cross-origin", form: "event=result_click&query=" + hash, origin: "https://evil.test", wantStatus: http.StatusForbidden},
+		{name: "unknown event", form: "event=scroll&query=" + hash, wantStatus: http.StatusBadRequest},
+		{name: "query instead of its hash", form: "event=result_click&query=go", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &beaconLimiter, newWindowLimiter(100, time.Minute))
+			stats.beacons.reset()
+			t.Cleanup(stats.beacons.reset)
+			w := postBeacon(tt.form, tt.origin, "203.0.113.7:51234")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			events, _ := stats.beacons.snapshot()
+			if got := events["result_click"]; got != tt.wantCount {
+				t.Errorf("result_click counted %d times, want %d", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestBeaconHandlerMethod(t *testing.T) {
+	w := get(beaconHandler, "/beacon?event=result_click")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != http.MethodPost {
+		t.Errorf("Allow = %q, want POST", got)
+	}
+}
+
+func TestBeaconRateLimit(t *testing.T) {
+	setVar(t, &beaconLimiter, newWindowLimiter(2, time.Minute))
+	t.Cleanup(stats.beacons.reset)
+	tests := []struct {
+		remoteAddr string
+		want       int
+	}{
+		{remoteAddr: "203.0.113.7:1", want: http.StatusNoContent},
+		{remoteAddr: "203.0.113.7:2", want: http.StatusNoContent},
+		{remoteAddr: "203.0.113.7:3", want: http.StatusTooManyRequests},
+		{remoteAddr: "203.0.113.8:1", want: http.StatusNoContent},
+	}
+	for i, tt := range tests {
+		if w := postBeacon("event=preview_open", "", tt.remoteAddr); w.Code != tt.want {
+			t.Errorf("beacon %d from %s: status = %d, want %d", i, tt.remoteAddr, w.Code, tt.want)
+		}
+	}
+}
+
+func TestBeaconCountsCapQueries(t *testing.T) {
+	var c beaconCounts
+	for i := range maxBeaconQueries + 10 {
+		c.record("result_click", queryHash(fmt.Sprint("query ", i)))
+	}
+	events, queries := c.snapshot()
+	if got := events["result_click"]; got != maxBeaconQueries+10 {
+		t.Errorf("result_click total = %d, want %d", got, maxBeaconQueries+10)
+	}
+	if len(queries) != maxBeaconQueries {
+		t.Errorf("counted %d query hashes, want at most %d", len(queries), maxBeaconQueries)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBenchNewsAPI serves the same page of 20 articles of about 1KB each to every request, the
+// size of a typical newsapi page
+func newBenchNewsAPI(b *testing.B) *httptest.Server {
+	b.Helper()
+	articles := make([]Articles, 20)
+	for i := range articles {
+		articles[i] = testArticle(i + 1)
+		articles[i].Content = strings.Repeat("Lorem ipsum dolor sit amet. ", 32)
+	}
+	body, err := json.Marshal(Results{Status: "ok", TotalResults: 1000, Articles: articles})
+	if err != nil {
+		b.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	b.Cleanup(srv.Close)
+	return srv
+}
+
+func BenchmarkBuildArticlesURL(b *testing.B) {
+	params := url.Values{"q": {"climate change"}, "page": {"2"}, "pageSize": {"20"}, "excludeDomains": {"example.com"}}
+	b.ReportAllocs()
+	for b.Loop() {
+		buildArticlesURL("https://newsapi.org", endpointEverything, params)
+	}
+}
+
+func BenchmarkEverything(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		ttl  time.Duration
+	}{
+		{name: "warm", ttl: time.Minute},
+		{name: "uncached", ttl: 0},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			srv := newBenchNewsAPI(b)
+			c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(10), bm.ttl)
+			params := url.Values{"q": {"go"}, "page": {"1"}, "pageSize": {"20"}}
+			ctx := context.Background()
+			if _, err := c.Everything(ctx, params); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := c.Everything(ctx, params); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSearchHandlerWarm(b *testing.B) {
+	useNewsAPI(b, newBenchNewsAPI(b))
+	r := httptest.NewRequest(http.MethodGet, "/search?q=go&page=1", nil)
+	searchHandler(httptest.NewRecorder(), r)
+	b.ReportAllocs()
+	for b.Loop() {
+		w := httptest.NewRecorder()
+		searchHandler(w, r)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status = %d", w.Code)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	savedCookie = "saved"
+	// maxSavedCookie keeps the signed cookie comfortably under the 4KB browsers allow
+	maxSavedCookie = 3500
+	// maxSavedTitle is how much of a title we keep, long ones would crowd out other bookmarks
+	maxSavedTitle = 80
+)
+
+var savedTpl = newTemplateStore("saved.html")
+
+// savedArticle is a bookmark, compact since the whole list lives in a cookie
+type savedArticle struct {
+	URL   string `json:"u"`
+	Title string `json:"t"`
+}
+
+// readSaved returns the bookmarks in the request, oldest first. A missing or tampered cookie reads as empty.
+func readSaved(r *http.Request) []savedArticle {
+	c, err := r.Cookie(savedCookie)
+	if err != nil {
+		return nil
+	}
+	payload, err := verify(c.Value)
+	if err != nil {
+		return nil
+	}
+	var saved []savedArticle
+	if err := json.Unmarshal(payload, &saved); err != nil {
+		return nil
+	}
+	return saved
+}
+
+// encodeSaved signs the bookmarks, dropping the oldest until the cookie fits in maxSavedCookie
+func encodeSaved(saved []savedArticle) (string, []savedArticle) {
+	for {
+		payload, _ := json.Marshal(saved)
+		token := sign(payload)
+		if len(token) <= maxSavedCookie || len(saved) == 0 {
+			return token, saved
+		}
+		saved = saved[1:]
+	}
+}
+
+func writeSaved(w http.ResponseWriter, saved []savedArticle) {
+	token, _ := encodeSaved(saved)
+	http.SetCookie(w, &http.Cookie{
+		Name:     savedCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// addSaved appends a bookmark, moving it to the newest position if it was already saved
+func addSaved(saved []savedArticle, a savedArticle) []savedArticle {
+	return append(removeSaved(saved, a.URL), a)
+}
+
+func removeSaved(saved []savedArticle, articleURL string) []savedArticle {
+	kept := make([]savedArticle, 0, len(saved))
+	for _, s := range saved {
+		if s.URL != articleURL {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// sameOrigin rejects cross-site form posts, which could otherwise overwrite a visitor's cookies
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// savedAddHandler bookmarks the posted url and title, then goes back to next
+func savedAddHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !sameOrigin(r) {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !parseForm(w, r) {
+		return
+	}
+
+	u, err := validateRemoteURL(r.FormValue("url"), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	title := []rune(cleanText(r.FormValue("title")))
+	if len(title) > maxSavedTitle {
+		title = append(title[:maxSavedTitle-1], '…')
+	}
+
+	writeSaved(w, addSaved(readSaved(r), savedArticle{URL: u.String(), Title: string(title)}))
+	http.Redirect(w, r, localRedirect(r.FormValue("next")), http.StatusSeeOther)
+}
+
+// savedRemoveHandler drops the posted url from the bookmarks
+func savedRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !sameOrigin(r) {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !parseForm(w, r) {
+		return
+	}
+
+	writeSaved(w, removeSaved(readSaved(r), r.FormValue("url")))
+	http.Redirect(w, r, localRedirect(r.FormValue("next")), http.StatusSeeOther)
+}
+
+// savedHandler lists the bookmarks, newest first
+func savedHandler(w http.ResponseWriter, r *http.Request) {
+	saved := readSaved(r)
+	newest := make([]savedArticle, len(saved))
+	for i, s := range saved {
+		newest[len(saved)-1-i] = s
+	}
+
+	if err := savedTpl.Get().Execute(w, newest); err != nil {
+		log.Println(err)
+	}
+}
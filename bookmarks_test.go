@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadSaved(t *testing.T) {
+	saved := []savedArticle{{URL: "https://news.example.com/a/1", Title: "Story 1"}}
+	token, _ := encodeSaved(saved)
+	tests := []struct {
+		name   string
+		cookie string
+		want   []savedArticle
+	}{
+		{name: "signed", cookie: token, want: saved},
+		{name: "no cookie", cookie: "", want: nil},
+		{name: "tampered", cookie: "W3sidSI6Imh0dHBzOi8vZXZpbC50ZXN0In1d" + token[strings.Index(token, "."):], want: nil},
+		{name: "unsigned", cookie: `[{"u":"https://evil.test"}]`, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/saved", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: savedCookie, Value: tt.cookie})
+			}
+			if got := readSaved(r); !slices.Equal(got, tt.want) {
+				t.Errorf("readSaved() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddSaved(t *testing.T) {
+	a := savedArticle{URL: "https://news.example.com/a/1", Title: "Story 1"}
+	b := savedArticle{URL: "https://news.example.com/a/2", Title: "Story 2"}
+	tests := []struct {
+		name  string
+		saved []savedArticle
+		add   savedArticle
+		want  []savedArticle
+	}{
+		{name: "first", saved: nil, add: a, want: []savedArticle{a}},
+		{name: "newest last", saved: []savedArticle{a}, add: b, want: []savedArticle{a, b}},
+		{name: "saved again moves to newest", saved: []savedArticle{a, b}, add: a, want: []savedArticle{b, a}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addSaved(tt.saved, tt.add); !slices.Equal(got, tt.want) {
+				t.Errorf("addSaved() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeSavedDropsOldest(t *testing.T) {
+	var saved []savedArticle
+	for i := range 100 {
+		saved = append(saved, savedArticle{URL: "https://news.example.com/a/" + strings.Repeat("x", 20) + string(rune('a'+i%26)), Title: strings.Repeat("t", 40)})
+	}
+	token, kept := encodeSaved(saved)
+	if len(token) > maxSavedCookie {
+		t.Errorf("cookie is %d bytes, want at most %d", len(token), maxSavedCookie)
+	}
+	if len(kept) == 0 || len(kept) == len(saved) || kept[len(kept)-1] != saved[len(saved)-1] {
+		t.Errorf("kept %d of %d bookmarks, want the newest that fit", len(kept), len(saved))
+	}
+}
+
+func TestSavedAddHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		form       url.Values
+		wantStatus int
+	}{
+		{name: "saves", method: http.MethodPost, form: url.Values{"url": {"https://news.example.com/a/1"}, "title": {"Story 1"}, "next": {"/search?q=go"}}, wantStatus: http.StatusSeeOther},
+		{name: "same origin", method: http.MethodPost, origin: "http://example.com", form: url.Values{"url": {"https://news.example.com/a/1"}}, wantStatus: http.StatusSeeOther},
+		{name: "cross origin", method: http.MethodPost, origin: "https://evil.test", form: url.Values{"url": {"https://news.example.com/a/1"}}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "GET", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+		{name: "not a link", method: http.MethodPost, form: url.Values{"url": {"javascript:alert(1)"}}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/saved/add", strings.NewReader(tt.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			savedAddHandler(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusSeeOther {
+				return
+			}
+			got := httptest.NewRequest(http.MethodGet, "/saved", nil)
+			for _, c := range w.Result().Cookies() {
+				got.AddCookie(c)
+			}
+			if saved := readSaved(got); len(saved) != 1 || saved[0].URL != tt.form.Get("url") {
+				t.Errorf("cookie holds %v, want the posted article", saved)
+			}
+		})
+	}
+}
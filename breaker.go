@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling newsapi while the breaker is open
+var ErrCircuitOpen = errors.New("news service temporarily unavailable")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops calling newsapi after threshold consecutive failures. Once cooldown has
+// passed it half-opens and lets a single request through: success closes it again, failure
+// reopens it for another cooldown.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	// now is time.Now, swappable to drive the breaker through its states
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	// probing is set while the one half-open request is in flight
+	probing bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether a request may go upstream, ErrCircuitOpen when it may not
+func (b *circuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record feeds back the outcome of an allowed request. A canceled request says nothing about
+// newsapi either way, it only frees up the half-open probe.
+func (b *circuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if isCanceled(err) {
+		return
+	}
+	if !upstreamFailure(err) {
+		b.failures = 0
+		b.setState(breakerClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.setState(breakerOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(s breakerState) {
+	if b.state != s {
+		log.Printf("newsapi circuit breaker %s", s)
+		b.state = s
+	}
+}
+
+// breakerCodes are the newsapi error codes that say newsapi itself is struggling
+var breakerCodes = map[string]bool{
+	codeRateLimited:      true,
+	codeUnexpectedError:  true,
+	codeUnexpectedStatus: true,
+}
+
+// upstreamFailure tells outages apart from errors about the request: transport errors, bodiless
+// error statuses and newsapi's own trouble count, a bad query, a rejected key or a caller
+// hanging up don't
+func upstreamFailure(err error) bool {
+	if err == nil || isCanceled(err) || errors.Is(err, ErrNotConfigured) {
+		return false
+	}
+	var apiErr *NewsAPIError
+	if errors.As(err, &apiErr) {
+		return breakerCodes[apiErr.Code]
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	outage := errors.New("connection refused")
+	type step struct {
+		// after the clock moves on by wait, allow calls Allow and expects it to refuse when
+		// wantRefused, otherwise err is fed back to Record; wantState left out is closed
+		allow       bool
+		wantRefused bool
+		err         error
+		wait        time.Duration
+		wantState   breakerState
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after threshold failures",
+			steps: []step{
+				{err: outage, wantState: breakerClosed},
+				{err: outage, wantState: breakerClosed},
+				{err: outage, wantState: breakerOpen},
+				{allow: true, wantRefused: true, wantState: breakerOpen},
+			},
+		},
+		{
+			name: "a success resets the count",
+			steps: []step{
+				{err: outage, wantState: breakerClosed},
+				{err: outage, wantState: breakerClosed},
+				{err: nil, wantState: breakerClosed},
+				{err: outage, wantState: breakerClosed},
+				{err: outage, wantState: breakerClosed},
+			},
+		},
+		{
+			name: "half-opens after the cooldown, a good probe closes it",
+			steps: []step{
+				{err: outage}, {err: outage}, {err: outage, wantState: breakerOpen},
+				{wait: time.Minute, allow: true, wantState: breakerHalfOpen},
+				{allow: true, wantRefused: true, wantState: breakerHalfOpen},
+				{err: nil, wantState: breakerClosed},
+				{allow: true, wantState: breakerClosed},
+			},
+		},
+		{
+			name: "a failed probe reopens it at once",
+			steps: []step{
+				{err: outage}, {err: outage}, {err: outage, wantState: breakerOpen},
+				{wait: time.Minute, allow: true, wantState: breakerHalfOpen},
+				{err: outage, wantState: breakerOpen},
+				{allow: true, wantRefused: true, wantState: breakerOpen},
+			},
+		},
+		{
+			name: "a canceled probe frees the slot",
+			steps: []step{
+				{err: outage}, {err: outage}, {err: outage, wantState: breakerOpen},
+				{wait: time.Minute, allow: true, wantState: breakerHalfOpen},
+				{err: context.Canceled, wantState: breakerHalfOpen},
+				{allow: true, wantState: breakerHalfOpen},
+			},
+		},
+		{
+			name: "still open within the cooldown",
+			steps: []step{
+				{err: outage}, {err: outage}, {err: outage, wantState: breakerOpen},
+				{wait: 29 * time.Second, allow: true, wantRefused: true, wantState: breakerOpen},
+			},
+		},
+		{
+			name: "bad requests don't count",
+			steps: []step{
+				{err: &NewsAPIError{Code: "parameterInvalid"}},
+				{err: ErrNotConfigured},
+				{err: outage},
+				{err: outage, wantState: breakerClosed},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+			b := newCircuitBreaker(3, 30*time.Second)
+			b.now = func() time.Time { return now }
+			for i, s := range tt.steps {
+				now = now.Add(s.wait)
+				if s.allow {
+					if err := b.Allow(); (err != nil) != s.wantRefused {
+						t.Fatalf("step %d: Allow() = %v, want refused %v", i, err, s.wantRefused)
+					}
+				} else {
+					b.Record(s.err)
+				}
+				if b.state != s.wantState {
+					t.Fatalf("step %d: state = %s, want %s", i, b.state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestUpstreamFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: errors.New("dial tcp: connection refused"), want: true},
+		{err: fmt.Errorf("newsapi: unexpected status %d", 502), want: true},
+		{err: context.Canceled, want: false},
+		{err: fmt.Errorf("fetch: %w", context.DeadlineExceeded), want: false},
+		{err: ErrNotConfigured, want: false},
+		{err: &NewsAPIError{Code: codeRateLimited}, want: true},
+		{err: &NewsAPIError{Code: codeUnexpectedError}, want: true},
+		{err: &NewsAPIError{Code: codeUnexpectedStatus}, want: true},
+		{err: &NewsAPIError{Code: "parameterInvalid"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := upstreamFailure(tt.err); got != tt.want {
+			t.Errorf("upstreamFailure(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNewsClientBreaker(t *testing.T) {
+	api := newErrorNewsAPI(t, http.StatusBadGateway, "")
+	c := NewNewsClient(api.Client(), api.URL, "test-key", newLRUCache(10), 0)
+	c.breaker = newCircuitBreaker(2, time.Minute)
+
+	var errs []error
+	for range 4 {
+		_, err := c.Everything(context.Background(), url.Values{"q": {"go"}})
+		errs = append(errs, err)
+	}
+	for i, err := range errs {
+		if open := errors.Is(err, ErrCircuitOpen); open != (i >= 2) {
+			t.Errorf("call %d: %v, want ErrCircuitOpen only once 2 calls failed", i, err)
+		}
+	}
+	if status, _ := fetchErrorResponse(errs[3]); status != http.StatusServiceUnavailable {
+		t.Errorf("open breaker answers %d, want 503", status)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache stores encoded values for a TTL. Backends treat their own failures as misses,
+// a cache outage slows searches down but doesn't break them.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(key string)
+}
+
+type cacheItem struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// ttlCache is a small in-memory cache whose entries expire after a per-item TTL. It keeps at
+// most limit entries and maxBytes of values, dropping the least recently used beyond either.
+// Every operation is amortized O(1): expired entries are dropped when Get finds them, and from the least
+// recently used end as Set makes room, rather than by scanning.
+type ttlCache struct {
+	mu       sync.Mutex
+	limit    int
+	maxBytes int
+	bytes    int
+	items    map[string]*list.Element
+	// order holds the *cacheItems, most recently used first
+	order *list.List
+}
+
+// newLRUCache is a ttlCache holding at most limit entries, 0 for no limit
+func newLRUCache(limit int) *ttlCache {
+	return newBoundedCache(limit, 0)
+}
+
+// newBoundedCache is a ttlCache holding at most limit entries and maxBytes of values, 0 leaving
+// either unbounded
+func newBoundedCache(limit, maxBytes int) *ttlCache {
+	return &ttlCache{limit: limit, maxBytes: maxBytes, items: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *ttlCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*cacheItem)
+	if time.Now().After(item.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return item.value, true
+}
+
+func (c *ttlCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a value over the whole byte budget would only push everything else out
+	if c.maxBytes > 0 && len(value) > c.maxBytes {
+		if el, ok := c.items[key]; ok {
+			c.remove(el)
+		}
+		return
+	}
+	now := time.Now()
+	if el, ok := c.items[key]; ok {
+		item := el.Value.(*cacheItem)
+		c.bytes += len(value) - len(item.value)
+		item.value, item.expires = value, now.Add(ttl)
+		c.order.MoveToFront(el)
+	} else {
+		c.items[key] = c.order.PushFront(&cacheItem{key: key, value: value, expires: now.Add(ttl)})
+		c.bytes += len(value)
+	}
+
+	// the least recently used end goes first: expired entries there, then whatever is over the limits
+	for back := c.order.Back(); back != nil && back != c.order.Front() && now.After(back.Value.(*cacheItem).expires); back = c.order.Back() {
+		c.remove(back)
+	}
+	for c.order.Len() > 1 && ((c.limit > 0 && c.order.Len() > c.limit) || (c.maxBytes > 0 && c.bytes > c.maxBytes)) {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *ttlCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Len is how many entries are held, expired ones included until they are dropped
+func (c *ttlCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove drops an entry, the caller holds mu
+func (c *ttlCache) remove(el *list.Element) {
+	item := el.Value.(*cacheItem)
+	c.order.Remove(el)
+	c.bytes -= len(item.value)
+	delete(c.items, item.key)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds every cache call, a slow Redis shouldn't hold up a search for long
+const redisTimeout = 500 * time.Millisecond
+
+// redisCache shares cached results between replicas
+type redisCache struct {
+	client *redis.Client
+	// prefix namespaces our keys in a Redis shared with other apps
+	prefix string
+}
+
+// newRedisCache connects to the redis:// URL rawURL and checks the connection
+func newRedisCache(rawURL string) (*redisCache, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisCache{client: redis.NewClient(opts), prefix: "news-atgo:"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.client.Ping(ctx).Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// pingRedis checks the Redis at rawURL answers, for validateConfig
+func pingRedis(rawURL string) error {
+	c, err := newRedisCache(rawURL)
+	if err != nil {
+		return err
+	}
+	return c.client.Close()
+}
+
+// redactURL hides the password in rawURL, for logging a -redis-url
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "the redis-url"
+	}
+	return u.Redacted()
+}
+
+func (c *redisCache) Get(key string) ([]byte, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	value, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("redis cache get: %v", err)
+		}
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *redisCache) Set(key string, value []byte, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := c.client.Set(ctx, c.prefix+key, value, ttl).Err(); err != nil {
+		log.Printf("redis cache set: %v", err)
+	}
+}
+
+func (c *redisCache) Delete(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := c.client.Del(ctx, c.prefix+key).Err(); err != nil {
+		log.Printf("redis cache delete: %v", err)
+	}
+}
+
+var (
+	_ Cache = (*ttlCache)(nil)
+	_ Cache = (*redisCache)(nil)
+)
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBoundedCacheByteLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int
+		sets     []string
+		want     []string
+		dropped  []string
+	}{
+		{name: "fits", maxBytes: 30, sets: []string{"a", "b", "c"}, want: []string{"a", "b", "c"}},
+		{name: "oldest go first", maxBytes: 20, sets: []string{"a", "b", "c"}, want: []string{"b", "c"}, dropped: []string{"a"}},
+		{name: "value over the budget isn't kept", maxBytes: 5, sets: []string{"a"}, dropped: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newBoundedCache(0, tt.maxBytes)
+			for _, key := range tt.sets {
+				c.Set(key, []byte(strings.Repeat(key, 10)), time.Minute)
+			}
+			for _, key := range tt.want {
+				if _, ok := c.Get(key); !ok {
+					t.Errorf("%s was dropped", key)
+				}
+			}
+			for _, key := range tt.dropped {
+				if _, ok := c.Get(key); ok {
+					t.Errorf("%s is still cached", key)
+				}
+			}
+		})
+	}
+}
+
+func TestLRUCacheEvictionOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     func(c *ttlCache)
+		kept    []string
+		dropped []string
+	}{
+		{
+			name:    "least recently set goes",
+			ops:     func(c *ttlCache) { c.Set("d", []byte("d"), time.Minute) },
+			kept:    []string{"b", "c", "d"},
+			dropped: []string{"a"},
+		},
+		{
+			name: "a get counts as a use",
+			ops: func(c *ttlCache) {
+				c.Get("a")
+				c.Set("d", []byte("d"), time.Minute)
+			},
+			kept:    []string{"a", "c", "d"},
+			dropped: []string{"b"},
+		},
+		{
+			name: "setting again counts as a use",
+			ops: func(c *ttlCache) {
+				c.Set("a", []byte("a2"), time.Minute)
+				c.Set("d", []byte("d"), time.Minute)
+				c.Set("e", []byte("e"), time.Minute)
+			},
+			kept:    []string{"a", "d", "e"},
+			dropped: []string{"b", "c"},
+		},
+		{
+			name: "a deleted entry frees its place",
+			ops: func(c *ttlCache) {
+				c.Delete("b")
+				c.Set("d", []byte("d"), time.Minute)
+			},
+			kept:    []string{"a", "c", "d"},
+			dropped: []string{"b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newLRUCache(3)
+			for _, key := range []string{"a", "b", "c"} {
+				c.Set(key, []byte(key), time.Minute)
+			}
+			tt.ops(c)
+			if c.Len() != len(tt.kept) {
+				t.Errorf("Len() = %d, want %d", c.Len(), len(tt.kept))
+			}
+			for _, key := range tt.dropped {
+				if _, ok := c.Get(key); ok {
+					t.Errorf("%s is still cached", key)
+				}
+			}
+			for _, key := range tt.kept {
+				if _, ok := c.Get(key); !ok {
+					t.Errorf("%s was dropped", key)
+				}
+			}
+		})
+	}
+}
+
+func TestLRUCacheExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     func(c *ttlCache)
+		wantLen int
+	}{
+		{name: "an expired entry counts until it is found", ops: func(c *ttlCache) {}, wantLen: 1},
+		{name: "Get drops it", ops: func(c *ttlCache) { c.Get("old") }, wantLen: 0},
+		{name: "Set drops it from the old end", ops: func(c *ttlCache) { c.Set("new", []byte("v"), time.Minute) }, wantLen: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newLRUCache(10)
+			c.Set("old", []byte("v"), -time.Second)
+			tt.ops(c)
+			if got := c.Len(); got != tt.wantLen {
+				t.Errorf("Len() = %d, want %d", got, tt.wantLen)
+			}
+			if _, ok := c.Get("old"); ok {
+				t.Error("expired entry served")
+			}
+		})
+	}
+}
+
+func TestTTLCache(t *testing.T) {
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		ops    func(c Cache)
+		want   string
+		wantOK bool
+	}{
+		{name: "set then get", ttl: time.Minute, ops: func(c Cache) {}, want: "v1", wantOK: true},
+		{name: "set again overwrites", ttl: time.Minute, ops: func(c Cache) { c.Set("k", []byte("v2"), time.Minute) }, want: "v2", wantOK: true},
+		{name: "deleted", ttl: time.Minute, ops: func(c Cache) { c.Delete("k") }},
+		{name: "deleting a missing key", ttl: time.Minute, ops: func(c Cache) { c.Delete("other") }, want: "v1", wantOK: true},
+		{name: "expired", ttl: -time.Second, ops: func(c Cache) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newLRUCache(10)
+			c.Set("k", []byte("v1"), tt.ttl)
+			tt.ops(c)
+			got, ok := c.Get("k")
+			if ok != tt.wantOK || string(got) != tt.want {
+				t.Errorf("Get(k) = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// recordingCache is a Cache that notes the keys it is asked for
+type recordingCache struct {
+	*ttlCache
+	gets, sets []string
+}
+
+func (c *recordingCache) Get(key string) ([]byte, bool) {
+	c.gets = append(c.gets, key)
+	return c.ttlCache.Get(key)
+}
+
+func (c *recordingCache) Set(key string, value []byte, ttl time.Duration) {
+	c.sets = append(c.sets, key)
+	c.ttlCache.Set(key, value, ttl)
+}
+
+func TestNewsClientUsesTheGivenCache(t *testing.T) {
+	api := newFakeNewsAPI(t, 5)
+	cache := &recordingCache{ttlCache: newLRUCache(10)}
+	c := NewNewsClient(api.Client(), api.URL, "test-key", cache, time.Minute)
+
+	for range 2 {
+		if _, err := c.Everything(context.Background(), url.Values{"q": {"go"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := api.hits.Load(); got != 1 {
+		t.Errorf("newsapi got %d requests, want 1", got)
+	}
+	if len(cache.sets) != 1 || !strings.HasPrefix(cache.sets[0], "everything|") {
+		t.Errorf("cache sets = %q, want one everything entry", cache.sets)
+	}
+	for _, key := range append(cache.gets, cache.sets...) {
+		if strings.Contains(key, "test-key") {
+			t.Errorf("cache key %q holds the API key", key)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// cacheTTLForResults picks how long clients may cache a results page from how fresh its newest
+// article is: a topic with minutes-old stories changes quickly, one whose newest story is a day
+// old hardly changes at all
+func cacheTTLForResults(results Results) time.Duration {
+	newest := newestPublished(results)
+	if newest.IsZero() {
+		return time.Minute
+	}
+
+	switch age := time.Since(newest); {
+	case age < time.Hour:
+		return time.Minute
+	case age < 6*time.Hour:
+		return 5 * time.Minute
+	case age < 24*time.Hour:
+		return 15 * time.Minute
+	default:
+		return time.Hour
+	}
+}
+
+// newestPublished is the latest PublishedAt among the results, zero when none has a date
+func newestPublished(results Results) time.Time {
+	var newest time.Time
+	for _, a := range results.Articles {
+		if a.PublishedAt.After(newest) {
+			newest = a.PublishedAt.Time
+		}
+	}
+	return newest
+}
+
+// lastModified is the Last-Modified of a results response: its newest article, to the second as
+// HTTP dates are. It only depends on the results, so every replica and cache hit agrees on it.
+func lastModified(results Results) time.Time {
+	return newestPublished(results).UTC().Truncate(time.Second)
+}
+
+// notModified sets Last-Modified from the results and, when If-Modified-Since shows the client
+// already has them, answers 304 and reports true. Results without any dates get neither.
+func notModified(w http.ResponseWriter, r *http.Request, results Results) bool {
+	modified := lastModified(results)
+	if modified.IsZero() {
+		return false
+	}
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// setCacheControl sets max-age from the results' freshness, scope is "public" or "private"
+func setCacheControl(w http.ResponseWriter, scope string, results Results) {
+	if results.Stale {
+		// a fallback while newsapi is unavailable, fresh results should replace it as soon as possible
+		w.Header().Set("Cache-Control", scope+", no-cache")
+		return
+	}
+	ttl := cacheTTLForResults(results)
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, int(ttl.Seconds())))
+}
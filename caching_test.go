@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// resultsAged are results with an article of each age
+func resultsAged(ages ...time.Duration) Results {
+	var results Results
+	for _, age := range ages {
+		results.Articles = append(results.Articles, Articles{PublishedAt: Timestamp{time.Now().Add(-age)}})
+	}
+	return results
+}
+
+func TestCacheTTLForResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		results Results
+		want    time.Duration
+	}{
+		{name: "no articles", results: Results{}, want: time.Minute},
+		{name: "no dates", results: Results{Articles: []Articles{{Title: "undated"}}}, want: time.Minute},
+		{name: "59 minutes old", results: resultsAged(59 * time.Minute), want: time.Minute},
+		{name: "61 minutes old", results: resultsAged(61 * time.Minute), want: 5 * time.Minute},
+		{name: "5 hours old", results: resultsAged(5 * time.Hour), want: 5 * time.Minute},
+		{name: "7 hours old", results: resultsAged(7 * time.Hour), want: 15 * time.Minute},
+		{name: "2 days old", results: resultsAged(48 * time.Hour), want: time.Hour},
+		{name: "newest decides", results: resultsAged(48*time.Hour, 10*time.Minute, 7*time.Hour), want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheTTLForResults(tt.results); got != tt.want {
+				t.Errorf("cacheTTLForResults() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetCacheControl(t *testing.T) {
+	stale := resultsAged(48 * time.Hour)
+	stale.Stale = true
+	tests := []struct {
+		name    string
+		scope   string
+		results Results
+		want    string
+	}{
+		{name: "fresh page", scope: "public", results: resultsAged(10 * time.Minute), want: "public, max-age=60"},
+		{name: "old page", scope: "private", results: resultsAged(48 * time.Hour), want: "private, max-age=3600"},
+		{name: "stale fallback", scope: "public", results: stale, want: "public, no-cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			setCacheControl(w, tt.scope, tt.results)
+			if got := w.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	newest := time.Date(2026, 10, 14, 12, 30, 15, 500, time.UTC)
+	results := Results{Articles: []Articles{
+		{PublishedAt: Timestamp{newest.Add(-time.Hour)}},
+		{PublishedAt: Timestamp{newest}},
+	}}
+	tests := []struct {
+		name             string
+		results          Results
+		ifModifiedSince  string
+		want             bool
+		wantLastModified string
+	}{
+		{name: "no conditional", results: results, want: false, wantLastModified: "Wed, 14 Oct 2026 12:30:15 GMT"},
+		{name: "client is current", results: results, ifModifiedSince: "Wed, 14 Oct 2026 12:30:15 GMT", want: true, wantLastModified: "Wed, 14 Oct 2026 12:30:15 GMT"},
+		{name: "client is ahead", results: results, ifModifiedSince: "Wed, 14 Oct 2026 13:00:00 GMT", want: true, wantLastModified: "Wed, 14 Oct 2026 12:30:15 GMT"},
+		{name: "client is behind", results: results, ifModifiedSince: "Wed, 14 Oct 2026 12:30:14 GMT", want: false, wantLastModified: "Wed, 14 Oct 2026 12:30:15 GMT"},
+		{name: "unparseable date", results: results, ifModifiedSince: "yesterday", want: false, wantLastModified: "Wed, 14 Oct 2026 12:30:15 GMT"},
+		{name: "undated results", results: Results{Articles: []Articles{{Title: "undated"}}}, ifModifiedSince: "Wed, 14 Oct 2026 13:00:00 GMT", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+			if tt.ifModifiedSince != "" {
+				r.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+			}
+			w := httptest.NewRecorder()
+			if got := notModified(w, r, tt.results); got != tt.want {
+				t.Errorf("notModified() = %v, want %v", got, tt.want)
+			}
+			if got := w.Header().Get("Last-Modified"); got != tt.wantLastModified {
+				t.Errorf("Last-Modified = %q, want %q", got, tt.wantLastModified)
+			}
+			if tt.want && w.Code != http.StatusNotModified {
+				t.Errorf("status = %d, want 304", w.Code)
+			}
+		})
+	}
+}
+
+func TestConditionalSearchEndpoints(t *testing.T) {
+	handlers := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+	}{
+		{name: "search page", handler: searchHandler, target: "/search?q=go"},
+		{name: "search json", handler: searchJSONHandler, target: "/search.json?q=go"},
+		{name: "facets", handler: facetsHandler, target: "/facets?q=go"},
+	}
+	for _, h := range handlers {
+		t.Run(h.name, func(t *testing.T) {
+			useNewsAPI(t, newFakeNewsAPI(t, 5).Server)
+			first := get(h.handler, h.target)
+			modified := first.Header().Get("Last-Modified")
+			if first.Code != http.StatusOK || modified == "" {
+				t.Fatalf("first response: status %d, Last-Modified %q", first.Code, modified)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, h.target, nil)
+			r.Header.Set("If-Modified-Since", modified)
+			w := httptest.NewRecorder()
+			h.handler(w, r)
+			if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+				t.Errorf("revalidation: status %d with %d bytes, want an empty 304", w.Code, w.Body.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// cardFieldNames are the optional parts of an article card, the title and links are always shown
+var cardFieldNames = []string{"image", "description", "source", "favicon", "author", "date", "content"}
+
+// defaultCardFields is what -card-fields shows unless told otherwise
+const defaultCardFields = "image,description,source,favicon,date"
+
+// cardFields is the set of fields the card templates render, set from -card-fields
+var cardFields map[string]bool
+
+// parseCardFields validates a comma separated -card-fields value
+func parseCardFields(list string) (map[string]bool, error) {
+	known := map[string]bool{}
+	for _, name := range cardFieldNames {
+		known[name] = true
+	}
+
+	fields := map[string]bool{}
+	var unknown []string
+	for _, name := range splitList(list) {
+		name = strings.ToLower(name)
+		if !known[name] {
+			unknown = append(unknown, name)
+			continue
+		}
+		fields[name] = true
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown card fields %s, the choices are %s",
+			strings.Join(unknown, ", "), strings.Join(cardFieldNames, ", "))
+	}
+	return fields, nil
+}
+
+// CardFields is the set the templates check before rendering each optional field, e.g. {{ if $.CardFields.author }}
+func (s *Search) CardFields() map[string]bool {
+	return cardFields
+}
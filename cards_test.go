@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseCardFields(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    map[string]bool
+		wantErr string
+	}{
+		{list: defaultCardFields, want: map[string]bool{"image": true, "description": true, "source": true, "favicon": true, "date": true}},
+		{list: " Author, DATE ", want: map[string]bool{"author": true, "date": true}},
+		{list: "", want: map[string]bool{}},
+		{list: "date,summary,byline", wantErr: "unknown card fields byline, summary"},
+	}
+	for _, tt := range tests {
+		got, err := parseCardFields(tt.list)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseCardFields(%q) error = %v, want %q", tt.list, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCardFields(%q): %v", tt.list, err)
+			continue
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("parseCardFields(%q) = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestCardFieldsRendered(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   string
+		want     []string
+		dontWant []string
+	}{
+		{
+			name:     "defaults",
+			fields:   defaultCardFields,
+			want:     []string{`class="description"`, `class="source"`, `class="favicon"`, `class="published-date"`},
+			dontWant: []string{`class="author"`},
+		},
+		{
+			name:     "author and date only",
+			fields:   "author,date",
+			want:     []string{`class="author"`, `class="published-date"`},
+			dontWant: []string{`class="description"`, `class="source"`, `class="favicon"`},
+		},
+		{
+			name:     "source without favicon",
+			fields:   "source",
+			want:     []string{`class="source"`},
+			dontWant: []string{`class="favicon"`, `class="published-date"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := parseCardFields(tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			setVar(t, &cardFields, fields)
+			a := testArticle(1)
+			a.Author = "Jo Reporter"
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(Results{Status: "ok", TotalResults: 1, Articles: []Articles{a}})
+			}))
+			t.Cleanup(srv.Close)
+			useNewsAPI(t, srv)
+
+			body := get(searchHandler, "/search?q=go").Body.String()
+			for _, s := range tt.want {
+				if !strings.Contains(body, s) {
+					t.Errorf("cards lack %s", s)
+				}
+			}
+			for _, s := range tt.dontWant {
+				if strings.Contains(body, s) {
+					t.Errorf("cards show %s", s)
+				}
+			}
+		})
+	}
+}
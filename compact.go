@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/url"
+	"time"
+)
+
+// compactSource and compactArticle are the compact=1 JSON of an article: the same fields
+// under the same names, but left out when empty instead of sent as "" or null
+type compactSource struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Country  string `json:"country,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+type compactArticle struct {
+	Source         *compactSource `json:"source,omitempty"`
+	Author         string         `json:"author,omitempty"`
+	Title          string         `json:"title,omitempty"`
+	Description    string         `json:"description,omitempty"`
+	URL            string         `json:"url,omitempty"`
+	URLToImage     string         `json:"urlToImage,omitempty"`
+	PublishedAt    string         `json:"publishedAt,omitempty"`
+	Content        string         `json:"content,omitempty"`
+	InvalidURL     bool           `json:"invalidUrl,omitempty"`
+	Headline       bool           `json:"headline,omitempty"`
+	AlsoReportedBy []string       `json:"alsoReportedBy,omitempty"`
+}
+
+type compactResults struct {
+	Status       string           `json:"status,omitempty"`
+	TotalResults int              `json:"totalResults,omitempty"`
+	Articles     []compactArticle `json:"articles,omitempty"`
+	NextCursor   string           `json:"nextCursor,omitempty"`
+	Stale        bool             `json:"stale,omitempty"`
+}
+
+func newCompactArticle(a Articles) compactArticle {
+	c := compactArticle{
+		Author:         a.Author,
+		Title:          a.Title,
+		Description:    a.Description,
+		URL:            a.URL,
+		URLToImage:     a.URLToImage,
+		Content:        a.Content,
+		InvalidURL:     a.InvalidURL,
+		Headline:       a.Headline,
+		AlsoReportedBy: a.AlsoReportedBy,
+	}
+	if !a.PublishedAt.IsZero() {
+		c.PublishedAt = a.PublishedAt.Format(time.RFC3339Nano)
+	}
+	id, _ := a.Source.ID.(string)
+	if src := (compactSource{ID: id, Name: a.Source.Name, Country: a.Source.Country, Category: a.Source.Category}); src != (compactSource{}) {
+		c.Source = &src
+	}
+	return c
+}
+
+func newCompactResults(results Results) compactResults {
+	c := compactResults{Status: results.Status, TotalResults: results.TotalResults, NextCursor: results.NextCursor, Stale: results.Stale}
+	for _, a := range results.Articles {
+		c.Articles = append(c.Articles, newCompactArticle(a))
+	}
+	return c
+}
+
+// boolParam reads an optional on/off param such as compact or download, on for 1 or true
+func boolParam(params url.Values, key string) (bool, error) {
+	v, err := singleParam(params, key)
+	return v == "1" || v == "true", err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBoolParam(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    bool
+		wantErr bool
+	}{
+		{query: "", want: false},
+		{query: "compact=1", want: true},
+		{query: "compact=true", want: true},
+		{query: "compact=0", want: false},
+		{query: "compact=yes", want: false},
+		{query: "compact=1&compact=1", wantErr: true},
+	}
+	for _, tt := range tests {
+		params, _ := url.ParseQuery(tt.query)
+		got, err := boolParam(params, "compact")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("boolParam(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("boolParam(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestNewCompactArticle(t *testing.T) {
+	published := time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		article Articles
+		want    string
+	}{
+		{name: "empty", article: Articles{}, want: `{}`},
+		{name: "source name only", article: Articles{Source: Source{Name: "BBC News"}, Title: "a"}, want: `{"source":{"name":"BBC News"},"title":"a"}`},
+		{name: "null source id", article: Articles{Source: Source{ID: nil}}, want: `{}`},
+		{
+			name:    "full",
+			article: Articles{Source: Source{ID: "bbc-news", Name: "BBC News"}, Author: "Jo", Title: "a", URL: "https://news.example.com/a", PublishedAt: Timestamp{published}, Headline: true},
+			want:    `{"source":{"id":"bbc-news","name":"BBC News"},"author":"Jo","title":"a","url":"https://news.example.com/a","publishedAt":"2026-10-14T09:30:00Z","headline":true}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(newCompactArticle(tt.article))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("compact JSON = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompactEndpoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		target     string
+		wantStatus int
+		wantEmpty  bool
+	}{
+		{name: "JSON", handler: searchJSONHandler, target: "/search.json?q=go", wantStatus: http.StatusOK, wantEmpty: true},
+		{name: "compact JSON", handler: searchJSONHandler, target: "/search.json?q=go&compact=1", wantStatus: http.StatusOK},
+		{name: "compact NDJSON", handler: searchNDJSONHandler, target: "/search.ndjson?q=go&compact=true", wantStatus: http.StatusOK},
+		{name: "NDJSON", handler: searchNDJSONHandler, target: "/search.ndjson?q=go", wantStatus: http.StatusOK, wantEmpty: true},
+		{name: "repeated compact", handler: searchJSONHandler, target: "/search.json?q=go&compact=1&compact=0", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, newFakeNewsAPI(t, 3).Server)
+			w := get(tt.handler, tt.target)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if !strings.Contains(w.Body.String(), `"title":"Story 1"`) {
+				t.Errorf("response lacks the articles: %s", w.Body)
+			}
+			// the test articles have no author
+			if got := strings.Contains(w.Body.String(), `"author":""`); got != tt.wantEmpty {
+				t.Errorf("empty author sent = %v, want %v", got, tt.wantEmpty)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// startupConfig is the flag values validateConfig checks, gathered so every problem can be
+// reported at once instead of stopping at the first
+type startupConfig struct {
+	apiKey string
+	tls    tlsOptions
+
+	pageSize, loadMoreSize, displayLimit int
+	maxPages                             int
+	collapseSimilar                      float64
+	smartHalfLife                        time.Duration
+	cardFields, timezone                 string
+	headlinesCountry                     string
+	assetsOverride                       string
+	minQueryLength                       int
+	breakingWindow                       time.Duration
+
+	cacheBackend       string
+	redisURL           string
+	cacheSize          int
+	staleCacheSize     int
+	cacheTTL, staleTTL time.Duration
+	retryAttempts      int
+	retryBudget        time.Duration
+	breakerFailures    int
+	beacon             bool
+	beaconRate         int
+	inlineImagesRate   int
+	maxHeaderBytes     int
+	maxBodyBytes       int64
+	readHeaderTimeout  time.Duration
+	readTimeout        time.Duration
+	writeTimeout       time.Duration
+	idleTimeout        time.Duration
+}
+
+// validateConfig checks the settings make sense on their own and together, returning all the
+// problems joined into one error, nil when there are none. It mostly looks at the values, files
+// are read when they are used, but with -cache-backend redis it checks Redis answers: a cache that
+// is down at startup is a typo in -redis-url more often than an outage.
+func validateConfig(c startupConfig) error {
+	var problems []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.apiKey != "", "apiKey must be set")
+	if err := c.tls.validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	check(c.pageSize >= minPageSize && c.pageSize <= maxPageSize, "page-size must be between %d and %d", minPageSize, maxPageSize)
+	check(c.loadMoreSize >= minPageSize && c.loadMoreSize <= maxPageSize, "load-more-size must be between %d and %d", minPageSize, maxPageSize)
+	check(c.displayLimit >= 0, "display-limit can't be negative")
+	check(c.maxPages >= 0, "max-pages can't be negative")
+	check(c.collapseSimilar >= 0 && c.collapseSimilar <= 1, "collapse-similar must be between 0 and 1")
+	check(c.smartHalfLife > 0, "smart-half-life must be positive")
+	if _, err := parseCardFields(c.cardFields); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := time.LoadLocation(c.timezone); err != nil {
+		problems = append(problems, fmt.Errorf("timezone: %v", err))
+	}
+	if _, ok := metaName(newsapiCountries, c.headlinesCountry); c.headlinesCountry != "" && !ok {
+		problems = append(problems, fmt.Errorf("headlines-country %q is not a newsapi country", c.headlinesCountry))
+	}
+	check(c.minQueryLength >= 0 && c.minQueryLength <= maxQueryLength, "min-query-length must be between 0 and %d", maxQueryLength)
+	check(c.breakingWindow > 0 && c.breakingWindow <= maxBreakingWindow, "breaking-window must be positive and at most %s", maxBreakingWindow)
+	if c.assetsOverride != "" {
+		info, err := os.Stat(c.assetsOverride)
+		check(err == nil && info.IsDir(), "assets-override must be a directory: %s", c.assetsOverride)
+	}
+
+	check(c.cacheBackend == "memory" || c.cacheBackend == "redis", "cache-backend must be memory or redis, not %q", c.cacheBackend)
+	if c.cacheBackend == "redis" {
+		if err := pingRedis(c.redisURL); err != nil {
+			problems = append(problems, fmt.Errorf("cache-backend redis: can't reach %s: %v", redactURL(c.redisURL), err))
+		}
+	}
+	check(c.cacheSize >= 1, "cache-size must be at least 1")
+	check(c.staleCacheSize >= 1, "stale-cache-size must be at least 1")
+	check(c.cacheTTL >= 0 && c.staleTTL >= 0, "cache-ttl and stale-ttl can't be negative")
+	check(c.retryAttempts >= 1, "retry-attempts must be at least 1")
+	check(c.retryBudget >= 0 && c.retryBudget < c.writeTimeout, "retry-budget must be 0 or more, and less than write-timeout so answers still get written")
+	check(c.breakerFailures >= 0, "breaker-failures can't be negative")
+	check(!c.beacon || c.beaconRate >= 1, "beacon-rate must be at least 1")
+	check(c.inlineImagesRate >= 0, "inline-images-rate can't be negative")
+
+	check(c.maxHeaderBytes > 0 && c.maxBodyBytes > 0, "max-header-bytes and max-body-bytes must be positive")
+	check(c.readHeaderTimeout > 0 && c.readTimeout > 0 && c.writeTimeout > 0 && c.idleTimeout > 0,
+		"read-header-timeout, read-timeout, write-timeout and idle-timeout must be positive")
+
+	return errors.Join(problems...)
+}
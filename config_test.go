@@ -0,0 +1,162 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig is what main passes validateConfig for a server started with only -apikey
+func validConfig() startupConfig {
+	return startupConfig{
+		apiKey:            "test-key",
+		pageSize:          20,
+		loadMoreSize:      10,
+		smartHalfLife:     6 * time.Hour,
+		cardFields:        defaultCardFields,
+		timezone:          "UTC",
+		headlinesCountry:  "us",
+		minQueryLength:    2,
+		breakingWindow:    time.Hour,
+		cacheBackend:      "memory",
+		cacheSize:         1000,
+		staleCacheSize:    1000,
+		cacheTTL:          5 * time.Minute,
+		staleTTL:          24 * time.Hour,
+		retryAttempts:     2,
+		retryBudget:       10 * time.Second,
+		breakerFailures:   5,
+		beaconRate:        60,
+		inlineImagesRate:  10,
+		maxHeaderBytes:    64 << 10,
+		maxBodyBytes:      64 << 10,
+		readHeaderTimeout: 5 * time.Second,
+		readTimeout:       15 * time.Second,
+		writeTimeout:      60 * time.Second,
+		idleTimeout:       120 * time.Second,
+	}
+}
+
+// configTest changes validConfig and expects validateConfig to report an error mentioning
+// wantErr, or none when it is empty
+type configTest struct {
+	name    string
+	change  func(c *startupConfig)
+	wantErr string
+}
+
+func runConfigTests(t *testing.T, tests []configTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.change(&c)
+			err := validateConfig(c)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateConfig: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateConfig error = %v, want one about %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigTimeouts(t *testing.T) {
+	runConfigTests(t, []configTest{
+		{name: "defaults", change: func(c *startupConfig) {}},
+		{name: "no read header timeout", change: func(c *startupConfig) { c.readHeaderTimeout = 0 }, wantErr: "read-header-timeout"},
+		{name: "negative read timeout", change: func(c *startupConfig) { c.readTimeout = -time.Second }, wantErr: "read-timeout"},
+		{name: "no write timeout", change: func(c *startupConfig) { c.writeTimeout = 0 }, wantErr: "write-timeout"},
+		{name: "no idle timeout", change: func(c *startupConfig) { c.idleTimeout = 0 }, wantErr: "idle-timeout"},
+	})
+}
+
+func TestValidateConfigDisplayLimit(t *testing.T) {
+	runConfigTests(t, []configTest{
+		{name: "a limit", change: func(c *startupConfig) { c.displayLimit = 5 }},
+		{name: "negative", change: func(c *startupConfig) { c.displayLimit = -1 }, wantErr: "display-limit"},
+	})
+}
+
+func TestValidateConfigCacheBackend(t *testing.T) {
+	runConfigTests(t, []configTest{
+		{name: "memory", change: func(c *startupConfig) { c.cacheBackend = "memory" }},
+		{name: "unknown", change: func(c *startupConfig) { c.cacheBackend = "memcached" }, wantErr: `cache-backend must be memory or redis, not "memcached"`},
+		{name: "not a redis url", change: func(c *startupConfig) { c.cacheBackend, c.redisURL = "redis", "http://localhost:6379" }, wantErr: "cache-backend redis"},
+	})
+}
+
+func TestValidateConfigCollapseSimilar(t *testing.T) {
+	runConfigTests(t, []configTest{
+		{name: "off", change: func(c *startupConfig) { c.collapseSimilar = 0 }},
+		{name: "all alike", change: func(c *startupConfig) { c.collapseSimilar = 1 }},
+		{name: "negative", change: func(c *startupConfig) { c.collapseSimilar = -0.1 }, wantErr: "collapse-similar"},
+		{name: "over 1", change: func(c *startupConfig) { c.collapseSimilar = 1.5 }, wantErr: "collapse-similar"},
+	})
+}
+
+func TestValidateConfigRetry(t *testing.T) {
+	runConfigTests(t, []configTest{
+		{name: "no retries", change: func(c *startupConfig) { c.retryAttempts = 1 }},
+		{name: "no budget", change: func(c *startupConfig) { c.retryBudget = 0 }},
+		{name: "no attempts", change: func(c *startupConfig) { c.retryAttempts = 0 }, wantErr: "retry-attempts"},
+		{name: "negative budget", change: func(c *startupConfig) { c.retryBudget = -time.Second }, wantErr: "retry-budget"},
+		{name: "budget past the write timeout", change: func(c *startupConfig) { c.retryBudget = c.writeTimeout }, wantErr: "retry-budget"},
+	})
+}
+
+func TestValidateConfigRanges(t *testing.T) {
+	runConfigTests(t, []configTest{
+		{name: "no minimum query length", change: func(c *startupConfig) { c.minQueryLength = 0 }},
+		{name: "longest minimum query length", change: func(c *startupConfig) { c.minQueryLength = maxQueryLength }},
+		{name: "negative minimum query length", change: func(c *startupConfig) { c.minQueryLength = -1 }, wantErr: "min-query-length"},
+		{name: "minimum past the longest query", change: func(c *startupConfig) { c.minQueryLength = maxQueryLength + 1 }, wantErr: "min-query-length"},
+		{name: "longest breaking window", change: func(c *startupConfig) { c.breakingWindow = maxBreakingWindow }},
+		{name: "no breaking window", change: func(c *startupConfig) { c.breakingWindow = 0 }, wantErr: "breaking-window"},
+		{name: "breaking window too long", change: func(c *startupConfig) { c.breakingWindow = maxBreakingWindow + time.Hour }, wantErr: "breaking-window"},
+		{name: "page size too big", change: func(c *startupConfig) { c.pageSize = maxPageSize + 1 }, wantErr: "page-size"},
+		{name: "no load more size", change: func(c *startupConfig) { c.loadMoreSize = 0 }, wantErr: "load-more-size"},
+		{name: "unknown card field", change: func(c *startupConfig) { c.cardFields = "title,colour" }, wantErr: "colour"},
+		{name: "unknown timezone", change: func(c *startupConfig) { c.timezone = "Mars/Olympus" }, wantErr: "timezone"},
+		{name: "unknown headlines country", change: func(c *startupConfig) { c.headlinesCountry = "zz" }, wantErr: "headlines-country"},
+		{name: "no headlines country", change: func(c *startupConfig) { c.headlinesCountry = "" }},
+		{name: "no smart half life", change: func(c *startupConfig) { c.smartHalfLife = 0 }, wantErr: "smart-half-life"},
+		{name: "negative max pages", change: func(c *startupConfig) { c.maxPages = -1 }, wantErr: "max-pages"},
+		{name: "no api key", change: func(c *startupConfig) { c.apiKey = "" }, wantErr: "apiKey"},
+	})
+}
+
+func TestValidateConfigRedisUnreachable(t *testing.T) {
+	c := validConfig()
+	c.cacheBackend, c.redisURL = "redis", "redis://:secret@"+closedAddr(t)+"/0"
+	err := validateConfig(c)
+	if err == nil || !strings.Contains(err.Error(), "cache-backend redis: can't reach") {
+		t.Fatalf("validateConfig error = %v, want one about reaching redis", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("validateConfig error = %v, shows the redis password", err)
+	}
+}
+
+func TestValidateConfigReportsEveryProblem(t *testing.T) {
+	c := validConfig()
+	c.apiKey = ""
+	c.pageSize = 0
+	c.idleTimeout = 0
+	err := validateConfig(c)
+	if err == nil {
+		t.Fatal("validateConfig accepted three problems")
+	}
+	for _, want := range []string{"apiKey", "page-size", "idle-timeout"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("validateConfig error = %v, missing %q", err, want)
+		}
+	}
+	if got := strings.Count(err.Error(), "\n") + 1; got != 3 {
+		t.Errorf("validateConfig reported %d problems, want 3: %v", got, err)
+	}
+}
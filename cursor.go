@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// cursorPrefix keeps cursors apart from the other signed tokens, a signed cookie can't pass for one
+const cursorPrefix = "cursor:"
+
+var errBadCursor = errors.New("invalid cursor")
+
+// cursorOptions are the params that may go along with a cursor, they shape the response
+// rather than the search
+var cursorOptions = map[string]bool{"cursor": true, "compact": true, "download": true, "inlineImages": true, "noContent": true}
+
+// encodeCursor signs the params of a search page into an opaque token
+func encodeCursor(params url.Values) string {
+	return sign([]byte(cursorPrefix + params.Encode()))
+}
+
+// decodeCursor returns the params a cursor was made from, rejecting anything we didn't sign
+func decodeCursor(cursor string) (url.Values, error) {
+	payload, err := verify(cursor)
+	if err != nil {
+		return nil, errBadCursor
+	}
+	raw, ok := strings.CutPrefix(string(payload), cursorPrefix)
+	if !ok {
+		return nil, errBadCursor
+	}
+	params, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, errBadCursor
+	}
+	return params, nil
+}
+
+// searchParams are the params of a JSON search: the request's own, or with a cursor the ones
+// it carries plus the response options. A cursor stands for the whole search, so it can't be
+// mixed with search params.
+func searchParams(params url.Values) (url.Values, error) {
+	cursor, err := singleParam(params, "cursor")
+	if err != nil || cursor == "" {
+		return params, err
+	}
+	for key := range params {
+		if !cursorOptions[key] {
+			return nil, fmt.Errorf("cursor can't be combined with %s", key)
+		}
+	}
+	decoded, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	for key := range cursorOptions {
+		if key != "cursor" && params.Has(key) {
+			decoded[key] = params[key]
+		}
+	}
+	return decoded, nil
+}
+
+// nextCursor is the cursor of the page after the one fetched, "" on the last page. It is
+// called after fetch, while NextPage is still the page that was fetched.
+func (s *Search) nextCursor() string {
+	if s.fetched == 0 || s.NextPage >= lastAvailablePage(s.Results.TotalResults, s.PageSize) {
+		return ""
+	}
+	v := s.linkParams()
+	v.Set("page", strconv.Itoa(s.NextPage+1))
+	return encodeCursor(v)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	params := url.Values{"q": {"go"}, "page": {"2"}}
+	cursor := encodeCursor(params)
+	payload, mac, _ := strings.Cut(cursor, ".")
+	tests := []struct {
+		name    string
+		cursor  string
+		key     string
+		wantErr bool
+	}{
+		{name: "as encoded", cursor: cursor},
+		{name: "tampered page", cursor: base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix+"page=3&q=go")) + "." + mac, wantErr: true},
+		{name: "tampered mac", cursor: payload + "." + strings.Repeat("A", len(mac)), wantErr: true},
+		{name: "other key", cursor: cursor, key: "another secret", wantErr: true},
+		{name: "signed token that isn't a cursor", cursor: sign([]byte("page=2&q=go")), wantErr: true},
+		{name: "garbage", cursor: "not-a-cursor", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.key != "" {
+				setVar(t, &signingKey, []byte(tt.key))
+			}
+			got, err := decodeCursor(tt.cursor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeCursor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Encode() != params.Encode() {
+				t.Errorf("decodeCursor() = %v, want %v", got, params)
+			}
+		})
+	}
+}
+
+func TestSearchParams(t *testing.T) {
+	cursor := url.QueryEscape(encodeCursor(url.Values{"q": {"go"}, "page": {"2"}}))
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{name: "no cursor", query: "q=go&page=3", want: "page=3&q=go"},
+		{name: "cursor", query: "cursor=" + cursor, want: "page=2&q=go"},
+		{name: "cursor with response options", query: "cursor=" + cursor + "&compact=1", want: "compact=1&page=2&q=go"},
+		{name: "cursor with search params", query: "cursor=" + cursor + "&q=rust", wantErr: true},
+		{name: "repeated cursor", query: "cursor=" + cursor + "&cursor=" + cursor, wantErr: true},
+		{name: "bad cursor", query: "cursor=abc.def", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, _ := url.ParseQuery(tt.query)
+			got, err := searchParams(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("searchParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Encode() != tt.want {
+				t.Errorf("searchParams() = %s, want %s", got.Encode(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchJSONCursorWalk(t *testing.T) {
+	useNewsAPI(t, newFakeNewsAPI(t, 45).Server)
+	target := "/search.json?q=go"
+	var firsts []string
+	for range 5 {
+		w := get(searchJSONHandler, target)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", target, w.Code, w.Body)
+		}
+		var results Results
+		if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+			t.Fatal(err)
+		}
+		firsts = append(firsts, results.Articles[0].Title)
+		if results.NextCursor == "" {
+			break
+		}
+		target = "/search.json?cursor=" + url.QueryEscape(results.NextCursor)
+	}
+	want := []string{"Story 1", "Story 21", "Story 41"}
+	if !slices.Equal(firsts, want) {
+		t.Errorf("pages started with %q, want %q", firsts, want)
+	}
+}
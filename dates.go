@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// datePreset is one of the quick date buttons, Days counts today, so 1 is just today
+type datePreset struct {
+	Name  string
+	Label string
+	Days  int
+}
+
+var datePresets = []datePreset{
+	{"today", "Today", 1},
+	{"3days", "Last 3 days", 3},
+	{"week", "Last week", 7},
+	{"month", "Last month", 30},
+}
+
+// datesForPreset is the from and to of the named preset: from the midnight that starts its first
+// day to the one that ends today, both as seen in now's location. The dates only change at
+// midnight, so the upstream query and its cache key stay the same all day.
+func datesForPreset(name string, now time.Time) (from, to time.Time, err error) {
+	for _, p := range datePresets {
+		if p.Name == name {
+			y, m, d := now.Date()
+			today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+			return today.AddDate(0, 0, 1-p.Days), today.AddDate(0, 0, 1), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("datePreset %q is not one of today, 3days, week or month", name)
+}
+
+// datePresetParam reads the optional datePreset param, which can't be combined with maxAgeHours
+func datePresetParam(params url.Values) (string, error) {
+	v, err := singleParam(params, "datePreset")
+	if err != nil || v == "" {
+		return "", err
+	}
+	if _, _, err := datesForPreset(v, time.Now()); err != nil {
+		return "", err
+	}
+	if params.Get("maxAgeHours") != "" {
+		return "", errors.New("datePreset and maxAgeHours can't both be given")
+	}
+	return v, nil
+}
+
+// presetDates are the dates of the search's preset in the -timezone, zero without one
+func (s *Search) presetDates() (from, to time.Time) {
+	if s.DatePreset == "" {
+		return time.Time{}, time.Time{}
+	}
+	from, to, _ = datesForPreset(s.DatePreset, time.Now().In(timelineLocation))
+	return from, to
+}
+
+// datePresetLink is a preset button of the results page, Active for the search's own preset
+type datePresetLink struct {
+	Label  string
+	URL    string
+	Active bool
+}
+
+// DatePresetLinks are the date preset buttons. Each one replaces the search's date filter from
+// its first page, the active one takes it off again.
+func (s *Search) DatePresetLinks() []datePresetLink {
+	links := make([]datePresetLink, len(datePresets))
+	for i, p := range datePresets {
+		v := s.linkParams()
+		v.Del("maxAgeHours")
+		v.Del("datePreset")
+		active := p.Name == s.DatePreset
+		if !active {
+			v.Set("datePreset", p.Name)
+		}
+		links[i] = datePresetLink{Label: p.Label, URL: "/search?" + v.Encode(), Active: active}
+	}
+	return links
+}
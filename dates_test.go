@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDatesForPreset(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name     string
+		preset   string
+		now      time.Time
+		wantFrom time.Time
+		wantTo   time.Time
+		wantErr  bool
+	}{
+		{name: "today", preset: "today", now: time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC),
+			wantFrom: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), wantTo: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "just after midnight", preset: "today", now: time.Date(2026, 10, 14, 0, 0, 1, 0, time.UTC),
+			wantFrom: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), wantTo: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "just before midnight", preset: "today", now: time.Date(2026, 10, 14, 23, 59, 59, 0, time.UTC),
+			wantFrom: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), wantTo: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "3 days counts today", preset: "3days", now: time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC),
+			wantFrom: time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), wantTo: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "month over a month end", preset: "month", now: time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC),
+			wantFrom: time.Date(2026, 2, 4, 0, 0, 0, 0, time.UTC), wantTo: time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{name: "local midnights", preset: "today", now: time.Date(2026, 10, 14, 2, 0, 0, 0, time.UTC).In(newYork),
+			wantFrom: time.Date(2026, 10, 13, 4, 0, 0, 0, time.UTC), wantTo: time.Date(2026, 10, 14, 4, 0, 0, 0, time.UTC)},
+		{name: "week over a DST change", preset: "week", now: time.Date(2026, 11, 3, 12, 0, 0, 0, newYork),
+			wantFrom: time.Date(2026, 10, 28, 4, 0, 0, 0, time.UTC), wantTo: time.Date(2026, 11, 4, 5, 0, 0, 0, time.UTC)},
+		{name: "unknown", preset: "year", now: time.Now(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := datesForPreset(tt.preset, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("datesForPreset(%q) error = %v, wantErr %v", tt.preset, err, tt.wantErr)
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("datesForPreset(%q) = %s to %s, want %s to %s", tt.preset, from.UTC(), to.UTC(), tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestDatePresetParam(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "", want: ""},
+		{query: "datePreset=week", want: "week"},
+		{query: "datePreset=today", want: "today"},
+		{query: "datePreset=year", wantErr: true},
+		{query: "datePreset=week&datePreset=today", wantErr: true},
+		{query: "datePreset=week&maxAgeHours=24", wantErr: true},
+		{query: "maxAgeHours=24", want: ""},
+	}
+	for _, tt := range tests {
+		params, _ := url.ParseQuery(tt.query)
+		got, err := datePresetParam(params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("datePresetParam(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("datePresetParam(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestDatePresetLinks(t *testing.T) {
+	tests := []struct {
+		name       string
+		search     Search
+		wantURLs   []string
+		wantActive string
+	}{
+		{name: "none active", search: Search{SearchKey: "go", PageSize: 20, NextPage: 3}, wantURLs: []string{
+			"/search?datePreset=today&q=go",
+			"/search?datePreset=3days&q=go",
+			"/search?datePreset=week&q=go",
+			"/search?datePreset=month&q=go",
+		}},
+		{name: "replaces maxAgeHours", search: Search{SearchKey: "go", PageSize: 20, MaxAgeHours: 24}, wantURLs: []string{
+			"/search?datePreset=today&q=go",
+			"/search?datePreset=3days&q=go",
+			"/search?datePreset=week&q=go",
+			"/search?datePreset=month&q=go",
+		}},
+		{name: "active one takes it off", search: Search{SearchKey: "go", PageSize: 20, DatePreset: "week"}, wantActive: "Last week", wantURLs: []string{
+			"/search?datePreset=today&q=go",
+			"/search?datePreset=3days&q=go",
+			"/search?q=go",
+			"/search?datePreset=month&q=go",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var urls []string
+			active := ""
+			for _, l := range tt.search.DatePresetLinks() {
+				urls = append(urls, l.URL)
+				if l.Active {
+					active = l.Label
+				}
+			}
+			if !slices.Equal(urls, tt.wantURLs) {
+				t.Errorf("DatePresetLinks() = %q, want %q", urls, tt.wantURLs)
+			}
+			if active != tt.wantActive {
+				t.Errorf("active preset = %q, want %q", active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestDatePresetSearch(t *testing.T) {
+	setVar(t, &timelineLocation, time.UTC)
+	var sent atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent.Store(r.URL.Query())
+		recent := testArticle(1)
+		recent.PublishedAt = Timestamp{time.Now()}
+		old := testArticle(2)
+		old.Title = "Old story"
+		old.PublishedAt = Timestamp{time.Now().AddDate(0, 0, -3)}
+		json.NewEncoder(w).Encode(Results{Status: "ok", TotalResults: 2, Articles: []Articles{recent, old}})
+	}))
+	t.Cleanup(srv.Close)
+	useNewsAPI(t, srv)
+
+	params, _ := url.ParseQuery("q=go&datePreset=today")
+	s, err := newSearch(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.fetch(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	from, to, _ := datesForPreset("today", time.Now().UTC())
+	upstream := sent.Load().(url.Values)
+	if got, want := upstream.Get("from"), from.Format(time.RFC3339); got != want {
+		t.Errorf("from = %s, want %s", got, want)
+	}
+	if got, want := upstream.Get("to"), to.Format(time.RFC3339); got != want {
+		t.Errorf("to = %s, want %s", got, want)
+	}
+	// articles from before it that newsapi sends anyway are dropped
+	if got := titles(s.Results.Articles); !slices.Equal(got, []string{"Story 1"}) {
+		t.Errorf("articles = %q, want only today's", got)
+	}
+}
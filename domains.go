@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// appendListParam returns list with value added unless it is already there, ignoring case.
+// It returns a new slice and leaves list untouched, so it is safe on a Search's fields.
+func appendListParam(list []string, value string) []string {
+	value = strings.TrimSpace(value)
+	out := append([]string(nil), list...)
+	if value == "" {
+		return out
+	}
+	for _, v := range out {
+		if strings.EqualFold(v, value) {
+			return out
+		}
+	}
+	return append(out, value)
+}
+
+// listParam reads a comma separated param, newsapi's own format for lists, lowercased and
+// without duplicates
+func listParam(params url.Values, key string) ([]string, error) {
+	raw, err := singleParam(params, key)
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	for _, v := range strings.Split(raw, ",") {
+		list = appendListParam(list, strings.ToLower(v))
+	}
+	return list, nil
+}
+
+// excludeDomainsParam reads the comma separated excludeDomains param
+func excludeDomainsParam(params url.Values) ([]string, error) {
+	return listParam(params, "excludeDomains")
+}
+
+// addToListURL is the current search with value added to the list param key, which holds list,
+// back on the first page. Hiding a source and the source quick filters both build links with it.
+func (s *Search) addToListURL(key string, list []string, value string) string {
+	v := s.linkParams()
+	v.Set(key, strings.Join(appendListParam(list, value), ","))
+	return "/search?" + v.Encode()
+}
+
+// removeFromListURL is the current search with value dropped from the list param key
+func (s *Search) removeFromListURL(key string, list []string, value string) string {
+	var kept []string
+	for _, item := range list {
+		if item != value {
+			kept = append(kept, item)
+		}
+	}
+	v := s.linkParams()
+	v.Del(key)
+	if len(kept) > 0 {
+		v.Set(key, strings.Join(kept, ","))
+	}
+	return "/search?" + v.Encode()
+}
+
+// articleDomain is the host an article was published on, without a leading www.
+func articleDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// HideSourceURL is the current search with the article's domain excluded, back on the first page
+func (s *Search) HideSourceURL(a Articles) string {
+	domain := articleDomain(a.URL)
+	if domain == "" {
+		return ""
+	}
+	return s.addToListURL("excludeDomains", s.ExcludeDomains, domain)
+}
+
+// ShowSourceURL is the current search with domain no longer excluded, for the chip's remove link
+func (s *Search) ShowSourceURL(domain string) string {
+	return s.removeFromListURL("excludeDomains", s.ExcludeDomains, domain)
+}
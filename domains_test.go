@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestAppendListParam(t *testing.T) {
+	tests := []struct {
+		name  string
+		list  []string
+		value string
+		want  []string
+	}{
+		{name: "empty list", list: nil, value: "bbc.co.uk", want: []string{"bbc.co.uk"}},
+		{name: "added at the end", list: []string{"cnn.com"}, value: "bbc.co.uk", want: []string{"cnn.com", "bbc.co.uk"}},
+		{name: "already there", list: []string{"cnn.com"}, value: "CNN.com", want: []string{"cnn.com"}},
+		{name: "blank", list: []string{"cnn.com"}, value: "  ", want: []string{"cnn.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := slices.Clone(tt.list)
+			if got := appendListParam(tt.list, tt.value); !slices.Equal(got, tt.want) {
+				t.Errorf("appendListParam = %q, want %q", got, tt.want)
+			}
+			if !slices.Equal(tt.list, before) {
+				t.Errorf("appendListParam changed its input to %q", tt.list)
+			}
+		})
+	}
+}
+
+func TestExcludeDomainsParam(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []string
+		wantErr bool
+	}{
+		{query: "", want: nil},
+		{query: "excludeDomains=cnn.com", want: []string{"cnn.com"}},
+		{query: "excludeDomains=CNN.com,+bbc.co.uk,,cnn.com", want: []string{"cnn.com", "bbc.co.uk"}},
+		{query: "excludeDomains=cnn.com&excludeDomains=bbc.co.uk", wantErr: true},
+	}
+	for _, tt := range tests {
+		params, _ := url.ParseQuery(tt.query)
+		got, err := excludeDomainsParam(params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("excludeDomainsParam(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("excludeDomainsParam(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestArticleDomain(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://www.BBC.co.uk/news/1", want: "bbc.co.uk"},
+		{url: "https://edition.cnn.com:443/x", want: "edition.cnn.com"},
+		{url: "not a url", want: ""},
+		{url: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := articleDomain(tt.url); got != tt.want {
+			t.Errorf("articleDomain(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestHideAndShowSourceURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		excluded []string
+		article  string
+		wantHide string
+		show     string
+		wantShow string
+	}{
+		{
+			name:     "first hidden source",
+			article:  "https://www.cnn.com/a",
+			wantHide: "/search?excludeDomains=cnn.com&q=go",
+		},
+		{
+			name:     "another source",
+			excluded: []string{"cnn.com"},
+			article:  "https://bbc.co.uk/a",
+			wantHide: "/search?excludeDomains=cnn.com%2Cbbc.co.uk&q=go",
+			show:     "cnn.com",
+			wantShow: "/search?q=go",
+		},
+		{
+			name:     "no domain",
+			excluded: []string{"cnn.com", "bbc.co.uk"},
+			article:  "",
+			wantHide: "",
+			show:     "cnn.com",
+			wantShow: "/search?excludeDomains=bbc.co.uk&q=go",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Search{SearchKey: "go", PageSize: 20, NextPage: 3, ExcludeDomains: tt.excluded}
+			if got := s.HideSourceURL(Articles{URL: tt.article}); got != tt.wantHide {
+				t.Errorf("HideSourceURL = %q, want %q", got, tt.wantHide)
+			}
+			if tt.show == "" {
+				return
+			}
+			if got := s.ShowSourceURL(tt.show); got != tt.wantShow {
+				t.Errorf("ShowSourceURL(%q) = %q, want %q", tt.show, got, tt.wantShow)
+			}
+		})
+	}
+}
+
+func TestExcludeDomainsSentUpstream(t *testing.T) {
+	s, err := newSearch(url.Values{"q": {"go"}, "excludeDomains": {"cnn.com,bbc.co.uk"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.everythingParams().Get("excludeDomains"); got != "cnn.com,bbc.co.uk" {
+		t.Errorf("excludeDomains sent = %q, want cnn.com,bbc.co.uk", got)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// collapseSimilar is how alike two titles must be, from 0 to 1, for the articles to be shown as
+// one story; 0 keeps them all. Set by -collapse-similar.
+var collapseSimilar *float64
+
+// titleTokens are the words that identify a story in a title: without the " - Source" newsapi
+// often appends, punctuation, case and stopwords
+func titleTokens(a Articles) map[string]bool {
+	title := cleanText(a.Title)
+	if i := strings.LastIndex(title, " - "); i >= 0 && strings.EqualFold(strings.TrimSpace(title[i+3:]), a.Source.Name) {
+		title = title[:i]
+	}
+	tokens := map[string]bool{}
+	for _, w := range words(title) {
+		if !stopwords[w] {
+			tokens[w] = true
+		}
+	}
+	return tokens
+}
+
+// jaccard is the share of the words in either set that are in both, 0 when both are empty
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// collapseNearDuplicates keeps the first article of each story, where titles whose words are at
+// least threshold alike (see jaccard) count as one story, and lists the sources of the dropped
+// ones in its AlsoReportedBy. Articles without a valid URL are kept as they are, never in place
+// of one that has. Every pair is compared, so it is opt-in. It returns a new slice and leaves
+// articles untouched.
+func collapseNearDuplicates(articles []Articles, threshold float64) []Articles {
+	if threshold <= 0 {
+		return articles
+	}
+	kept := make([]Articles, 0, len(articles))
+	var keptTokens []map[string]bool
+	var keptIndex []int
+	for _, a := range articles {
+		if a.InvalidURL {
+			kept = append(kept, a)
+			continue
+		}
+		tokens := titleTokens(a)
+		story := -1
+		for i, t := range keptTokens {
+			if jaccard(tokens, t) >= threshold {
+				story = keptIndex[i]
+				break
+			}
+		}
+		if story < 0 {
+			keptTokens = append(keptTokens, tokens)
+			keptIndex = append(keptIndex, len(kept))
+			kept = append(kept, a)
+			continue
+		}
+		first := &kept[story]
+		if name := a.Source.Name; name != "" && !strings.EqualFold(name, first.Source.Name) {
+			first.AlsoReportedBy = appendListParam(first.AlsoReportedBy, name)
+		}
+	}
+	return kept
+}
+
+// AlsoReported is the card's note on the collapsed duplicates, "" when there are none
+func (a *Articles) AlsoReported() string {
+	switch n := len(a.AlsoReportedBy); n {
+	case 0:
+		return ""
+	case 1:
+		return "Also reported by 1 other source"
+	default:
+		return fmt.Sprintf("Also reported by %d other sources", n)
+	}
+}
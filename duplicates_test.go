@@ -0,0 +1,118 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestTitleTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		article Articles
+		want    []string
+	}{
+		{name: "source suffix dropped", article: Articles{Title: "Markets Rally Strongly - Example News", Source: Source{Name: "Example News"}}, want: []string{"markets", "rally", "strongly"}},
+		{name: "other suffix kept", article: Articles{Title: "Markets rally - analysis", Source: Source{Name: "Example News"}}, want: []string{"analysis", "markets", "rally"}},
+		{name: "stopwords and markup", article: Articles{Title: "<b>The</b> markets and the rally"}, want: []string{"markets", "rally"}},
+		{name: "empty", article: Articles{}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slices.Sorted(maps.Keys(titleTokens(tt.article)))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("titleTokens() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	set := func(words ...string) map[string]bool {
+		m := map[string]bool{}
+		for _, w := range words {
+			m[w] = true
+		}
+		return m
+	}
+	tests := []struct {
+		name string
+		a, b map[string]bool
+		want float64
+	}{
+		{name: "same", a: set("a", "b"), b: set("a", "b"), want: 1},
+		{name: "half", a: set("a", "b", "c"), b: set("b", "c", "d"), want: 0.5},
+		{name: "disjoint", a: set("a"), b: set("b"), want: 0},
+		{name: "empty", a: set(), b: set(), want: 0},
+	}
+	for _, tt := range tests {
+		if got := jaccard(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: jaccard() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCollapseNearDuplicates(t *testing.T) {
+	article := func(title, source string) Articles {
+		return Articles{Title: title, Source: Source{Name: source}, URL: "https://news.example.com/" + source}
+	}
+	articles := []Articles{
+		article("Central bank raises interest rates again - Daily", "Daily"),
+		article("Football final ends in a draw", "Sport"),
+		article("Central bank raises interest rates again", "Wire"),
+		article("Central Bank raises interest rates, again", "Daily"),
+		{Title: "Central bank raises interest rates again", InvalidURL: true},
+		article("Central bank raises rates again", "Post"),
+	}
+	tests := []struct {
+		name      string
+		threshold float64
+		want      []string
+		wantAlso  []string
+	}{
+		{name: "off", threshold: 0, want: titles(articles)},
+		{
+			name:      "exact words",
+			threshold: 1,
+			want:      []string{articles[0].Title, articles[1].Title, articles[4].Title, articles[5].Title},
+			wantAlso:  []string{"Wire"},
+		},
+		{
+			name:      "near",
+			threshold: 0.6,
+			want:      []string{articles[0].Title, articles[1].Title, articles[4].Title},
+			wantAlso:  []string{"Wire", "Post"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collapseNearDuplicates(articles, tt.threshold)
+			if !slices.Equal(titles(got), tt.want) {
+				t.Errorf("kept %q, want %q", titles(got), tt.want)
+			}
+			if !slices.Equal(got[0].AlsoReportedBy, tt.wantAlso) {
+				t.Errorf("AlsoReportedBy = %q, want %q", got[0].AlsoReportedBy, tt.wantAlso)
+			}
+			if articles[0].AlsoReportedBy != nil {
+				t.Error("collapseNearDuplicates changed the given articles")
+			}
+		})
+	}
+}
+
+func TestAlsoReported(t *testing.T) {
+	tests := []struct {
+		also []string
+		want string
+	}{
+		{also: nil, want: ""},
+		{also: []string{"Wire"}, want: "Also reported by 1 other source"},
+		{also: []string{"Wire", "Post"}, want: "Also reported by 2 other sources"},
+	}
+	for _, tt := range tests {
+		a := Articles{AlsoReportedBy: tt.also}
+		if got := a.AlsoReported(); got != tt.want {
+			t.Errorf("AlsoReported() with %q = %q, want %q", tt.also, got, tt.want)
+		}
+	}
+}
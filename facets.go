@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// FacetCount is how many articles share one value of a field
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets counts the articles of a results page by source and author
+type Facets struct {
+	TotalResults int          `json:"totalResults"`
+	Sources      []FacetCount `json:"sources"`
+	Authors      []FacetCount `json:"authors"`
+}
+
+// computeFacets groups the articles by Source.Name and Author, most common first and ties
+// alphabetical. Articles without an author aren't counted in that facet.
+func computeFacets(results Results) Facets {
+	sources := map[string]int{}
+	authors := map[string]int{}
+	for _, a := range results.Articles {
+		if a.Source.Name != "" {
+			sources[a.Source.Name]++
+		}
+		if a.Author != "" {
+			authors[a.Author]++
+		}
+	}
+	return Facets{
+		TotalResults: results.TotalResults,
+		Sources:      sortedFacets(sources),
+		Authors:      sortedFacets(authors),
+	}
+}
+
+func sortedFacets(counts map[string]int) []FacetCount {
+	facets := make([]FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facets = append(facets, FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+	return facets
+}
+
+// facetsHandler returns the facet counts for the same page /search would show, going through the
+// NewsClient cache so facets next to a search don't cost another upstream call
+func facetsHandler(w http.ResponseWriter, r *http.Request) {
+	search, err := newSearch(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	search.withHeadlines = true
+	if answerHead(w, r, "application/json") {
+		return
+	}
+
+	if err := search.fetch(r.Context()); err != nil {
+		if clientGone(r, err) {
+			return
+		}
+		writeJSONFetchError(w, err)
+		return
+	}
+
+	setCacheControl(w, "public", search.Results)
+	if notModified(w, r, search.Results) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(computeFacets(search.Results)); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestComputeFacets(t *testing.T) {
+	article := func(source, author string) Articles {
+		return Articles{Source: Source{Name: source}, Author: author}
+	}
+	tests := []struct {
+		name    string
+		results Results
+		want    Facets
+	}{
+		{
+			name:    "empty",
+			results: Results{},
+			want:    Facets{Sources: []FacetCount{}, Authors: []FacetCount{}},
+		},
+		{
+			name: "most common first, ties alphabetical",
+			results: Results{TotalResults: 120, Articles: []Articles{
+				article("Wire", "Ann"), article("BBC", "Bob"), article("Wire", "Ann"), article("Alpha", ""),
+			}},
+			want: Facets{
+				TotalResults: 120,
+				Sources:      []FacetCount{{"Wire", 2}, {"Alpha", 1}, {"BBC", 1}},
+				Authors:      []FacetCount{{"Ann", 2}, {"Bob", 1}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := computeFacets(tt.results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("computeFacets() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFacetsHandlerUsesTheCache(t *testing.T) {
+	api := newFakeNewsAPI(t, 30)
+	useNewsAPI(t, api.Server)
+	for i, target := range []string{"/facets?q=go", "/facets?q=go"} {
+		w := get(facetsHandler, target)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d: %s", i, w.Code, w.Body)
+		}
+		var facets Facets
+		if err := json.Unmarshal(w.Body.Bytes(), &facets); err != nil {
+			t.Fatal(err)
+		}
+		if facets.TotalResults != 30 || len(facets.Sources) != 1 || facets.Sources[0].Count != 20 {
+			t.Errorf("request %d: facets = %+v, want 20 articles of one source", i, facets)
+		}
+	}
+	if got := api.hits.Load(); got != 1 {
+		t.Errorf("newsapi got %d requests, want 1", got)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// defaultFavicon is shown for articles whose domain can't be worked out
+const defaultFavicon = "/assets/favicon-default.svg"
+
+// faviconService is a URL template with a {domain} placeholder, e.g.
+// https://icons.duckduckgo.com/ip3/{domain}.ico, set from -favicon-service.
+// When empty the site's own /favicon.ico is used.
+var faviconService *string
+
+// FaviconURL is the icon for the article's source site, or defaultFavicon when it can't be built
+func (a *Articles) FaviconURL() string {
+	u, err := url.Parse(a.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return defaultFavicon
+	}
+
+	var icon string
+	if *faviconService != "" {
+		icon = strings.ReplaceAll(*faviconService, "{domain}", url.PathEscape(articleDomain(a.URL)))
+	} else {
+		icon = u.Scheme + "://" + u.Host + "/favicon.ico"
+	}
+
+	// whatever the template produced has to be a plain absolute web URL to end up in an img tag,
+	// from a host the Content-Security-Policy lets it load from
+	parsed, err := url.Parse(icon)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return defaultFavicon
+	}
+	if !isAllowedImageHost(parsed.Hostname(), imageHosts) {
+		return defaultFavicon
+	}
+	return parsed.String()
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestFaviconURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		service    string
+		imageHosts []string
+		url        string
+		want       string
+	}{
+		{name: "site favicon", url: "https://www.bbc.co.uk/news/1", want: "https://www.bbc.co.uk/favicon.ico"},
+		{name: "port kept", url: "http://news.example.com:8080/a", want: "http://news.example.com:8080/favicon.ico"},
+		{
+			name:    "service template",
+			service: "https://icons.duckduckgo.com/ip3/{domain}.ico",
+			url:     "https://www.bbc.co.uk/news/1",
+			want:    "https://icons.duckduckgo.com/ip3/bbc.co.uk.ico",
+		},
+		{name: "not a web url", url: "javascript:alert(1)", want: defaultFavicon},
+		{name: "no host", url: "https:///a", want: defaultFavicon},
+		{name: "empty", url: "", want: defaultFavicon},
+		{name: "template without a scheme", service: "icons.example.com/{domain}", url: "https://bbc.co.uk/", want: defaultFavicon},
+		{name: "template with a bad scheme", service: "data:image/png,{domain}", url: "https://bbc.co.uk/", want: defaultFavicon},
+		{
+			name:       "host outside the image allowlist",
+			imageHosts: []string{"images.example.com"},
+			url:        "https://bbc.co.uk/a",
+			want:       defaultFavicon,
+		},
+		{
+			name:       "service on the image allowlist",
+			service:    "https://icons.example.com/{domain}.ico",
+			imageHosts: []string{"example.com"},
+			url:        "https://bbc.co.uk/a",
+			want:       "https://icons.example.com/bbc.co.uk.ico",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &faviconService, tt.service)
+			setVar(t, &imageHosts, tt.imageHosts)
+			a := Articles{URL: tt.url}
+			if got := a.FaviconURL(); got != tt.want {
+				t.Errorf("FaviconURL() for %q = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// blockedWords are dropped from results by filterBlocked, set from -blocked-words and -blocklist-file
+var blockedWords []string
+
+// blockedPattern matches blockedWords and safeBlockedPattern those and the safe search terms,
+// compiled once by compileBlocklist rather than for every search
+var blockedPattern, safeBlockedPattern *regexp.Regexp
+
+// compileBlocklist compiles blockedWords and safeSearchTerms into the patterns filterBlocked is
+// given, main calls it once they are set from the flags
+func compileBlocklist() {
+	blockedPattern = wordPattern(blockedWords)
+	safeBlockedPattern = wordPattern(append(append([]string(nil), blockedWords...), safeSearchTerms...))
+}
+
+// filterBlocked removes articles whose title or description pattern matches, see wordPattern.
+// A nil pattern keeps them all. It returns a new slice and leaves articles untouched.
+func filterBlocked(articles []Articles, pattern *regexp.Regexp) []Articles {
+	if pattern == nil {
+		return articles
+	}
+
+	kept := make([]Articles, 0, len(articles))
+	for _, a := range articles {
+		if pattern.MatchString(cleanText(a.Title)) || pattern.MatchString(cleanText(a.Description)) {
+			continue
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
+
+// hasValidURL reports whether an article links somewhere we can send readers: an http(s) URL
+// with a host. newsapi now and then has articles with an empty or broken one.
+func hasValidURL(a Articles) bool {
+	_, err := validateRemoteURL(a.URL, nil)
+	return err == nil
+}
+
+// flagInvalidURLs marks the articles without a valid URL, for the JSON endpoints that keep them.
+// It returns a new slice and leaves articles untouched.
+func flagInvalidURLs(articles []Articles) []Articles {
+	flagged := make([]Articles, len(articles))
+	for i, a := range articles {
+		a.InvalidURL = !hasValidURL(a)
+		flagged[i] = a
+	}
+	return flagged
+}
+
+// withoutInvalidURLs drops the articles without a valid URL, pages can't link them.
+// It returns a new slice and leaves articles untouched.
+func withoutInvalidURLs(articles []Articles) []Articles {
+	kept := make([]Articles, 0, len(articles))
+	for _, a := range articles {
+		if hasValidURL(a) {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+// wordPattern matches any of words as a whole word or phrase, nil when there are none.
+// Matching ignores case and only hits whole words, so "ass" doesn't match "class".
+func wordPattern(words []string) *regexp.Regexp {
+	var alts []string
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			alts = append(alts, regexp.QuoteMeta(w))
+		}
+	}
+	if len(alts) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)(?:^|[^\pL\pN])(?:` + strings.Join(alts, "|") + `)(?:[^\pL\pN]|$)`)
+}
+
+// readWordList loads one word or phrase per line, skipping blank lines and # comments
+func readWordList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	return words, scanner.Err()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFilterBlocked(t *testing.T) {
+	articles := []Articles{
+		{Title: "a", Description: "A class on Go"},
+		{Title: "b", Description: "Crypto SCAM uncovered"},
+		{Title: "c <b>Celebrity gossip</b>"},
+		{Title: "d", Description: "scammers arrested"},
+	}
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{name: "no words", words: nil, want: []string{"a", "b", "c <b>Celebrity gossip</b>", "d"}},
+		{name: "whole words, any case", words: []string{"scam"}, want: []string{"a", "c <b>Celebrity gossip</b>", "d"}},
+		{name: "not inside other words", words: []string{"ass"}, want: []string{"a", "b", "c <b>Celebrity gossip</b>", "d"}},
+		{name: "phrase in the title markup", words: []string{"celebrity gossip"}, want: []string{"a", "b", "d"}},
+		{name: "blank words ignored", words: []string{" ", ""}, want: []string{"a", "b", "c <b>Celebrity gossip</b>", "d"}},
+		{name: "regexp characters are literal", words: []string{"c.*"}, want: []string{"a", "b", "c <b>Celebrity gossip</b>", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := titles(filterBlocked(articles, wordPattern(tt.words))); !slices.Equal(got, tt.want) {
+				t.Errorf("filterBlocked kept %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileBlocklist(t *testing.T) {
+	setBlocklist(t, []string{"scam"}, []string{"nsfw"})
+	tests := []struct {
+		name        string
+		safeSearch  bool
+		title       string
+		wantBlocked bool
+	}{
+		{name: "blocked word", title: "Crypto scam", wantBlocked: true},
+		{name: "safe search term, safe search off", title: "NSFW story"},
+		{name: "blocked word, safe search on", safeSearch: true, title: "Crypto scam", wantBlocked: true},
+		{name: "safe search term, safe search on", safeSearch: true, title: "NSFW story", wantBlocked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Search{SafeSearch: tt.safeSearch}
+			kept := filterBlocked([]Articles{{Title: tt.title}}, s.blockedFor())
+			if blocked := len(kept) == 0; blocked != tt.wantBlocked {
+				t.Errorf("%q blocked = %v, want %v", tt.title, blocked, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+func TestReadWordList(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{name: "one per line", content: "scam\ncelebrity gossip\n", want: []string{"scam", "celebrity gossip"}},
+		{name: "comments and blanks", content: "# blocked\n\n  scam  \n# more\n", want: []string{"scam"}},
+		{name: "empty", content: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "words.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readWordList(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("readWordList = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasValidURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://news.example.com/a/1", want: true},
+		{url: "http://news.example.com/a/1", want: true},
+		{url: "", want: false},
+		{url: "news.example.com/a/1", want: false},
+		{url: "javascript:alert(1)", want: false},
+		{url: "https://", want: false},
+		{url: "ftp://news.example.com/a/1", want: false},
+	}
+	for _, tt := range tests {
+		if got := hasValidURL(Articles{URL: tt.url}); got != tt.want {
+			t.Errorf("hasValidURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidURLFilters(t *testing.T) {
+	articles := []Articles{
+		{Title: "good", URL: "https://news.example.com/a/1"},
+		{Title: "empty", URL: ""},
+		{Title: "script", URL: "javascript:alert(1)"},
+	}
+	flagged := flagInvalidURLs(articles)
+	var invalid []string
+	for _, a := range flagged {
+		if a.InvalidURL {
+			invalid = append(invalid, a.Title)
+		}
+	}
+	if want := []string{"empty", "script"}; !slices.Equal(invalid, want) {
+		t.Errorf("flagInvalidURLs flagged %q, want %q", invalid, want)
+	}
+	if got := titles(withoutInvalidURLs(articles)); !slices.Equal(got, []string{"good"}) {
+		t.Errorf("withoutInvalidURLs kept %q, want [good]", got)
+	}
+	for _, a := range articles {
+		if a.InvalidURL {
+			t.Errorf("flagInvalidURLs changed the given article %q", a.Title)
+		}
+	}
+}
+
+func TestInvalidURLsInResponses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		broken := testArticle(2)
+		broken.URL = "javascript:alert(1)"
+		json.NewEncoder(w).Encode(Results{Status: "ok", TotalResults: 2, Articles: []Articles{testArticle(1), broken}})
+	}))
+	t.Cleanup(srv.Close)
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		target      string
+		wantBroken  bool
+		wantFlagged bool
+	}{
+		{name: "page leaves it out", handler: searchHandler, target: "/search?q=go"},
+		{name: "JSON flags it", handler: searchJSONHandler, target: "/search.json?q=go", wantBroken: true, wantFlagged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, srv)
+			body := get(tt.handler, tt.target).Body.String()
+			if !strings.Contains(body, "Story 1") {
+				t.Errorf("response lacks the article with a valid URL")
+			}
+			if got := strings.Contains(body, "Story 2"); got != tt.wantBroken {
+				t.Errorf("article without a valid URL included = %v, want %v", got, tt.wantBroken)
+			}
+			if got := strings.Contains(body, `"invalidUrl":true`); got != tt.wantFlagged {
+				t.Errorf("invalidUrl flag sent = %v, want %v", got, tt.wantFlagged)
+			}
+		})
+	}
+}
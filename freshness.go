@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// maxAgeLimit is the largest maxAgeHours accepted, newsapi's free plan only searches the last month anyway
+const maxAgeLimit = 30 * 24
+
+// maxAgeChoice is an option of the search form's freshness select
+type maxAgeChoice struct {
+	Hours int
+	Label string
+}
+
+var maxAgeChoices = []maxAgeChoice{
+	{1, "Past hour"},
+	{6, "Past 6 hours"},
+	{24, "Past day"},
+	{72, "Past 3 days"},
+	{168, "Past week"},
+}
+
+// maxAgeParam reads the optional maxAgeHours param, 0 when it isn't given
+func maxAgeParam(params url.Values) (int, error) {
+	v, err := singleParam(params, "maxAgeHours")
+	if err != nil || v == "" {
+		return 0, err
+	}
+	hours, err := strconv.Atoi(v)
+	if err != nil || hours < 1 || hours > maxAgeLimit {
+		return 0, fmt.Errorf("maxAgeHours must be between 1 and %d", maxAgeLimit)
+	}
+	return hours, nil
+}
+
+// fromTimestamp is the from param for articles at most maxAge old. It is rounded down to the
+// hour so the upstream query, and with it the cache key, stays the same for an hour; the
+// post-fetch filterFresh applies the exact cutoff.
+func fromTimestamp(now time.Time, maxAge time.Duration) string {
+	return now.Add(-maxAge).UTC().Truncate(time.Hour).Format(time.RFC3339)
+}
+
+// filterFresh keeps the articles published at or after cutoff, those without a date are dropped.
+// It returns a new slice and leaves articles untouched.
+func filterFresh(articles []Articles, cutoff time.Time) []Articles {
+	kept := make([]Articles, 0, len(articles))
+	for _, a := range articles {
+		if !a.PublishedAt.IsZero() && !a.PublishedAt.Before(cutoff) {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+// MaxAgeChoices lists the maxAgeHours options for the search form
+func (s *Search) MaxAgeChoices() []maxAgeChoice {
+	return maxAgeChoices
+}
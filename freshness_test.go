@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMaxAgeParam(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{query: "", want: 0},
+		{query: "maxAgeHours=24", want: 24},
+		{query: "maxAgeHours=720", want: 720},
+		{query: "maxAgeHours=0", wantErr: true},
+		{query: "maxAgeHours=721", wantErr: true},
+		{query: "maxAgeHours=a+day", wantErr: true},
+		{query: "maxAgeHours=1&maxAgeHours=2", wantErr: true},
+	}
+	for _, tt := range tests {
+		params, _ := url.ParseQuery(tt.query)
+		got, err := maxAgeParam(params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("maxAgeParam(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("maxAgeParam(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestFromTimestamp(t *testing.T) {
+	tests := []struct {
+		name   string
+		now    time.Time
+		maxAge time.Duration
+		want   string
+	}{
+		{name: "rounded down to the hour", now: time.Date(2026, 10, 14, 12, 40, 5, 0, time.UTC), maxAge: time.Hour, want: "2026-10-14T11:00:00Z"},
+		{name: "same key all hour", now: time.Date(2026, 10, 14, 12, 59, 59, 0, time.UTC), maxAge: time.Hour, want: "2026-10-14T11:00:00Z"},
+		{name: "in UTC", now: time.Date(2026, 10, 14, 12, 40, 0, 0, time.FixedZone("CEST", 2*60*60)), maxAge: 24 * time.Hour, want: "2026-10-13T10:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fromTimestamp(tt.now, tt.maxAge); got != tt.want {
+				t.Errorf("fromTimestamp() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterFresh(t *testing.T) {
+	cutoff := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	articles := []Articles{
+		{Title: "newer", PublishedAt: Timestamp{cutoff.Add(time.Minute)}},
+		{Title: "at the cutoff", PublishedAt: Timestamp{cutoff}},
+		{Title: "older", PublishedAt: Timestamp{cutoff.Add(-time.Minute)}},
+		{Title: "undated"},
+	}
+	want := []string{"newer", "at the cutoff"}
+	if got := titles(filterFresh(articles, cutoff)); !slices.Equal(got, want) {
+		t.Errorf("filterFresh kept %q, want %q", got, want)
+	}
+	if len(articles) != 4 || articles[2].Title != "older" {
+		t.Error("filterFresh changed its input")
+	}
+}
+
+func TestSearchMaxAge(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxAge   string
+		wantKept []string
+		wantGone []string
+	}{
+		{name: "past hour", maxAge: "1", wantKept: []string{"Story 1", "Story 59"}, wantGone: []string{"Story 61", "Story 80"}},
+		{name: "no limit", maxAge: "", wantKept: []string{"Story 1", "Story 59", "Story 61", "Story 80"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, newFakeNewsAPI(t, 80).Server)
+			params := url.Values{"q": {"go"}, "pageSize": {"80"}}
+			if tt.maxAge != "" {
+				params.Set("maxAgeHours", tt.maxAge)
+			}
+			s, err := newSearch(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from := s.everythingParams().Get("from"); (from != "") != (tt.maxAge != "") {
+				t.Errorf("from = %q sent upstream for maxAgeHours %q", from, tt.maxAge)
+			}
+			if err := s.fetch(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			got := titles(s.Results.Articles)
+			for _, title := range tt.wantKept {
+				if !slices.Contains(got, title) {
+					t.Errorf("%s was dropped", title)
+				}
+			}
+			for _, title := range tt.wantGone {
+				if slices.Contains(got, title) {
+					t.Errorf("%s is older than maxAgeHours but kept", title)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// cspImageSources replace the img-src sources worked out from imageHosts when set, from
+// -csp-img-src
+var cspImageSources []string
+
+// imageSources are the img-src sources for the image hosts allowlist: each host and its
+// subdomains over https, or any https origin when the list is empty, since favicons load
+// straight from the article sites
+func imageSources(allowlist []string) []string {
+	if len(allowlist) == 0 {
+		return []string{"https:"}
+	}
+	sources := make([]string, 0, 2*len(allowlist))
+	for _, host := range allowlist {
+		sources = append(sources, "https://"+host, "https://*."+host)
+	}
+	return sources
+}
+
+// contentSecurityPolicy allows our own assets and scripts only, plus images from the image hosts
+func contentSecurityPolicy() string {
+	img := []string{"'self'", "data:"}
+	if len(cspImageSources) > 0 {
+		img = append(img, cspImageSources...)
+	} else {
+		img = append(img, imageSources(imageHosts)...)
+	}
+	return strings.Join([]string{
+		"default-src 'self'",
+		"img-src " + strings.Join(img, " "),
+		"style-src 'self'",
+		"script-src 'self'",
+		"font-src 'self'",
+		"connect-src 'self'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+	}, "; ")
+}
+
+// withSecurityHeaders adds the security headers to HTML responses, JSON and assets don't need them
+func withSecurityHeaders(next http.Handler) http.Handler {
+	csp := contentSecurityPolicy()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&securityHeadersWriter{ResponseWriter: w, csp: csp}, r)
+	})
+}
+
+// securityHeadersWriter adds the headers just before the response starts, once its content type is known
+type securityHeadersWriter struct {
+	http.ResponseWriter
+	csp         string
+	wroteHeader bool
+}
+
+func (w *securityHeadersWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		h := w.Header()
+		if strings.HasPrefix(h.Get("Content-Type"), "text/html") {
+			h.Set("Content-Security-Policy", w.csp)
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+			h.Set("X-Frame-Options", "DENY")
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *securityHeadersWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		// what net/http would sniff anyway, we need it before the headers go out
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the Flusher the NDJSON export needs
+func (w *securityHeadersWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *securityHeadersWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
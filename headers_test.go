@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestImageSources(t *testing.T) {
+	tests := []struct {
+		allowlist []string
+		want      []string
+	}{
+		{allowlist: nil, want: []string{"https:"}},
+		{allowlist: []string{"images.example.com"}, want: []string{"https://images.example.com", "https://*.images.example.com"}},
+	}
+	for _, tt := range tests {
+		if got := imageSources(tt.allowlist); !slices.Equal(got, tt.want) {
+			t.Errorf("imageSources(%q) = %q, want %q", tt.allowlist, got, tt.want)
+		}
+	}
+}
+
+func TestContentSecurityPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		imageHosts []string
+		cspImages  []string
+		wantImgSrc string
+	}{
+		{name: "any https image", wantImgSrc: "img-src 'self' data: https:;"},
+		{name: "image hosts", imageHosts: []string{"cdn.example.com"}, wantImgSrc: "img-src 'self' data: https://cdn.example.com https://*.cdn.example.com;"},
+		{name: "several image hosts", imageHosts: []string{"cdn.example.com", "images.example.org"}, wantImgSrc: "img-src 'self' data: https://cdn.example.com https://*.cdn.example.com https://images.example.org https://*.images.example.org;"},
+		{name: "csp-img-src wins", imageHosts: []string{"cdn.example.com"}, cspImages: []string{"https://icons.example.com"}, wantImgSrc: "img-src 'self' data: https://icons.example.com;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &imageHosts, tt.imageHosts)
+			setVar(t, &cspImageSources, tt.cspImages)
+			csp := contentSecurityPolicy()
+			if !strings.Contains(csp, tt.wantImgSrc) {
+				t.Errorf("CSP %q lacks %q", csp, tt.wantImgSrc)
+			}
+			for _, directive := range []string{"default-src 'self'", "script-src 'self'", "frame-ancestors 'none'"} {
+				if !strings.Contains(csp, directive) {
+					t.Errorf("CSP %q lacks %q", csp, directive)
+				}
+			}
+		})
+	}
+}
+
+func TestWithSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		maintenance bool
+		wantCSP     bool
+	}{
+		{
+			name: "typed html",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Write([]byte("<p>hi"))
+			},
+			wantCSP: true,
+		},
+		{
+			name:    "sniffed html",
+			handler: func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<!DOCTYPE html><p>hi")) },
+			wantCSP: true,
+		},
+		{
+			name: "html error status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantCSP: true,
+		},
+		{
+			name: "json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte("{}"))
+			},
+		},
+		{
+			name:    "plain text error",
+			handler: func(w http.ResponseWriter, r *http.Request) { http.Error(w, "bad", http.StatusBadRequest) },
+		},
+		{name: "maintenance page", handler: withMaintenance(http.NotFoundHandler()).ServeHTTP, maintenance: true, wantCSP: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maintenance.Store(tt.maintenance)
+			t.Cleanup(func() { maintenance.Store(false) })
+			w := httptest.NewRecorder()
+			withSecurityHeaders(tt.handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search", nil))
+			h := w.Header()
+			if got := h.Get("Content-Security-Policy") != ""; got != tt.wantCSP {
+				t.Errorf("CSP set = %v, want %v (Content-Type %q)", got, tt.wantCSP, h.Get("Content-Type"))
+			}
+			if tt.wantCSP && (h.Get("X-Content-Type-Options") != "nosniff" || h.Get("X-Frame-Options") != "DENY" || h.Get("Referrer-Policy") == "") {
+				t.Errorf("security headers missing: %v", h)
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersWriterFlushes(t *testing.T) {
+	w := httptest.NewRecorder()
+	withSecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+	})).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search.ndjson", nil))
+	if !w.Flushed {
+		t.Error("the response wasn't flushed")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// headlinesPageSize is how many top headlines are merged into a first page, kept small as
+// every merged search costs a second request
+const headlinesPageSize = 5
+
+// mergeHeadlines turns on merging top headlines into first pages, set by -merge-headlines
+var mergeHeadlines *bool
+
+// headlinesCountry is the country top headlines are taken from, set by -headlines-country
+var headlinesCountry *string
+
+// mergeResults puts the headlines, marked as such, ahead of the other results and drops the
+// results that repeat one of them or each other, comparing URLs. The totals are the other
+// results', pagination follows them. Neither argument is modified.
+func mergeResults(headlines, results Results) Results {
+	merged := results
+	merged.Articles = make([]Articles, 0, len(headlines.Articles)+len(results.Articles))
+	seen := map[string]bool{}
+	add := func(a Articles) {
+		key := dedupeKey(a.URL)
+		if key != "" && seen[key] {
+			return
+		}
+		seen[key] = true
+		merged.Articles = append(merged.Articles, a)
+	}
+	for _, a := range headlines.Articles {
+		a.Headline = true
+		add(a)
+	}
+	for _, a := range results.Articles {
+		add(a)
+	}
+	return merged
+}
+
+// dedupeKey is the URL as compared for duplicates: no scheme, www., fragment or trailing slash,
+// in lower case. Unparseable URLs compare as they are.
+func dedupeKey(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return strings.ToLower(strings.TrimSpace(rawURL))
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	return host + strings.TrimSuffix(u.EscapedPath(), "/") + "?" + u.RawQuery
+}
+
+// mergesHeadlines reports whether this search gets top headlines merged in: only first pages of
+// the results page, so paging through results doesn't cost two requests a page and exports and
+// counts are newsapi's search alone
+func (s *Search) mergesHeadlines() bool {
+	return *mergeHeadlines && s.withHeadlines && s.NextPage == 1
+}
+
+// headlinesParams are the /v2/top-headlines params for the search. top-headlines can't combine
+// sources with a country, the search's sources win.
+func (s *Search) headlinesParams() url.Values {
+	v := url.Values{}
+	v.Set("q", s.upstreamQuery())
+	v.Set("page", "1")
+	v.Set("pageSize", strconv.Itoa(headlinesPageSize))
+	if len(s.Sources) > 0 {
+		v.Set("sources", strings.Join(s.Sources, ","))
+	} else if *headlinesCountry != "" {
+		v.Set("country", *headlinesCountry)
+	}
+	return v
+}
+
+// fetchHeadlines gets the search's top headlines, nil when there are none or they failed: the
+// headlines are a bonus, the search goes on without them. top-headlines has no excludeDomains,
+// so hidden sources are dropped here.
+func (s *Search) fetchHeadlines(ctx context.Context) *Results {
+	headlines, err := newsapi.TopHeadlines(ctx, s.headlinesParams())
+	if err != nil {
+		if !isCanceled(err) {
+			log.Printf("fetching top headlines: %v", err)
+		}
+		return nil
+	}
+	kept := *headlines
+	kept.Articles = nil
+	excluded := s.excludedFor()
+	for _, a := range headlines.Articles {
+		if !domainExcluded(articleDomain(a.URL), excluded) {
+			kept.Articles = append(kept.Articles, a)
+		}
+	}
+	return &kept
+}
+
+// domainExcluded matches domain against excluded like newsapi's excludeDomains: the domain
+// itself or any of its subdomains
+func domainExcluded(domain string, excluded []string) bool {
+	for _, d := range excluded {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDedupeKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{a: "https://www.news.example.com/a/1", b: "http://news.example.com/a/1/", same: true},
+		{a: "https://news.example.com/a/1#comments", b: "https://NEWS.example.com/a/1", same: true},
+		{a: "https://news.example.com/a/1?id=1", b: "https://news.example.com/a/1?id=2", same: false},
+		{a: "https://news.example.com/a/1", b: "https://news.example.com/a/2", same: false},
+		{a: " not a url ", b: "NOT A URL", same: true},
+	}
+	for _, tt := range tests {
+		if got := dedupeKey(tt.a) == dedupeKey(tt.b); got != tt.same {
+			t.Errorf("dedupeKey(%q) == dedupeKey(%q) is %v, want %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
+
+func TestMergeResults(t *testing.T) {
+	article := func(title, u string) Articles { return Articles{Title: title, URL: u} }
+	headlines := Results{TotalResults: 2, Articles: []Articles{
+		article("h1", "https://news.example.com/h1"),
+		article("h2", "https://news.example.com/shared"),
+	}}
+	results := Results{Status: "ok", TotalResults: 40, Articles: []Articles{
+		article("r1", "https://www.news.example.com/shared/"),
+		article("r2", "https://news.example.com/r2"),
+		article("r2 again", "https://news.example.com/r2#top"),
+		article("no url", ""),
+		article("no url either", ""),
+	}}
+	merged := mergeResults(headlines, results)
+
+	if want := []string{"h1", "h2", "r2", "no url", "no url either"}; !slices.Equal(titles(merged.Articles), want) {
+		t.Errorf("merged = %q, want %q", titles(merged.Articles), want)
+	}
+	for _, a := range merged.Articles {
+		if a.Headline != (a.Title == "h1" || a.Title == "h2") {
+			t.Errorf("%s marked as a headline = %v", a.Title, a.Headline)
+		}
+	}
+	if merged.TotalResults != 40 {
+		t.Errorf("TotalResults = %d, want the search's 40", merged.TotalResults)
+	}
+	if headlines.Articles[0].Headline || len(results.Articles) != 5 {
+		t.Error("mergeResults changed its arguments")
+	}
+}
+
+func TestHeadlinesParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		search  Search
+		country string
+		want    string
+	}{
+		{name: "country", search: Search{SearchKey: "go"}, country: "gb", want: "country=gb&page=1&pageSize=5&q=go"},
+		{name: "no country", search: Search{SearchKey: "go"}, country: "", want: "page=1&pageSize=5&q=go"},
+		{name: "sources win over the country", search: Search{SearchKey: "go", Sources: []string{"bbc-news", "reuters"}}, country: "gb", want: "page=1&pageSize=5&q=go&sources=bbc-news%2Creuters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &headlinesCountry, tt.country)
+			if got := tt.search.headlinesParams().Encode(); got != tt.want {
+				t.Errorf("headlinesParams() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDomainExcluded(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{domain: "example.com", want: true},
+		{domain: "news.example.com", want: true},
+		{domain: "notexample.com", want: false},
+		{domain: "example.org", want: false},
+	}
+	for _, tt := range tests {
+		if got := domainExcluded(tt.domain, []string{"example.com"}); got != tt.want {
+			t.Errorf("domainExcluded(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+}
+
+// newHeadlinesAPI serves two headlines, one from hidden.example.com, and one page of results,
+// counting the requests
+func newHeadlinesAPI(t *testing.T, headlinesStatus int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/v2/top-headlines" {
+			w.WriteHeader(headlinesStatus)
+			json.NewEncoder(w).Encode(Results{Status: "ok", TotalResults: 2, Articles: []Articles{
+				{Title: "Headline", URL: "https://news.example.com/h/1"},
+				{Title: "Hidden headline", URL: "https://hidden.example.com/h/2"},
+			}})
+			return
+		}
+		json.NewEncoder(w).Encode(Results{Status: "ok", TotalResults: 2, Articles: []Articles{testArticle(1), testArticle(2)}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestFetchMergesHeadlines(t *testing.T) {
+	tests := []struct {
+		name            string
+		merge           bool
+		query           string
+		headlinesStatus int
+		want            []string
+		wantRequests    int32
+	}{
+		{name: "off", merge: false, query: "q=go", headlinesStatus: http.StatusOK, want: []string{"Story 1", "Story 2"}, wantRequests: 1},
+		{name: "first page", merge: true, query: "q=go&excludeDomains=hidden.example.com", headlinesStatus: http.StatusOK, want: []string{"Headline", "Story 1", "Story 2"}, wantRequests: 2},
+		{name: "later pages", merge: true, query: "q=go&page=2", headlinesStatus: http.StatusOK, wantRequests: 1},
+		{name: "failed headlines", merge: true, query: "q=go", headlinesStatus: http.StatusInternalServerError, want: []string{"Story 1", "Story 2"}, wantRequests: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := newHeadlinesAPI(t, tt.headlinesStatus)
+			useNewsAPI(t, srv)
+			setFlag(t, &mergeHeadlines, tt.merge)
+			params, _ := url.ParseQuery(tt.query)
+			s, err := newSearch(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.withHeadlines = true
+			if err := s.fetch(t.Context()); err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != nil && !slices.Equal(titles(s.Results.Articles), tt.want) {
+				t.Errorf("articles = %q, want %q", titles(s.Results.Articles), tt.want)
+			}
+			if got := hits.Load(); got != tt.wantRequests {
+				t.Errorf("newsapi got %d requests, want %d", got, tt.wantRequests)
+			}
+			// the merged headlines don't count towards the page newsapi returned
+			if s.fetched != 2 {
+				t.Errorf("fetched = %d, want the 2 results", s.fetched)
+			}
+		})
+	}
+}
+
+func TestHeadlinesOnlyOnTheResultsPage(t *testing.T) {
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		target        string
+		wantHeadlines bool
+	}{
+		{name: "results page", handler: searchHandler, target: "/search?q=go", wantHeadlines: true},
+		{name: "load more batch", handler: moreHandler, target: "/search/more?q=go&offset=0", wantHeadlines: true},
+		{name: "json", handler: searchJSONHandler, target: "/search.json?q=go"},
+		{name: "ndjson", handler: searchNDJSONHandler, target: "/search.ndjson?q=go"},
+		{name: "count", handler: countHandler, target: "/count?q=go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := newHeadlinesAPI(t, http.StatusOK)
+			useNewsAPI(t, srv)
+			setFlag(t, &mergeHeadlines, true)
+			w := get(tt.handler, tt.target)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if got := strings.Contains(w.Body.String(), "Headline"); got != tt.wantHeadlines {
+				t.Errorf("response has the headline = %v, want %v", got, tt.wantHeadlines)
+			}
+			wantRequests := int32(1)
+			if tt.wantHeadlines {
+				wantRequests = 2
+			}
+			if got := hits.Load(); got != wantRequests {
+				t.Errorf("newsapi got %d requests, want %d", got, wantRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TermCount is how many times a search term was used within the trending window
+type TermCount struct {
+	Term  string
+	Count int
+}
+
+type historyEntry struct {
+	term string
+	at   time.Time
+}
+
+// searchHistory keeps the most recent searches in a fixed-size ring, so memory stays bounded
+type searchHistory struct {
+	mu      sync.Mutex
+	entries []historyEntry
+	next    int
+	full    bool
+}
+
+func newSearchHistory(capacity int) *searchHistory {
+	return &searchHistory{entries: make([]historyEntry, max(capacity, 1))}
+}
+
+// Record adds a search, overwriting the oldest one once the ring is full
+func (h *searchHistory) Record(term string, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries[h.next] = historyEntry{term: term, at: at}
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Trending counts the searches made since now-window and returns the limit most frequent,
+// ties broken alphabetically so the order is stable
+func (h *searchHistory) Trending(window time.Duration, now time.Time, limit int) []TermCount {
+	h.mu.Lock()
+	counts := map[string]int{}
+	n := h.next
+	if h.full {
+		n = len(h.entries)
+	}
+	since := now.Add(-window)
+	for _, e := range h.entries[:n] {
+		if e.at.After(since) {
+			counts[e.term]++
+		}
+	}
+	h.mu.Unlock()
+
+	terms := make([]TermCount, 0, len(counts))
+	for term, count := range counts {
+		terms = append(terms, TermCount{Term: term, Count: count})
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if len(terms) > limit {
+		terms = terms[:limit]
+	}
+	return terms
+}
+
+// maxTrendingTerms is how many terms the sidebar shows
+const maxTrendingTerms = 10
+
+var (
+	history = newSearchHistory(1000)
+	// trendingWindow is the rolling window TrendingTerms counts over
+	trendingWindow *time.Duration
+)
+
+// TrendingTerms returns the most searched terms over the -trending-window
+func TrendingTerms() []TermCount {
+	return history.Trending(*trendingWindow, time.Now(), maxTrendingTerms)
+}
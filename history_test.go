@@ -0,0 +1,68 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSearchHistoryTrending(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	type search struct {
+		term string
+		ago  time.Duration
+	}
+	tests := []struct {
+		name     string
+		capacity int
+		searches []search
+		limit    int
+		want     []TermCount
+	}{
+		{
+			name:     "most searched first, ties alphabetical",
+			capacity: 10,
+			searches: []search{{"go", 1}, {"rust", 2}, {"go", 3}, {"ai", 4}},
+			limit:    10,
+			want:     []TermCount{{"go", 2}, {"ai", 1}, {"rust", 1}},
+		},
+		{
+			name:     "outside the window doesn't count",
+			capacity: 10,
+			searches: []search{{"go", time.Minute}, {"old", 25 * time.Hour}},
+			limit:    10,
+			want:     []TermCount{{"go", 1}},
+		},
+		{
+			name:     "limited",
+			capacity: 10,
+			searches: []search{{"a", 1}, {"b", 1}, {"c", 1}},
+			limit:    2,
+			want:     []TermCount{{"a", 1}, {"b", 1}},
+		},
+		{
+			name:     "full ring forgets the oldest",
+			capacity: 2,
+			searches: []search{{"first", 3}, {"second", 2}, {"third", 1}},
+			limit:    10,
+			want:     []TermCount{{"second", 1}, {"third", 1}},
+		},
+		{
+			name:     "empty",
+			capacity: 10,
+			limit:    10,
+			want:     []TermCount{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newSearchHistory(tt.capacity)
+			for _, s := range tt.searches {
+				h.Record(s.term, now.Add(-s.ago))
+			}
+			if got := h.Trending(24*time.Hour, now, tt.limit); !slices.Equal(got, tt.want) {
+				t.Errorf("Trending() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
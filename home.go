@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+// homeQuery, when set, is searched for the homepage, which then shows a featured article above the grid
+var homeQuery *string
+
+// siteTitle and siteDescription brand the pages, set by -site-title and -site-description
+var siteTitle, siteDescription *string
+
+// SiteTitle names the site in the page title and header
+func (s *Search) SiteTitle() string {
+	return *siteTitle
+}
+
+// SiteDescription is the page's meta description, left out when empty
+func (s *Search) SiteDescription() string {
+	return *siteDescription
+}
+
+// splitFeatured takes the first article out to be featured, returning the rest.
+// With no articles there is nothing to feature, with one the rest is empty.
+func splitFeatured(articles []Articles) (*Articles, []Articles) {
+	if len(articles) == 0 {
+		return nil, nil
+	}
+	featured := articles[0]
+	return &featured, articles[1:]
+}
+
+// homeSearch is the homepage: the -home-query results with the first one featured when
+// it is set, otherwise just the search form. A failed fetch falls back to the plain page.
+func homeSearch(r *http.Request) *Search {
+	home := &Search{ViewMode: viewMode(r), Variant: variants[0], Trending: TrendingTerms(), Shortcuts: shortcuts}
+	if *homeQuery == "" {
+		return home
+	}
+	s, err := newSearch(url.Values{"q": {*homeQuery}})
+	if err != nil {
+		slog.Warn("home query", "query", *homeQuery, "err", err)
+		return home
+	}
+	s.SafeSearch = safeSearchCookieOn(r)
+	if err := s.fetch(r.Context()); err != nil {
+		if !clientGone(r, err) {
+			slog.Warn("home query", "query", *homeQuery, "err", err)
+		}
+		return home
+	}
+	s.ViewMode, s.Variant, s.Trending, s.Shortcuts = home.ViewMode, home.Variant, home.Trending, home.Shortcuts
+	s.Home = true
+	s.Featured, s.Results.Articles = splitFeatured(withoutInvalidURLs(s.Results.Articles))
+	return s
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSplitFeatured(t *testing.T) {
+	tests := []struct {
+		name         string
+		articles     []Articles
+		wantFeatured string
+		wantRest     int
+	}{
+		{name: "none", articles: nil, wantRest: 0},
+		{name: "one", articles: []Articles{testArticle(1)}, wantFeatured: "Story 1", wantRest: 0},
+		{name: "several", articles: []Articles{testArticle(1), testArticle(2), testArticle(3)}, wantFeatured: "Story 1", wantRest: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			featured, rest := splitFeatured(tt.articles)
+			if (featured == nil) != (tt.wantFeatured == "") || (featured != nil && featured.Title != tt.wantFeatured) {
+				t.Errorf("featured = %v, want %q", featured, tt.wantFeatured)
+			}
+			if len(rest) != tt.wantRest {
+				t.Errorf("rest has %d articles, want %d", len(rest), tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestIndexHandlerHomeQuery(t *testing.T) {
+	tests := []struct {
+		name         string
+		homeQuery    string
+		failing      bool
+		wantFeatured bool
+	}{
+		{name: "plain form", homeQuery: "", wantFeatured: false},
+		{name: "featured article", homeQuery: "top stories", wantFeatured: true},
+		{name: "failed fetch falls back", homeQuery: "top stories", failing: true, wantFeatured: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &homeQuery, tt.homeQuery)
+			api := newFakeNewsAPI(t, 5)
+			if tt.failing {
+				useNewsAPI(t, newErrorNewsAPI(t, http.StatusBadGateway, ""))
+			} else {
+				useNewsAPI(t, api.Server)
+			}
+			w := get(indexHandler, "/")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+			body := w.Body.String()
+			if got := strings.Contains(body, `class="news-article featured"`) && strings.Contains(body, "Story 1"); got != tt.wantFeatured {
+				t.Errorf("featured story shown = %v, want %v", got, tt.wantFeatured)
+			}
+			if tt.homeQuery == "" && api.hits.Load() != 0 {
+				t.Error("the plain homepage called newsapi")
+			}
+		})
+	}
+}
+
+func TestSiteBranding(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		want        string
+		dontWant    string
+	}{
+		{name: "with a description", description: "Headlines & more", want: `<meta name="description" content="Headlines &amp; more">`},
+		{name: "without", description: "", dontWant: `<meta name="description"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &siteTitle, "Daily Go")
+			setFlag(t, &siteDescription, tt.description)
+			body := get(indexHandler, "/").Body.String()
+			if !strings.Contains(body, "<title>Daily Go</title>") {
+				t.Error("page title isn't the site title")
+			}
+			if tt.want != "" && !strings.Contains(body, tt.want) {
+				t.Errorf("page lacks %s", tt.want)
+			}
+			if tt.dontWant != "" && strings.Contains(body, tt.dontWant) {
+				t.Errorf("page has %s", tt.dontWant)
+			}
+		})
+	}
+}
+
+func TestSiteTitleOnPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		target    string
+		homeQuery string
+		wantTitle string
+	}{
+		{name: "home", handler: indexHandler, target: "/", wantTitle: "<title>Daily Go</title>"},
+		{name: "home with a home query", handler: indexHandler, target: "/", homeQuery: "go", wantTitle: "<title>Daily Go</title>"},
+		{name: "search", handler: searchHandler, target: "/search?q=golang", wantTitle: "<title>golang - Daily Go</title>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, newFakeNewsAPI(t, 3).Server)
+			setFlag(t, &siteTitle, "Daily Go")
+			setFlag(t, &homeQuery, tt.homeQuery)
+			body := get(tt.handler, tt.target).Body.String()
+			if !strings.Contains(body, tt.wantTitle) {
+				t.Errorf("page lacks %s", tt.wantTitle)
+			}
+			if !strings.Contains(body, `<a class="logo" href="/">Daily Go</a>`) {
+				t.Error("header doesn't name the site")
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// maxImageBytes caps how much of a remote image we are willing to read
+	maxImageBytes = 5 << 20
+	// maxImagePixels caps the width times height of an image we decode to scale it, a few MB of
+	// compressed image can claim dimensions that would take gigabytes once decoded
+	maxImagePixels = 4096 * 4096
+	// maxImageSize is the largest size a client may ask /img to scale to
+	maxImageSize = 1200
+	// cardImageSize is what article cards request, a little over their 200px width for hi-dpi screens
+	cardImageSize = 400
+	imageCacheTTL = 10 * time.Minute
+	// imageCacheEntries and imageCacheBytes bound the proxied images kept in memory
+	imageCacheEntries = 500
+	imageCacheBytes   = 64 << 20
+	// maxImageRedirects is how many redirects an image fetch follows, each checked like the URL
+	maxImageRedirects = 5
+)
+
+// imageHosts are the hosts images may come from, set from -image-hosts, checked with
+// isAllowedImageHost by /img, inlineImages, FaviconURL and the Content-Security-Policy's
+// img-src. Empty allows any https host, the proxy then relies on refusePrivate to stay off
+// internal addresses.
+var imageHosts []string
+
+var imageCache = newBoundedCache(imageCacheEntries, imageCacheBytes)
+
+// remoteTransport dials remote hosts itself, never through HTTP_PROXY or HTTPS_PROXY: with a
+// proxy the dial check would only see the proxy's address, not the host being fetched
+var remoteTransport = &http.Transport{
+	Proxy:       nil,
+	DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: refusePrivate}).DialContext,
+}
+
+// reservedPrefixes are ranges remote fetches must not reach that netip's own checks in
+// isPublicAddr don't cover: "this network", carrier-grade NAT, IETF protocol assignments,
+// benchmarking and the reserved 240/4, and the IPv6 prefixes that embed an IPv4 address
+// (NAT64 and 6to4), which could lead back to a private one
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// remoteClient fetches third-party resources and refuses to dial private addresses
+var remoteClient = &http.Client{Timeout: 10 * time.Second, Transport: remoteTransport}
+
+// imageClient is remoteClient for images, following redirects only to hosts the proxy may fetch
+var imageClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: remoteTransport,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxImageRedirects {
+			return fmt.Errorf("image %s: too many redirects", via[0].URL)
+		}
+		_, err := validateImageURL(req.URL.String())
+		return err
+	},
+}
+
+// refusePrivate stops remote fetches from reaching loopback, private, link-local, multicast or
+// reserved addresses
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !isPublicAddr(ip) {
+		return fmt.Errorf("refusing to connect to %s", host)
+	}
+	return nil
+}
+
+// isPublicAddr reports whether ip is a unicast address on the internet, an IPv4-mapped IPv6
+// address counting as its IPv4 one
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRemoteURL checks raw is an absolute http(s) URL, and its host on the allowlist when one
+// is given (see isAllowedImageHost)
+func validateRemoteURL(raw string, allow []string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, errors.New("invalid url")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("url must be http or https")
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("url has no host")
+	}
+	if !isAllowedImageHost(u.Hostname(), allow) {
+		return nil, errors.New("host not allowed")
+	}
+	return u, nil
+}
+
+// isAllowedImageHost reports whether images may be loaded from host: any host when allowlist is
+// empty, otherwise one of its entries or their subdomains
+func isAllowedImageHost(host string, allowlist []string) bool {
+	return len(allowlist) == 0 || hostAllowed(host, allowlist)
+}
+
+// validateImageURL is validateRemoteURL for what the image proxy fetches: a host on -image-hosts,
+// or any https URL when none are set
+func validateImageURL(raw string) (*url.URL, error) {
+	u, err := validateRemoteURL(raw, imageHosts)
+	if err != nil {
+		return nil, err
+	}
+	if len(imageHosts) == 0 && u.Scheme != "https" {
+		return nil, errors.New("url must be https when no -image-hosts are set")
+	}
+	return u, nil
+}
+
+// hostAllowed matches host against the allowlist, entries also cover their subdomains
+func hostAllowed(host string, allow []string) bool {
+	host = strings.ToLower(host)
+	for _, a := range allow {
+		a = strings.ToLower(a)
+		if host == a || strings.HasSuffix(host, "."+a) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageURL routes the article image through the /img proxy
+func (a *Articles) ImageURL() string {
+	return imageProxyURL(a.URLToImage, cardImageSize)
+}
+
+// imageProxyURL is the /img URL for src scaled to size, "" when there is no image or the proxy
+// won't fetch it
+func imageProxyURL(src string, size int) string {
+	if src == "" {
+		return ""
+	}
+	if _, err := validateImageURL(src); err != nil {
+		return ""
+	}
+	v := url.Values{}
+	v.Set("url", src)
+	v.Set("size", strconv.Itoa(size))
+	return "/img?" + v.Encode()
+}
+
+// imageHandler proxies a remote image, optionally scaling it down so its longest side is at most size
+func imageHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	u, err := validateImageURL(params.Get("url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	size := 0
+	if s := params.Get("size"); s != "" {
+		size, err = strconv.Atoi(s)
+		if err != nil || size < 1 || size > maxImageSize {
+			http.Error(w, fmt.Sprintf("size must be between 1 and %d", maxImageSize), http.StatusBadRequest)
+			return
+		}
+	}
+
+	key := fmt.Sprintf("img|%s|%d", u.String(), size)
+	body, ok := imageCache.Get(key)
+	if !ok {
+		body, err = fetchImage(r.Context(), u.String(), size)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, "Could not load image", http.StatusBadGateway)
+			return
+		}
+		imageCache.Set(key, body, imageCacheTTL)
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(body))
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(imageCacheTTL.Seconds())))
+	w.Write(body)
+}
+
+func fetchImage(ctx context.Context, src string, size int) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := imageClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image %s: status %d", src, resp.StatusCode)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		return nil, fmt.Errorf("image %s: not an image (%s)", src, resp.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxImageBytes {
+		return nil, fmt.Errorf("image %s: larger than %d bytes", src, maxImageBytes)
+	}
+	if !strings.HasPrefix(http.DetectContentType(body), "image/") {
+		return nil, fmt.Errorf("image %s: body is not an image", src)
+	}
+
+	if size == 0 {
+		return body, nil
+	}
+	return resizeImage(body, size)
+}
+
+// resizeImage scales the image down so neither side exceeds size, re-encoding it as JPEG.
+// Images already small enough are returned untouched, and images over maxImagePixels are
+// refused before they are decoded.
+func resizeImage(body []byte, size int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	w, h := cfg.Width, cfg.Height
+	if w*h > maxImagePixels {
+		return nil, fmt.Errorf("image of %dx%d is over %d pixels", w, h, maxImagePixels)
+	}
+	if w <= size && h <= size {
+		return body, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	if w >= h {
+		h = h * size / w
+		w = size
+	} else {
+		w = w * size / h
+		h = size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
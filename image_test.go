@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateImageURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		hosts   []string
+		raw     string
+		wantErr bool
+	}{
+		{name: "any https host without hosts", hosts: nil, raw: "https://img.example.com/a.jpg"},
+		{name: "plain http needs hosts", hosts: nil, raw: "http://img.example.com/a.jpg", wantErr: true},
+		{name: "plain http on a listed host", hosts: []string{"example.com"}, raw: "http://example.com/a.jpg"},
+		{name: "listed host", hosts: []string{"example.com"}, raw: "https://example.com/a.jpg"},
+		{name: "subdomain of a listed host", hosts: []string{"example.com"}, raw: "https://img.example.com/a.jpg"},
+		{name: "host case doesn't matter", hosts: []string{"example.com"}, raw: "https://IMG.Example.com/a.jpg"},
+		{name: "other host", hosts: []string{"example.com"}, raw: "https://evil.test/a.jpg", wantErr: true},
+		{name: "lookalike suffix", hosts: []string{"example.com"}, raw: "https://notexample.com/a.jpg", wantErr: true},
+		{name: "not http", hosts: []string{"example.com"}, raw: "ftp://example.com/a.jpg", wantErr: true},
+		{name: "no host", hosts: []string{"example.com"}, raw: "/a.jpg", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &imageHosts, tt.hosts)
+			if _, err := validateImageURL(tt.raw); (err != nil) != tt.wantErr {
+				t.Errorf("validateImageURL(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsAllowedImageHost(t *testing.T) {
+	tests := []struct {
+		host      string
+		allowlist []string
+		want      bool
+	}{
+		{host: "anything.example.net", allowlist: nil, want: true},
+		{host: "example.com", allowlist: []string{"example.com"}, want: true},
+		{host: "img.example.com", allowlist: []string{"example.com"}, want: true},
+		{host: "IMG.EXAMPLE.COM", allowlist: []string{"example.com"}, want: true},
+		{host: "notexample.com", allowlist: []string{"example.com"}, want: false},
+		{host: "example.com.evil.test", allowlist: []string{"example.com"}, want: false},
+		{host: "images.example.org", allowlist: []string{"example.com", "images.example.org"}, want: true},
+	}
+	for _, tt := range tests {
+		if got := isAllowedImageHost(tt.host, tt.allowlist); got != tt.want {
+			t.Errorf("isAllowedImageHost(%q, %q) = %v, want %v", tt.host, tt.allowlist, got, tt.want)
+		}
+	}
+}
+
+func TestImageProxyURL(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts []string
+		src   string
+		want  string
+	}{
+		{name: "proxied", hosts: []string{"example.com"}, src: "https://img.example.com/a.jpg", want: "/img?size=64&url=https%3A%2F%2Fimg.example.com%2Fa.jpg"},
+		{name: "any https host without hosts", hosts: nil, src: "https://img.example.com/a.jpg", want: "/img?size=64&url=https%3A%2F%2Fimg.example.com%2Fa.jpg"},
+		{name: "plain http without hosts", hosts: nil, src: "http://img.example.com/a.jpg", want: ""},
+		{name: "host not allowed", hosts: []string{"example.com"}, src: "https://evil.test/a.jpg", want: ""},
+		{name: "no image", hosts: []string{"example.com"}, src: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &imageHosts, tt.hosts)
+			if got := imageProxyURL(tt.src, 64); got != tt.want {
+				t.Errorf("imageProxyURL(%q) = %q, want %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageRedirectsAreChecked(t *testing.T) {
+	setVar(t, &imageHosts, []string{"example.com"})
+	tests := []struct {
+		name    string
+		target  string
+		via     int
+		wantErr bool
+	}{
+		{name: "to a listed host", target: "https://cdn.example.com/a.jpg", via: 1},
+		{name: "to another host", target: "https://internal.test/a.jpg", via: 1, wantErr: true},
+		{name: "too many", target: "https://cdn.example.com/a.jpg", via: maxImageRedirects + 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			via := make([]*http.Request, tt.via)
+			for i := range via {
+				via[i] = httptest.NewRequest(http.MethodGet, "https://example.com/a.jpg", nil)
+			}
+			if err := imageClient.CheckRedirect(req, via); (err != nil) != tt.wantErr {
+				t.Errorf("CheckRedirect to %s error = %v, wantErr %v", tt.target, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRefusePrivate(t *testing.T) {
+	tests := []struct {
+		address string
+		refused bool
+	}{
+		{address: "93.184.216.34:443"},
+		{address: "[2606:2800:220:1:248:1893:25c8:1946]:443"},
+		{address: "127.0.0.1:80", refused: true},
+		{address: "10.1.2.3:80", refused: true},
+		{address: "169.254.169.254:80", refused: true},
+		{address: "0.0.0.0:80", refused: true},
+		{address: "0.1.2.3:80", refused: true},
+		{address: "100.64.0.1:80", refused: true},
+		{address: "192.0.0.8:80", refused: true},
+		{address: "198.18.0.1:80", refused: true},
+		{address: "240.0.0.1:80", refused: true},
+		{address: "255.255.255.255:80", refused: true},
+		{address: "224.0.0.1:80", refused: true},
+		{address: "[::1]:80", refused: true},
+		{address: "[fd00::1]:80", refused: true},
+		{address: "[fe80::1%eth0]:80", refused: true},
+		{address: "[::ffff:10.0.0.1]:80", refused: true},
+		{address: "[64:ff9b::a00:1]:80", refused: true},
+		{address: "[2002:a00:1::1]:80", refused: true},
+		{address: "not-an-ip:80", refused: true},
+	}
+	for _, tt := range tests {
+		if err := refusePrivate("tcp", tt.address, nil); (err != nil) != tt.refused {
+			t.Errorf("refusePrivate(%s) error = %v, want refused %v", tt.address, err, tt.refused)
+		}
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		size          int
+		wantW, wantH  int
+	}{
+		{name: "wide image", width: 800, height: 400, size: 200, wantW: 200, wantH: 100},
+		{name: "tall image", width: 300, height: 600, size: 150, wantW: 75, wantH: 150},
+		{name: "already small enough", width: 100, height: 50, size: 200, wantW: 100, wantH: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, tt.width, tt.height))); err != nil {
+				t.Fatal(err)
+			}
+			out, err := resizeImage(buf.Bytes(), tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != tt.wantW || cfg.Height != tt.wantH {
+				t.Errorf("resized to %dx%d, want %dx%d", cfg.Width, cfg.Height, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestResizeImageRefusesHugeImages(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 10, 10), palette.Plan9), nil); err != nil {
+		t.Fatal(err)
+	}
+	// a GIF names its size in the header, a tiny file can claim 65535x65535
+	body := buf.Bytes()
+	binary.LittleEndian.PutUint16(body[6:], 0xffff)
+	binary.LittleEndian.PutUint16(body[8:], 0xffff)
+	if _, err := resizeImage(body, cardImageSize); err == nil || !strings.Contains(err.Error(), "pixels") {
+		t.Errorf("resizeImage of a 65535x65535 GIF error = %v, want one about its pixels", err)
+	}
+}
+
+func TestImageHandlerRejects(t *testing.T) {
+	setVar(t, &imageHosts, []string{"example.com"})
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "host not allowed", query: "url=https://evil.test/a.jpg"},
+		{name: "size too large", query: "url=https://example.com/a.jpg&size=5000"},
+		{name: "size zero", query: "url=https://example.com/a.jpg&size=0"},
+		{name: "size not a number", query: "url=https://example.com/a.jpg&size=big"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := get(imageHandler, "/img?"+tt.query); w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", w.Code)
+			}
+		})
+	}
+}
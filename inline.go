@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// maxInlineImageBytes caps an inlined image after scaling, bigger ones keep their URL
+	maxInlineImageBytes = 200 << 10
+	// inlineImageWorkers is how many images one response fetches at a time
+	inlineImageWorkers = 4
+	inlineImagesWindow = time.Minute
+)
+
+// inlineImagesTimeout bounds all of a response's image fetches, the ones still going keep their URL
+var inlineImagesTimeout = 15 * time.Second
+
+// inlineImagesLimiter is set up in main from -inline-images-rate, nil turns inlineImages=1 off
+var inlineImagesLimiter *windowLimiter
+
+// imageDataURI is body as a data: URI, typed by sniffing it
+func imageDataURI(body []byte) string {
+	return "data:" + http.DetectContentType(body) + ";base64," + base64.StdEncoding.EncodeToString(body)
+}
+
+// inlineImage fetches u at card size, through the /img cache, for embedding
+func inlineImage(ctx context.Context, u *url.URL) ([]byte, error) {
+	key := fmt.Sprintf("img|%s|%d", u.String(), cardImageSize)
+	if body, ok := imageCache.Get(key); ok {
+		return body, nil
+	}
+	body, err := fetchImage(ctx, u.String(), cardImageSize)
+	if err != nil {
+		return nil, err
+	}
+	imageCache.Set(key, body, imageCacheTTL)
+	return body, nil
+}
+
+// inlineImages replaces each article's urlToImage with the image as a data URI, scaled down to
+// card size. Images that fail, time out or are still too big afterwards keep their URL.
+// It returns a new slice and leaves articles untouched.
+func inlineImages(ctx context.Context, articles []Articles) []Articles {
+	ctx, cancel := context.WithTimeout(ctx, inlineImagesTimeout)
+	defer cancel()
+
+	inlined := append([]Articles(nil), articles...)
+	slots := make(chan struct{}, inlineImageWorkers)
+	var wg sync.WaitGroup
+	for i := range inlined {
+		// images off the image hosts are expected, they keep their URL without a word
+		u, err := validateImageURL(inlined[i].URLToImage)
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			body, err := inlineImage(ctx, u)
+			if err != nil {
+				if !isCanceled(err) {
+					log.Printf("inlining image: %v", err)
+				}
+				return
+			}
+			if len(body) > maxInlineImageBytes {
+				return
+			}
+			inlined[i].URLToImage = imageDataURI(body)
+		}()
+	}
+	wg.Wait()
+	return inlined
+}
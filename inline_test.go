@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useImageServer serves handler as the image host inlining fetches from, dialled without the
+// refusal of loopback addresses, with an empty image cache. It returns the server's URL.
+func useImageServer(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	setVar[http.RoundTripper](t, &imageClient.Transport, srv.Client().Transport)
+	setVar(t, &imageHosts, []string{"127.0.0.1"})
+	setVar(t, &imageCache, newBoundedCache(100, 0))
+	return srv.URL
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// noiseImage is a card sized image that doesn't compress, so its PNG is over maxInlineImageBytes
+func noiseImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 300, 300))
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for i := range img.Pix {
+		img.Pix[i] = byte(rnd.Uint32())
+	}
+	return img
+}
+
+func TestInlineImages(t *testing.T) {
+	small := encodePNG(t, image.NewGray(image.Rect(0, 0, 4, 4)))
+	big := encodePNG(t, noiseImage())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/small.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(small)
+	})
+	mux.HandleFunc("/big.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(big)
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body>not an image</body></html>")
+	})
+	mux.HandleFunc("/slow.png", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	base := useImageServer(t, mux)
+	setVar(t, &inlineImagesTimeout, 200*time.Millisecond)
+
+	tests := []struct {
+		name     string
+		src      string
+		wantData bool
+	}{
+		{name: "embedded", src: base + "/small.png", wantData: true},
+		{name: "too big keeps its URL", src: base + "/big.png"},
+		{name: "not an image keeps its URL", src: base + "/page.html"},
+		{name: "failed keeps its URL", src: base + "/missing.png"},
+		{name: "still loading at the timeout keeps its URL", src: base + "/slow.png"},
+		{name: "host not allowed keeps its URL", src: "https://other.example.org/a.png"},
+		{name: "no image", src: ""},
+	}
+	articles := make([]Articles, len(tests))
+	for i, tt := range tests {
+		articles[i] = Articles{Title: tt.name, URLToImage: tt.src}
+	}
+	start := time.Now()
+	inlined := inlineImages(t.Context(), articles)
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("inlineImages took %v, want it cut off at the %v timeout", took, inlineImagesTimeout)
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := inlined[i].URLToImage
+			if tt.wantData && !strings.HasPrefix(got, "data:image/png;base64,") {
+				t.Errorf("urlToImage = %.40q, want a PNG data URI", got)
+			}
+			if !tt.wantData && got != tt.src {
+				t.Errorf("urlToImage = %.40q, want it kept as %q", got, tt.src)
+			}
+			if articles[i].URLToImage != tt.src {
+				t.Error("inlineImages changed the given articles")
+			}
+		})
+	}
+}
+
+func TestInlineImagesConcurrency(t *testing.T) {
+	small := encodePNG(t, image.NewGray(image.Rect(0, 0, 4, 4)))
+	var inFlight, most atomic.Int32
+	full := make(chan struct{})
+	var fullOnce sync.Once
+	base := useImageServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+		}
+		// hold each fetch until all the workers are busy, so a fifth one would be seen
+		if n >= inlineImageWorkers {
+			fullOnce.Do(func() { close(full) })
+		}
+		select {
+		case <-full:
+		case <-time.After(time.Second):
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(small)
+	}))
+
+	articles := make([]Articles, 3*inlineImageWorkers)
+	for i := range articles {
+		articles[i].URLToImage = fmt.Sprintf("%s/%d.png", base, i)
+	}
+	for i, a := range inlineImages(t.Context(), articles) {
+		if !strings.HasPrefix(a.URLToImage, "data:image/png;base64,") {
+			t.Errorf("image %d = %.40q, want a PNG data URI", i, a.URLToImage)
+		}
+	}
+	if got := most.Load(); got != inlineImageWorkers {
+		t.Errorf("at most %d images were fetched at once, want %d", got, inlineImageWorkers)
+	}
+}
+
+func TestSearchJSONInlineImages(t *testing.T) {
+	tests := []struct {
+		name       string
+		limiter    *windowLimiter
+		hosts      []string
+		requests   int
+		wantStatus int
+		wantHits   int32
+	}{
+		{name: "disabled", limiter: nil, hosts: []string{"images.example.com"}, requests: 1, wantStatus: http.StatusBadRequest},
+		{name: "no image hosts", limiter: newWindowLimiter(1, time.Minute), requests: 1, wantStatus: http.StatusBadRequest},
+		{name: "allowed", limiter: newWindowLimiter(1, time.Minute), hosts: []string{"images.example.com"}, requests: 1, wantStatus: http.StatusOK, wantHits: 1},
+		{name: "limited before the fetch", limiter: newWindowLimiter(1, time.Minute), hosts: []string{"images.example.com"}, requests: 2, wantStatus: http.StatusTooManyRequests, wantHits: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeNewsAPI(t, 3)
+			useNewsAPI(t, api.Server)
+			setVar(t, &inlineImagesLimiter, tt.limiter)
+			setVar(t, &imageHosts, tt.hosts)
+			var w *httptest.ResponseRecorder
+			for i := range tt.requests {
+				// a new page each time, so the cache doesn't answer
+				w = get(searchJSONHandler, fmt.Sprintf("/search.json?q=go&inlineImages=1&pageSize=1&page=%d", i+1))
+			}
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := api.hits.Load(); got != tt.wantHits {
+				t.Errorf("newsapi got %d requests, want %d", got, tt.wantHits)
+			}
+			if tt.wantStatus == http.StatusTooManyRequests {
+				var body map[string]any
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Errorf("429 isn't JSON: %s", w.Body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxKeywords caps the repeated keyword param, each one widens the upstream query
+const maxKeywords = 5
+
+// keywordsParam collects the repeated keyword param, trimmed and without duplicates
+func keywordsParam(params url.Values) ([]string, error) {
+	var keywords []string
+	seen := map[string]bool{}
+	for _, k := range params["keyword"] {
+		// quotes would unbalance the phrase we wrap multi-word keywords in
+		k = strings.Join(strings.Fields(strings.ReplaceAll(k, `"`, "")), " ")
+		if k == "" || seen[strings.ToLower(k)] {
+			continue
+		}
+		seen[strings.ToLower(k)] = true
+		keywords = append(keywords, k)
+	}
+	if len(keywords) > maxKeywords {
+		return nil, fmt.Errorf("at most %d keywords may be given", maxKeywords)
+	}
+	return keywords, nil
+}
+
+// keywordQuery joins keywords into a newsapi OR group such as (go OR rust OR "machine learning")
+func keywordQuery(keywords []string) string {
+	if len(keywords) == 0 {
+		return ""
+	}
+	terms := make([]string, len(keywords))
+	for i, k := range keywords {
+		if strings.Contains(k, " ") {
+			k = `"` + k + `"`
+		}
+		terms[i] = k
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// combineQuery requires both the typed query and the keyword group when both are present
+func combineQuery(q string, keywords []string) string {
+	group := keywordQuery(keywords)
+	switch {
+	case group == "":
+		return q
+	case strings.TrimSpace(q) == "":
+		return group
+	default:
+		return "(" + q + ") AND " + group
+	}
+}
+
+// RemoveKeywordURL is the current search without keyword, for the chip's remove link
+func (s *Search) RemoveKeywordURL(keyword string) string {
+	v := s.linkParams()
+	v.Del("keyword")
+	for _, k := range s.Keywords {
+		if k != keyword {
+			v.Add("keyword", k)
+		}
+	}
+	return "/search?" + v.Encode()
+}
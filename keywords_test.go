@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestKeywordsParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "none", query: "q=go", want: nil},
+		{name: "trimmed", query: "keyword=+go+&keyword=machine++learning", want: []string{"go", "machine learning"}},
+		{name: "duplicates in any case", query: "keyword=Go&keyword=go&keyword=GO", want: []string{"Go"}},
+		{name: "quotes dropped", query: `keyword="ai"`, want: []string{"ai"}},
+		{name: "blank skipped", query: "keyword=&keyword=+", want: nil},
+		{name: "at the limit", query: "keyword=a&keyword=b&keyword=c&keyword=d&keyword=e", want: []string{"a", "b", "c", "d", "e"}},
+		{name: "over the limit", query: "keyword=a&keyword=b&keyword=c&keyword=d&keyword=e&keyword=f", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, _ := url.ParseQuery(tt.query)
+			got, err := keywordsParam(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("keywordsParam error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("keywordsParam = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCombineQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		q        string
+		keywords []string
+		want     string
+	}{
+		{name: "query only", q: "climate", want: "climate"},
+		{name: "one keyword", keywords: []string{"go"}, want: "go"},
+		{name: "keywords OR'ed", keywords: []string{"go", "rust"}, want: "(go OR rust)"},
+		{name: "phrases quoted", keywords: []string{"go", "machine learning"}, want: `(go OR "machine learning")`},
+		{name: "both required", q: "jobs", keywords: []string{"go", "rust"}, want: "(jobs) AND (go OR rust)"},
+		{name: "blank query", q: "  ", keywords: []string{"go"}, want: "go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := combineQuery(tt.q, tt.keywords); got != tt.want {
+				t.Errorf("combineQuery(%q, %q) = %q, want %q", tt.q, tt.keywords, got, tt.want)
+			}
+		})
+	}
+}
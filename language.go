@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// detectLanguages turns on detectLanguage for searches without a language param, set by -detect-language
+var detectLanguages *bool
+
+// languageParam reads the optional language param, "" when it isn't given
+func languageParam(params url.Values) (string, error) {
+	lang, err := singleParam(params, "language")
+	if err != nil {
+		return "", err
+	}
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if _, ok := metaName(newsapiLanguages, lang); lang != "" && !ok {
+		return "", errors.New("language must be a two letter code newsapi supports, e.g. en or de")
+	}
+	return lang, nil
+}
+
+// scriptLanguages are the languages a non-Latin script gives away on its own
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	lang   string
+}{
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Han, "zh"},
+}
+
+// latinLetters and latinWords mostly occur in one language written in Latin script
+var latinLetters = map[string]string{
+	"ä": "de", "ß": "de", "ç": "fr", "ê": "fr", "œ": "fr", "ñ": "es", "¿": "es",
+	"¡": "es", "ã": "pt", "õ": "pt", "å": "sv", "ø": "no", "æ": "no",
+}
+
+var latinWords = map[string]string{
+	"der": "de", "das": "de", "und": "de", "nicht": "de", "mit": "de", "für": "de",
+	"les": "fr", "et": "fr", "des": "fr", "une": "fr", "pour": "fr", "avec": "fr",
+	"el": "es", "los": "es", "las": "es", "y": "es", "del": "es", "una": "es", "para": "es",
+	"il": "it", "gli": "it", "della": "it", "che": "it", "sono": "it",
+	"não": "pt", "uma": "pt",
+	"het": "nl", "een": "nl", "niet": "nl",
+	"och": "sv", "att": "sv", "inte": "sv",
+	"og": "no", "ikke": "no",
+}
+
+// detectLanguage guesses the language of a query, "" when it can't tell. A non-Latin script
+// decides it outright; Latin queries need a language specific letter or word to be told
+// apart from English, and a tie between languages is no guess at all.
+func detectLanguage(q string) string {
+	q = strings.ToLower(q)
+	for _, r := range q {
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.script, r) {
+				return s.lang
+			}
+		}
+	}
+
+	scores := map[string]int{}
+	for letter, lang := range latinLetters {
+		if strings.Contains(q, letter) {
+			scores[lang]++
+		}
+	}
+	for _, w := range strings.FieldsFunc(q, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if lang, ok := latinWords[w]; ok {
+			scores[lang]++
+		}
+	}
+
+	best, bestScore, tie := "", 0, false
+	for lang, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = lang, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+	if tie {
+		return ""
+	}
+	return best
+}
+
+// LanguageName is how the UI names the search language
+func (s *Search) LanguageName() string {
+	name, _ := metaName(newsapiLanguages, s.Language)
+	return name
+}
+
+// LanguageURL is the current search with an explicit language, which always wins over detection
+func (s *Search) LanguageURL(lang string) string {
+	v := s.linkParams()
+	v.Set("language", lang)
+	return "/search?" + v.Encode()
+}
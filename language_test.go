@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestLanguageParam(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "", want: ""},
+		{query: "language=de", want: "de"},
+		{query: "language=+FR+", want: "fr"},
+		{query: "language=xx", wantErr: true},
+		{query: "language=english", wantErr: true},
+		{query: "language=de&language=fr", wantErr: true},
+	}
+	for _, tt := range tests {
+		params, _ := url.ParseQuery(tt.query)
+		got, err := languageParam(params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("languageParam(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("languageParam(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{q: "election results", want: ""},
+		{q: "Wahlen in Österreich und der Schweiz", want: "de"},
+		{q: "les élections pour le président", want: "fr"},
+		{q: "¿qué pasa en España?", want: "es"},
+		{q: "выборы", want: "ru"},
+		{q: "选举", want: "zh"},
+		{q: "انتخابات", want: "ar"},
+		{q: "בחירות", want: "he"},
+		{q: "der les", want: ""},
+		{q: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.q); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestSearchLanguage(t *testing.T) {
+	tests := []struct {
+		name         string
+		detect       bool
+		params       url.Values
+		wantLanguage string
+		wantDetected bool
+		wantInLinks  bool
+	}{
+		{name: "detection off", detect: false, params: url.Values{"q": {"Wahlen und Parteien"}}},
+		{name: "detected", detect: true, params: url.Values{"q": {"Wahlen und Parteien"}}, wantLanguage: "de", wantDetected: true},
+		{name: "param wins", detect: true, params: url.Values{"q": {"Wahlen und Parteien"}, "language": {"fr"}}, wantLanguage: "fr", wantInLinks: true},
+		{name: "nothing to detect", detect: true, params: url.Values{"q": {"elections"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &detectLanguages, tt.detect)
+			s, err := newSearch(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Language != tt.wantLanguage || s.LanguageDetected != tt.wantDetected {
+				t.Errorf("Language = %q, detected %v, want %q, %v", s.Language, s.LanguageDetected, tt.wantLanguage, tt.wantDetected)
+			}
+			if got := s.everythingParams().Get("language"); got != tt.wantLanguage {
+				t.Errorf("language sent = %q, want %q", got, tt.wantLanguage)
+			}
+			// a detected language is guessed again from the query, links don't pin it
+			if got := s.linkParams().Has("language"); got != tt.wantInLinks {
+				t.Errorf("language in links = %v, want %v", got, tt.wantInLinks)
+			}
+		})
+	}
+}
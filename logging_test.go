@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    slog.Level
+		wantErr bool
+	}{
+		{in: "debug", want: slog.LevelDebug},
+		{in: "info", want: slog.LevelInfo},
+		{in: "WARN", want: slog.LevelWarn},
+		{in: "Error", want: slog.LevelError},
+		{in: "verbose", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseLogLevel(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLogLevel(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseLogLevel(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewLogHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		level   slog.Level
+		wantErr bool
+		want    func(out string) bool
+	}{
+		{name: "text", format: "text", level: slog.LevelInfo, want: func(out string) bool {
+			return strings.Contains(out, "level=INFO") && strings.Contains(out, "msg=shown") && !strings.Contains(out, "hidden")
+		}},
+		{name: "json", format: "json", level: slog.LevelInfo, want: func(out string) bool {
+			var record map[string]any
+			return json.Unmarshal([]byte(out), &record) == nil && record["msg"] == "shown" && record["q"] == "go"
+		}},
+		{name: "debug shows everything", format: "text", level: slog.LevelDebug, want: func(out string) bool {
+			return strings.Contains(out, "hidden") && strings.Contains(out, "shown")
+		}},
+		{name: "unknown format", format: "xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h, err := newLogHandler(&buf, tt.format, tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLogHandler(%q) error = %v, wantErr %v", tt.format, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			logger := slog.New(h)
+			logger.Debug("hidden")
+			logger.Info("shown", "q", "go")
+			if !tt.want(buf.String()) {
+				t.Errorf("logged %q", buf.String())
+			}
+		})
+	}
+}
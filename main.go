@@ -1,167 +1,907 @@
 package main
 
 import (
-	"html/template"
-	"net/http"
-	"os"
-	"fmt"
-	"net/url"
+	"context"
+	"errors"
 	"flag"
-	"time"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
 	"strconv"
-	"encoding/json"
-	"math"
-
+	"strings"
+	"time"
 )
 
 // tpl is a package level var , points to a template definition
-// wrap the invocation of template.ParseFiles with template.Must so that the code panics if an error is obtained. 
-var tpl = template.Must(template.ParseFiles("index.html"))
+// newTemplateStore panics if the template doesn't parse, and lets -dev swap it while it is in use.
+var tpl = newTemplateStore("index.html")
 var apiKey *string
 
+// newsapi is shared by every handler that needs to talk to newsapi.org
+var newsapi *NewsClient
+
+// defaultPageSize is used when a request doesn't ask for its own pageSize
+var defaultPageSize *int
+
+// displayLimit caps how many of each fetched page are shown, after filtering and ranking, 0 shows them all
+var displayLimit *int
+
+// breakingWindow is how recently an article must have been published to get the NEW badge
+var breakingWindow *time.Duration
+
+// maxBreakingWindow keeps the NEW badge for news: past a week it would be on most results
+const maxBreakingWindow = 7 * 24 * time.Hour
+
+// readerPrefix, when set, is a reader proxy the article URL gets appended to for the reader view link
+var readerPrefix *string
+
+// newsapi accepts page sizes between these bounds
+const (
+	minPageSize = 1
+	maxPageSize = 100
+)
+
 // Data model - convert json to struct from JSON-to-GO
 type Source struct {
 	ID   interface{} `json:"id"`
 	Name string      `json:"name"`
-} 
+	// Country and Category come from the source catalog, with -source-labels
+	Country  string `json:"country,omitempty"`
+	Category string `json:"category,omitempty"`
+}
 
 type Articles struct {
-	Source 	Source	`json:"source"`
+	Source      Source    `json:"source"`
 	Author      string    `json:"author"`
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
 	URL         string    `json:"url"`
 	URLToImage  string    `json:"urlToImage"`
-	PublishedAt time.Time `json:"publishedAt"`
+	PublishedAt Timestamp `json:"publishedAt"`
 	Content     string    `json:"content"`
-} 
+	// InvalidURL flags an article whose URL is empty or not a usable link, pages leave it out
+	InvalidURL bool `json:"invalidUrl,omitempty"`
+	// Headline marks an article merged in from top headlines, with -merge-headlines
+	Headline bool `json:"headline,omitempty"`
+	// AlsoReportedBy names the sources of the near-duplicates collapsed into this article, with -collapse-similar
+	AlsoReportedBy []string `json:"alsoReportedBy,omitempty"`
+}
 
 func (a *Articles) FormatPublishedDate() string {
+	if a.PublishedAt.IsZero() {
+		return "Unknown date"
+	}
 	year, month, day := a.PublishedAt.Date()
 	return fmt.Sprintf("%v %d, %d", month, day, year)
 }
 
+// IsBreaking reports whether the article was published within the -breaking-window
+func (a *Articles) IsBreaking() bool {
+	age := time.Since(a.PublishedAt.Time)
+	return age >= 0 && age <= *breakingWindow
+}
+
+// ReaderURL links to a simplified reading view of the article through the -reader-prefix proxy.
+// Without a proxy, or for URLs that aren't plain http(s), it returns the original URL.
+// With -article-reader and no proxy it links to our own /article extractor instead.
+func (a *Articles) ReaderURL() string {
+	if *readerPrefix == "" && !*articleReader {
+		return a.URL
+	}
+	u, err := url.Parse(a.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return a.URL
+	}
+	if *readerPrefix == "" {
+		return "/article?url=" + url.QueryEscape(u.String())
+	}
+	return *readerPrefix + u.String()
+}
+
 type Results struct {
-	Status       string `json:"status"`
-	TotalResults int    `json:"totalResults"`
-	Articles []Articles `json:"articles"`
+	Status       string     `json:"status"`
+	TotalResults int        `json:"totalResults"`
+	Articles     []Articles `json:"articles"`
+	// NextCursor is set by /search.json for the page after this one, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+	// Stale results were cached earlier and are served because newsapi is rate limiting us
+	// or the breaker is open
+	Stale bool `json:"stale,omitempty"`
 }
 
 type Search struct {
-	SearchKey  string
-	NextPage   int
-	TotalPages int
-	Results    Results
+	SearchKey string
+	// Keywords come from repeated keyword params and are OR'ed together upstream
+	Keywords []string
+	// Language is the language param, or with -detect-language the one guessed from the query
+	// (LanguageDetected), "" searches in English, the language in everythingDefaults
+	Language         string
+	LanguageDetected bool
+	// MaxAgeHours limits results to articles at most that many hours old, 0 for no limit
+	MaxAgeHours int
+	// DatePreset is a quick date filter such as "week", the from and to sent upstream are
+	// worked out from it (see datesForPreset)
+	DatePreset string
+	// ExcludeDomains are left out upstream, added through each card's hide this source link
+	ExcludeDomains []string
+	// Sources are newsapi source ids the search is limited to, added through the quick filters
+	Sources []string
+	// QuickSources are the -popular-sources quick filters not yet in Sources
+	QuickSources []sourceFilter
+	NextPage     int
+	TotalPages   int
+	PageSize     int
+	Results      Results
+	// Announcement is read out by screen readers through the aria-live region
+	Announcement string
+	// ViewMode is the grid or list layout picked through /view
+	ViewMode string
+	// Variant selects the results block for layout experiments, see variants
+	Variant string
+	// Trending are the most searched terms lately, shown in the sidebar
+	Trending []TermCount
+	// Related are follow-up searches suggested by the current page's titles
+	Related []string
+	// Notice explains why the results are missing or partial, NoticeURL optionally links somewhere useful
+	Notice    string
+	NoticeURL string
+	// Home is the -home-query homepage, its Featured article is shown large above the rest
+	Home     bool
+	Featured *Articles
+	// Shortcuts are the homepage's topic shortcut buttons
+	Shortcuts []searchPreset
+	// SafeSearch keeps adult content out, see safesearch.go
+	SafeSearch bool
+	// SortBy is newsapi's sortBy, or smart for our own smartScore; "" is newest first
+	SortBy string
+
+	// fetched is how many articles newsapi returned for the page, before merged headlines and
+	// our own filtering
+	fetched int
+	// withHeadlines lets fetch merge top headlines into a first page, see mergesHeadlines. The
+	// results page and what is shown with it set it, the JSON exports and /count don't.
+	withHeadlines bool
+	// safeSearchSet is whether the request gave safeSearch, rather than leaving it to the cookie
+	safeSearchSet bool
+}
+
+// singleParam returns the value of a param that only makes sense once.
+// Rather than silently using the first of ?page=1&page=2, a repeated param is an error.
+func singleParam(values url.Values, key string) (string, error) {
+	v := values[key]
+	if len(v) > 1 {
+		return "", fmt.Errorf("%s was given %d times, it may only be given once", key, len(v))
+	}
+	if len(v) == 0 {
+		return "", nil
+	}
+	return v[0], nil
 }
 
-// check if next page field is greater than total page 
+// pageSizeParam reads the optional pageSize override, falling back to the -page-size default
+func pageSizeParam(params url.Values) (int, error) {
+	p, err := singleParam(params, "pageSize")
+	if err != nil {
+		return 0, err
+	}
+	if p == "" {
+		return *defaultPageSize, nil
+	}
+
+	n, err := strconv.Atoi(p)
+	if err != nil || n < minPageSize || n > maxPageSize {
+		return 0, fmt.Errorf("pageSize must be between %d and %d", minPageSize, maxPageSize)
+	}
+	return n, nil
+}
+
+// newSearch reads the params shared by every search endpoint, NextPage starts out as the requested page
+func newSearch(params url.Values) (*Search, error) {
+	q, err := singleParam(params, "q")
+	if err != nil {
+		return nil, err
+	}
+	search := &Search{}
+	search.SearchKey = q
+
+	page, err := singleParam(params, "page")
+	if err != nil {
+		return nil, err
+	}
+	search.NextPage = 1
+	if page != "" {
+		next, err := strconv.Atoi(page)
+		if err != nil || next < 1 {
+			return nil, errors.New("page must be a positive number")
+		}
+		search.NextPage = next
+	}
+
+	pageSize, err := pageSizeParam(params)
+	if err != nil {
+		return nil, err
+	}
+	search.PageSize = pageSize
+
+	search.Keywords, err = keywordsParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	search.ExcludeDomains, err = excludeDomainsParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	search.Sources, err = sourcesParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	search.MaxAgeHours, err = maxAgeParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	search.DatePreset, err = datePresetParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	search.Language, err = languageParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if search.Language == "" && *detectLanguages {
+		search.Language = detectLanguage(search.SearchKey)
+		search.LanguageDetected = search.Language != ""
+	}
+
+	search.SafeSearch, search.safeSearchSet, err = safeSearchParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	search.SortBy, err = sortParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateQueryLength(search); err != nil {
+		return nil, err
+	}
+
+	return search, nil
+}
+
+// query is what gets sent to newsapi as q
+func (s *Search) query() string {
+	return combineQuery(queryHooks.apply(s.SearchKey), s.Keywords)
+}
+
+// EffectiveQuery is the query actually sent upstream when it differs from what was typed
+func (s *Search) EffectiveQuery() string {
+	if q := s.query(); q != strings.TrimSpace(s.SearchKey) {
+		return q
+	}
+	return ""
+}
+
+// DisplayQuery describes the search to the user
+func (s *Search) DisplayQuery() string {
+	if len(s.Keywords) == 0 {
+		return s.SearchKey
+	}
+	return s.query()
+}
+
+// everythingParams builds the NewsClient params for the page in NextPage
+func (s *Search) everythingParams() url.Values {
+	v := url.Values{}
+	v.Set("q", s.upstreamQuery())
+	v.Set("page", strconv.Itoa(s.NextPage))
+	v.Set("pageSize", strconv.Itoa(s.PageSize))
+	if excluded := s.excludedFor(); len(excluded) > 0 {
+		v.Set("excludeDomains", strings.Join(excluded, ","))
+	}
+	if len(s.Sources) > 0 {
+		v.Set("sources", strings.Join(s.Sources, ","))
+	}
+	if s.Language != "" {
+		v.Set("language", s.Language)
+	}
+	if s.MaxAgeHours > 0 {
+		v.Set("from", fromTimestamp(time.Now(), s.maxAge()))
+	}
+	if from, to := s.presetDates(); !from.IsZero() {
+		v.Set("from", from.UTC().Format(time.RFC3339))
+		v.Set("to", to.UTC().Format(time.RFC3339))
+	}
+	// smart ranks the newest articles, the default order
+	if s.SortBy != "" && s.SortBy != "smart" {
+		v.Set("sortBy", s.SortBy)
+	}
+	return v
+}
+
+func (s *Search) maxAge() time.Duration {
+	return time.Duration(s.MaxAgeHours) * time.Hour
+}
+
+// fetch loads the page in NextPage into Results and applies our own ranking on top
+func (s *Search) fetch(ctx context.Context) error {
+	if last := maxPage(s.PageSize); s.NextPage > last {
+		return fmt.Errorf("%w, the last page is %d", errPastLastPage, last)
+	}
+	stats.searches.Add(1)
+	// top headlines come in alongside, under the same ctx, the buffer lets the goroutine finish
+	// even when the search fails and nobody reads it
+	var headlines chan *Results
+	if s.mergesHeadlines() {
+		headlines = make(chan *Results, 1)
+		go func() { headlines <- s.fetchHeadlines(ctx) }()
+	}
+	results, err := newsapi.Everything(ctx, s.everythingParams())
+	if err != nil {
+		return err
+	}
+	// counted before the headlines join them, paging goes by the search's own page
+	s.fetched = len(results.Articles)
+	if headlines != nil {
+		if h := <-headlines; h != nil {
+			merged := mergeResults(*h, *results)
+			results = &merged
+		}
+	}
+	s.Results = *results
+	s.Results.Articles = flagInvalidURLs(filterBlocked(s.Results.Articles, s.blockedFor()))
+	if s.MaxAgeHours > 0 {
+		s.Results.Articles = filterFresh(s.Results.Articles, time.Now().Add(-s.maxAge()))
+	}
+	if from, _ := s.presetDates(); !from.IsZero() {
+		s.Results.Articles = filterFresh(s.Results.Articles, from)
+	}
+	s.Results.Articles = collapseNearDuplicates(s.Results.Articles, *collapseSimilar)
+	if s.SortBy == "smart" {
+		// the source preference is part of the score
+		s.Results.Articles = sortSmart(s.Results.Articles, smartWeights, time.Now())
+	} else {
+		s.Results.Articles = boostSources(s.Results.Articles, preferredSources)
+	}
+	if *sourceLabels {
+		s.addSourceLabels(ctx)
+	}
+	if *displayLimit > 0 && len(s.Results.Articles) > *displayLimit {
+		s.Results.Articles = s.Results.Articles[:*displayLimit]
+	}
+	return nil
+}
+
+// linkParams are the params that reproduce this search in a link, everything but the page
+func (s *Search) linkParams() url.Values {
+	v := url.Values{}
+	v.Set("q", s.SearchKey)
+	if s.PageSize != *defaultPageSize {
+		v.Set("pageSize", strconv.Itoa(s.PageSize))
+	}
+	for _, k := range s.Keywords {
+		v.Add("keyword", k)
+	}
+	if len(s.ExcludeDomains) > 0 {
+		v.Set("excludeDomains", strings.Join(s.ExcludeDomains, ","))
+	}
+	if len(s.Sources) > 0 {
+		v.Set("sources", strings.Join(s.Sources, ","))
+	}
+	// a detected language is detected again, only a chosen one goes in links
+	if s.Language != "" && !s.LanguageDetected {
+		v.Set("language", s.Language)
+	}
+	if s.MaxAgeHours > 0 {
+		v.Set("maxAgeHours", strconv.Itoa(s.MaxAgeHours))
+	}
+	if s.DatePreset != "" {
+		v.Set("datePreset", s.DatePreset)
+	}
+	if s.Variant != "" && s.Variant != variants[0] {
+		v.Set("variant", s.Variant)
+	}
+	if s.SafeSearch {
+		v.Set("safeSearch", "1")
+	}
+	if s.SortBy != "" {
+		v.Set("sortBy", s.SortBy)
+	}
+	return v
+}
+
+// PresetParams is the query string a preset of this search stores
+func (s *Search) PresetParams() string {
+	return s.linkParams().Encode()
+}
+
+// PageURL links to another page of the same search
+func (s *Search) PageURL(page int) string {
+	v := s.linkParams()
+	v.Set("page", strconv.Itoa(page))
+	return "/search?" + v.Encode()
+}
+
+// check if next page field is greater than total page
 func (s *Search) IsLastPage() bool {
-	return s.NextPage >= s.TotalPages
+	return s.NextPage > s.TotalPages
+}
+
+// FurtherResultsUnavailable reports whether the results fill more pages than can be shown,
+// once TotalPages is set
+func (s *Search) FurtherResultsUnavailable() bool {
+	return s.TotalPages < totalPages(s.Results.TotalResults, s.PageSize)
 }
 
-// keep track of current page
+// keep track of current page, NextPage is one past it once the page has been fetched
 func (s *Search) CurrentPage() int {
-	if s.NextPage == 1 {
-		return s.NextPage
+	return max(1, s.NextPage-1)
+}
+
+// totalPages is how many pages of pageSize the results fill. A pageSize below 1 counts as 1
+// and a negative total as none, so bad input can't divide by zero or yield negative pages.
+func totalPages(totalResults, pageSize int) int {
+	pageSize = max(pageSize, 1)
+	totalResults = max(totalResults, 0)
+	return (totalResults + pageSize - 1) / pageSize
+}
+
+// errPastLastPage is returned by fetch for pages newsapi would refuse, without asking it
+var errPastLastPage = errors.New("page is past the last available page")
+
+// maxPages caps the pages of results shown below what newsapi serves, 0 leaves it at that.
+// Set by -max-pages.
+var maxPages *int
+
+// maxPage is the last page of pageSize that can be shown: newsapi's developer plan stops at
+// freeTierResultCap results however many it counts, and -max-pages may stop sooner
+func maxPage(pageSize int) int {
+	return capPages(max(1, freeTierResultCap/max(pageSize, 1)), *maxPages)
+}
+
+// capPages is pages, but at most limit of them when limit is positive
+func capPages(pages, limit int) int {
+	if limit > 0 {
+		return min(pages, limit)
 	}
+	return pages
+}
 
-	return s.NextPage - 1
+// lastAvailablePage is the last page that can be shown for a search counting totalResults:
+// the last one they fill, but no further than newsapi serves
+func lastAvailablePage(totalResults, pageSize int) int {
+	return max(1, min(totalPages(totalResults, pageSize), maxPage(pageSize)))
 }
+
 // method for previous button
 func (s *Search) PreviousPage() int {
 	return s.CurrentPage() - 1
 }
 
-// execute the template created 
+// execute the template created
 func indexHandler(w http.ResponseWriter, r *http.Request) {
-	tpl.Execute(w, nil)
+	tpl.Get().Execute(w, homeSearch(r))
 }
 
-type NewsAPIError struct {
-	Status  string `json:"status"`
-	Code    string `json:"code"`
-	Message string `json:"message"`
+// announce describes the outcome of a search for the aria-live region
+func announce(s *Search) string {
+	if s.Results.TotalResults == 0 {
+		return fmt.Sprintf("No results found for %s", s.DisplayQuery())
+	}
+	if s.Results.TotalResults == 1 {
+		return fmt.Sprintf("Found 1 result for %s", s.DisplayQuery())
+	}
+	return fmt.Sprintf("Found %d results for %s", s.Results.TotalResults, s.DisplayQuery())
 }
 
-func searchHandler(w http.ResponseWriter, r *http.Request) {
-
-	u, err := url.Parse(r.URL.String())
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Internal server error"))
-		return
+// fetchErrorResponse picks the status and user-facing message for a NewsClient error
+func fetchErrorResponse(err error) (int, string) {
+	if errors.Is(err, ErrNotConfigured) {
+		return http.StatusServiceUnavailable, ErrNotConfigured.Error()
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return http.StatusServiceUnavailable, ErrCircuitOpen.Error()
+	}
+	if errors.Is(err, errPastLastPage) {
+		return http.StatusBadRequest, err.Error()
 	}
 
-	params := u.Query()
-	searchKey := params.Get("q")
-	page := params.Get("page")
-	if page == "" {
-		page = "1"
+	var apiErr *NewsAPIError
+	if errors.As(err, &apiErr) {
+		return http.StatusInternalServerError, apiErr.Message
 	}
 
-	search := &Search{}
-	search.SearchKey = searchKey
+	log.Println(err)
+	return http.StatusInternalServerError, "Unexpected server error"
+}
 
-	next, err := strconv.Atoi(page)
-	if err != nil {
-		http.Error(w, "Unexpected server error", http.StatusInternalServerError)
-		return
+// clientGone reports whether a fetch failed because the client disconnected, there is
+// nobody left to write an error to then, so handlers just stop
+func clientGone(r *http.Request, err error) bool {
+	if err == nil || r.Context().Err() == nil {
+		return false
+	}
+	slog.Debug("client went away during fetch", "path", r.URL.Path, "error", err)
+	return true
+}
+
+// answerHead replies to a HEAD request with the headers a GET would get, short of those that
+// depend on the results. The params have been validated by then, but nothing is fetched, so
+// checking a link doesn't use up any newsapi quota.
+func answerHead(w http.ResponseWriter, r *http.Request, contentType string) bool {
+	if r.Method != http.MethodHead {
+		return false
 	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	return true
+}
 
-	search.NextPage = next
-	pageSize := 20
+// writeFetchError turns a NewsClient error into a plain text response
+func writeFetchError(w http.ResponseWriter, err error) {
+	status, message := fetchErrorResponse(err)
+	http.Error(w, message, status)
+}
 
-	endpoint := fmt.Sprintf("https://newsapi.org/v2/everything?q=%s&pageSize=%d&page=%d&apiKey=%s&sortBy=publishedAt&language=en", url.QueryEscape(search.SearchKey), pageSize, search.NextPage, *apiKey)
-	resp, err := http.Get(endpoint)
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	// parsed once, r.URL.Query() parses the raw query again on every call
+	params := r.URL.Query()
+	search, err := newSearch(params)
+	if errors.Is(err, errQueryTooShort) {
+		// back to the homepage, with a hint rather than an error page
+		home := &Search{SearchKey: params.Get("q"), PageSize: *defaultPageSize, ViewMode: viewMode(r), Variant: variants[0], Trending: TrendingTerms()}
+		home.Notice = fmt.Sprintf("Enter at least %d characters to search.", *minQueryLength)
+		home.Announcement = home.Notice
+		if err := tpl.Get().Execute(w, home); err != nil {
+			log.Println(err)
+		}
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	search.ViewMode = viewMode(r)
+	search.Variant = variantParam(params)
+	search.readSafeSearch(w, r)
+	search.withHeadlines = true
+	if answerHead(w, r, "text/html; charset=utf-8") {
 		return
 	}
 
-	defer resp.Body.Close()
-
-	// error handling
-	if resp.StatusCode != 200 {
-		newError := &NewsAPIError{}
-		err := json.NewDecoder(resp.Body).Decode(newError)
-		if err != nil {
-		  http.Error(w, "Unexpected server error", http.StatusInternalServerError)
-		  return
+	err = search.fetch(r.Context())
+	if clientGone(r, err) {
+		return
+	}
+	// from here on NextPage is the page after this one, CurrentPage is the one fetched
+	search.NextPage++
+	if errors.Is(err, errPastLastPage) {
+		search.Notice = "You're past the last page of results that can be shown."
+		search.NoticeURL = search.PageURL(maxPage(search.PageSize))
+		search.Announcement = search.Notice
+		if err := tpl.Get().Execute(w, search); err != nil {
+			log.Println(err)
 		}
-	  
-		http.Error(w, newError.Message, http.StatusInternalServerError)
 		return
-	  }
-
-	err = json.NewDecoder(resp.Body).Decode(&search.Results)
+	}
+	if apiErrorCode(err) == codeMaximumResultsReached {
+		search.Notice = "You've reached the maximum available results for this plan."
+		search.NoticeURL = search.PageURL(maxPage(search.PageSize))
+		search.Announcement = search.Notice
+		if err := tpl.Get().Execute(w, search); err != nil {
+			log.Println(err)
+		}
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeFetchError(w, err)
 		return
 	}
+	// newsapi sometimes counts more results than it will hand out, the page past the last one it serves comes back empty
+	if search.fetched == 0 && search.Results.TotalResults > 0 {
+		search.Notice = "No more articles are available for this search."
+		if search.CurrentPage() > 1 {
+			search.NoticeURL = search.PageURL(search.CurrentPage() - 1)
+		}
+		search.Announcement = search.Notice
+		if err := tpl.Get().Execute(w, search); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	search.Results.Articles = withoutInvalidURLs(search.Results.Articles)
+	if *dedupePages || *debugPageOverlap {
+		search.trackSeen(w, r)
+	}
+	search.Announcement = announce(search)
 
-	search.TotalPages = int(math.Ceil(float64(search.Results.TotalResults / pageSize)))
-	// if next page is rendered , increment next page
-	if ok := !search.IsLastPage(); ok {
-		search.NextPage++
+	if term := normalizeQuery(search.DisplayQuery()); term != "" {
+		history.Record(term, time.Now())
 	}
+	search.Trending = TrendingTerms()
+	search.Related = relatedTerms(search.Results.Articles, search.query())
+	search.QuickSources = search.quickSources(r.Context())
+
+	// newsapi counts far more results than it pages through, only offer the pages it serves
+	search.TotalPages = lastAvailablePage(search.Results.TotalResults, search.PageSize)
 
-	err = tpl.Execute(w, search)
+	// the page also reflects cookies (layout, saved articles), so only the browser may cache it
+	setCacheControl(w, "private", search.Results)
+	if notModified(w, r, search.Results) {
+		return
+	}
+	err = tpl.Get().Execute(w, search)
 	if err != nil {
 		log.Println(err)
 	}
 }
 
+// splitList turns a comma separated flag value into its trimmed, non-empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func main() {
 	//define a string flag  - (flagname, default value, usage description)
 	apiKey = flag.String("apikey", "", "Newsapi.org access key")
+	defaultPageSize = flag.Int("page-size", 20, "Articles per page when the request has no pageSize param (requests may override it within 1-100)")
+	collapseSimilar = flag.Float64("collapse-similar", 0, "Show articles whose titles are at least this alike (0-1, e.g. 0.6) as one story noting the other sources; 0 is off")
+	dedupePages = flag.Bool("dedupe-pages", false, "Leave articles already shown on a search's earlier pages off its later ones, tracked in a cookie")
+	loadMoreSize = flag.Int("load-more-size", 10, "Articles each \"load more\" call adds to the results page as it is scrolled (1-100)")
+	maxPages = flag.Int("max-pages", 0, "Show at most this many pages of results for a search, 0 for as many as newsapi serves (100 results)")
+	displayLimit = flag.Int("display-limit", 0, "Show at most this many articles per page after filtering, 0 shows every fetched article")
+	mergeHeadlines = flag.Bool("merge-headlines", false, "Also fetch a few top headlines for each first page and show them first; costs a second newsapi request per new search")
+	headlinesCountry = flag.String("headlines-country", "us", "Two letter country the merged top headlines come from, empty for any")
+	sourceLabels = flag.Bool("source-labels", false, "Label cards with their source's country and category from newsapi's source catalog")
+	cardFieldList := flag.String("card-fields", defaultCardFields, "Comma separated article card fields to show: "+strings.Join(cardFieldNames, ", "))
+	imageHostList := flag.String("image-hosts", "", "Comma separated hosts /img may fetch from, empty allows any https host; favicons and the Content-Security-Policy follow it too")
+	siteTitle = flag.String("site-title", "News Headlines", "Site name shown in the search and home page titles and header")
+	siteDescription = flag.String("site-description", "Search the latest news from thousands of sources.", "Meta description of the search and home pages, left out when empty")
+	homeQuery = flag.String("home-query", "", "Search shown on the homepage, its first article featured above the grid; an empty homepage when unset")
+	readerPrefix = flag.String("reader-prefix", "", "Reader proxy prefix for the reader view link, the article URL is appended to it (e.g. https://r.jina.ai/)")
+	newsapiBase := flag.String("newsapi-base", "https://newsapi.org", "Base URL of the NewsAPI service, point it at a mock or proxy if needed")
+	debugPageOverlap = flag.Bool("debug-page-overlap", false, "Log a warning when a results page repeats 30% or more of the page before it, tracked in a cookie")
+	debugUpstream := flag.Bool("debug-upstream", false, "Log every NewsAPI request (key redacted), its status and timing at debug level, which it turns on")
+	logFormat := flag.String("log-format", "text", "Log as text for people or json for log aggregators")
+	logLevel := flag.String("log-level", "info", "Lowest level logged: debug, info, warn or error")
+	breakerFailures := flag.Int("breaker-failures", 5, "Consecutive newsapi failures that open the circuit breaker, 0 disables it")
+	breakerCooldown := flag.Duration("breaker-cooldown", 30*time.Second, "How long the open breaker answers 503 before letting a test request through")
+	cacheTTL := flag.Duration("cache-ttl", 5*time.Minute, "How long search results are cached, 0 disables the cache")
+	cacheSize := flag.Int("cache-size", 1000, "Most entries the memory cache holds, the least recently used are dropped beyond it")
+	staleCacheSize := flag.Int("stale-cache-size", 1000, "Most stale copies (see -stale-ttl) the memory cache holds, apart from -cache-size")
+	retryAttempts := flag.Int("retry-attempts", 2, "Most calls made for one newsapi request, retrying transport errors and failures on newsapi's side; 1 never retries")
+	retryBudgetTotal := flag.Duration("retry-budget", 10*time.Second, "Time all the attempts of one newsapi request, and the waits between them, must fit in; 0 leaves it to the request's own deadline")
+	staleTTL := flag.Duration("stale-ttl", 24*time.Hour, "How long cached results are kept to fall back on while newsapi is rate limiting, 0 disables the fallback")
+	cacheBackend := flag.String("cache-backend", "memory", "Where search results are cached: memory, or redis to share them between replicas")
+	redisURL := flag.String("redis-url", "redis://localhost:6379/0", "Redis to cache in with -cache-backend redis")
+	adminToken = flag.String("admin-token", "", "Bearer token for the /admin/ endpoints, they are disabled when empty")
+	maintenanceMode := flag.Bool("maintenance", false, "Start in maintenance mode, serving a 503 notice on all but health and admin routes")
+	popularSourceList := flag.String("popular-sources", "", "Comma separated newsapi source ids, e.g. bbc-news,reuters, offered as quick filters above the results")
+	smartRecency := flag.Float64("smart-recency-weight", 1, "sortBy=smart: score of a brand new article, halving every -smart-half-life")
+	smartHalfLife := flag.Duration("smart-half-life", 6*time.Hour, "sortBy=smart: age at which an article's recency score has halved")
+	smartImage := flag.Float64("smart-image-weight", 0.3, "sortBy=smart: score added for an article with an image")
+	smartSource := flag.Float64("smart-source-weight", 0.5, "sortBy=smart: score added for an article from one of the -preferred-sources")
+	preferredSourceList := flag.String("preferred-sources", "", "Comma separated source names or domains moved to the top of each results page")
+	blockedWordList := flag.String("blocked-words", "", "Comma separated words or phrases, articles mentioning them are dropped from results")
+	blocklistFile := flag.String("blocklist-file", "", "File of blocked words or phrases, one per line")
+	safeSearchTermList := flag.String("safe-search-terms", strings.Join(defaultSafeSearchTerms, ","), "Comma separated words or phrases safe search excludes from the query and drops from results")
+	safeSearchDomainList := flag.String("safe-search-domains", strings.Join(defaultSafeSearchDomains, ","), "Comma separated adult domains safe search excludes")
+	historySize := flag.Int("history-size", 1000, "How many recent searches are kept for the trending terms")
+	trendingWindow = flag.Duration("trending-window", 24*time.Hour, "Rolling window the trending terms are counted over")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file, serves HTTPS together with -tls-key")
+	tlsKey := flag.String("tls-key", "", "TLS private key file")
+	domain := flag.String("domain", "", "Comma separated domains to serve HTTPS for with Let's Encrypt certificates")
+	certCache := flag.String("cert-cache", "certs", "Directory Let's Encrypt certificates are cached in")
+	httpsRedirect := flag.String("https-redirect", "", "Address of an extra plain HTTP listener that redirects to HTTPS, e.g. :80")
+	cspImgSrc := flag.String("csp-img-src", "", "Space separated image sources the Content-Security-Policy allows besides our own, e.g. https://*.example.com; empty derives them from -image-hosts")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "OTLP/HTTP traces endpoint, e.g. http://localhost:4318/v1/traces; tracing is off when empty")
+	http2 := flag.Bool("http2", true, "Offer HTTP/2 over TLS, turn it off to debug with plain HTTP/1.1")
+	h2c := flag.Bool("h2c", false, "Accept HTTP/2 without TLS (h2c), for a proxy that speaks it to us; plain HTTP only")
+	secret := flag.String("secret", "", "Key used to sign cookies such as saved articles, random (and reset on restart) when empty")
+	readHeaderTimeout := flag.Duration("read-header-timeout", 5*time.Second, "How long a client may take to send request headers (slowloris protection)")
+	readTimeout := flag.Duration("read-timeout", 15*time.Second, "How long a client may take to send the whole request, headers and body")
+	writeTimeout := flag.Duration("write-timeout", 60*time.Second, "How long a handler may take to write its response, covers the slowest NDJSON export")
+	idleTimeout := flag.Duration("idle-timeout", 120*time.Second, "How long an idle keep-alive connection is kept open")
+	maxHeaderBytes := flag.Int("max-header-bytes", 64<<10, "Largest request headers accepted, bigger ones get a 431")
+	maxBodyBytes := flag.Int64("max-body-bytes", 64<<10, "Largest request body accepted, bigger posted forms get a 413")
+	minQueryLength = flag.Int("min-query-length", 2, "Shortest query, in characters, that is sent to NewsAPI; 0 allows any")
+	detectLanguages = flag.Bool("detect-language", false, "Guess the language of queries without a language param and search in it")
+	cleanQueries := flag.Bool("clean-query", false, "Strip stopwords from searches and keep only the most significant words before querying NewsAPI")
+	expandSynonymsFlag := flag.Bool("synonyms", false, "Expand query terms with their synonyms into OR groups, e.g. AI searches for (AI OR \"artificial intelligence\")")
+	synonymsFile := flag.String("synonyms-file", "", "File of synonyms for -synonyms, one \"term: synonym, synonym\" per line, a built-in list when empty")
+	shortcutsFile := flag.String("shortcuts-file", "", "File of homepage topic shortcuts, one \"Label: q=topic&maxAgeHours=24\" per line, a built-in set when empty")
+	topicList := flag.String("random-topics", "", "Comma separated topics /random picks from, a built-in list when empty")
+	topicsFile := flag.String("random-topics-file", "", "File of topics for /random, one per line")
+	faviconService = flag.String("favicon-service", "", "Favicon URL template with a {domain} placeholder, each site's /favicon.ico when empty")
+	baseURL = flag.String("base-url", "", "Public URL of the site, e.g. https://news.example.com, used in sitemap.xml; derived from each request when empty")
+	trustProxy = flag.Bool("trust-proxy", false, "Trust X-Forwarded-Proto and X-Forwarded-Host, set this only behind a proxy that sets them")
+	slashRedirect := flag.Bool("trailing-slash-redirect", true, "Redirect paths with a trailing slash, such as /search/, to the route without it")
+	statsEnabled := flag.Bool("stats", true, "Serve in-memory request counters as JSON at /stats")
+	beaconEnabled = flag.Bool("beacon", false, "Have pages report result clicks and previews to /beacon, counted in /stats without the queries themselves")
+	beaconRate := flag.Int("beacon-rate", 60, "Beacons each client may send per minute, more get a 429")
+	inlineImagesRate := flag.Int("inline-images-rate", 10, "inlineImages=1 requests each client may make per minute, more get a 429; 0 turns inlineImages off")
+	assetsOverride := flag.String("assets-override", "", "Directory whose files, e.g. style.css, are served in place of the bundled assets of the same name")
+	dev := flag.Bool("dev", false, "Reparse the page templates when their files change, for working on them without restarts")
+	articleReader = flag.Bool("article-reader", false, "Serve /article, which fetches an article page and extracts its main text into a reader view")
+	timezone := flag.String("timezone", "UTC", "IANA time zone the timeline view and the datePreset filters count days in, e.g. Europe/London")
+	breakingWindow = flag.Duration("breaking-window", time.Hour, "Articles published within this window are badged as NEW")
 	// parse the key
 	flag.Parse()
 
-	if *apiKey == "" {
-		log.Fatal("apiKey must be set")
+	// before anything else, so every startup message is in the chosen format
+	level, err := parseLogLevel(*logLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *debugUpstream {
+		level = slog.LevelDebug
+	}
+	logHandler, err := newLogHandler(os.Stderr, *logFormat, level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(slog.New(logHandler))
+
+	tlsOpts := tlsOptions{
+		certFile:     *tlsCert,
+		keyFile:      *tlsKey,
+		domains:      splitList(*domain),
+		certCache:    *certCache,
+		redirectAddr: *httpsRedirect,
+		http2:        *http2,
+		h2c:          *h2c,
+	}
+	err = validateConfig(startupConfig{
+		apiKey:            *apiKey,
+		tls:               tlsOpts,
+		pageSize:          *defaultPageSize,
+		loadMoreSize:      *loadMoreSize,
+		displayLimit:      *displayLimit,
+		maxPages:          *maxPages,
+		collapseSimilar:   *collapseSimilar,
+		smartHalfLife:     *smartHalfLife,
+		cardFields:        *cardFieldList,
+		timezone:          *timezone,
+		headlinesCountry:  *headlinesCountry,
+		assetsOverride:    *assetsOverride,
+		minQueryLength:    *minQueryLength,
+		breakingWindow:    *breakingWindow,
+		cacheBackend:      *cacheBackend,
+		redisURL:          *redisURL,
+		cacheSize:         *cacheSize,
+		staleCacheSize:    *staleCacheSize,
+		cacheTTL:          *cacheTTL,
+		staleTTL:          *staleTTL,
+		retryAttempts:     *retryAttempts,
+		retryBudget:       *retryBudgetTotal,
+		breakerFailures:   *breakerFailures,
+		beacon:            *beaconEnabled,
+		beaconRate:        *beaconRate,
+		inlineImagesRate:  *inlineImagesRate,
+		maxHeaderBytes:    *maxHeaderBytes,
+		maxBodyBytes:      *maxBodyBytes,
+		readHeaderTimeout: *readHeaderTimeout,
+		readTimeout:       *readTimeout,
+		writeTimeout:      *writeTimeout,
+		idleTimeout:       *idleTimeout,
+	})
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
 	}
 
+	// both were checked by validateConfig
+	cardFields, _ = parseCardFields(*cardFieldList)
+	timelineLocation, _ = time.LoadLocation(*timezone)
+
+	imageHosts = splitList(*imageHostList)
+	cspImageSources = strings.Fields(*cspImgSrc)
+	preferredSources = splitList(*preferredSourceList)
+	smartWeights = smartOptions{
+		recencyWeight: *smartRecency,
+		imageWeight:   *smartImage,
+		sourceWeight:  *smartSource,
+		halfLife:      *smartHalfLife,
+		preferred:     preferredSources,
+	}
+	popularSources = splitList(*popularSourceList)
+	blockedWords = splitList(*blockedWordList)
+	if *blocklistFile != "" {
+		words, err := readWordList(*blocklistFile)
+		if err != nil {
+			log.Fatalf("reading blocklist: %v", err)
+		}
+		blockedWords = append(blockedWords, words...)
+	}
+	safeSearchTerms = splitList(*safeSearchTermList)
+	compileBlocklist()
+	safeSearchDomains = splitList(*safeSearchDomainList)
+	shortcutLines := defaultShortcuts
+	if *shortcutsFile != "" {
+		lines, err := readWordList(*shortcutsFile)
+		if err != nil {
+			log.Fatalf("reading shortcuts: %v", err)
+		}
+		shortcutLines = lines
+	}
+	if shortcuts, err = parseShortcuts(shortcutLines); err != nil {
+		log.Fatal(err)
+	}
+	if topics := splitList(*topicList); len(topics) > 0 {
+		randomTopics = topics
+	}
+	if *topicsFile != "" {
+		topics, err := readWordList(*topicsFile)
+		if err != nil {
+			log.Fatalf("reading random topics: %v", err)
+		}
+		randomTopics = topics
+	}
+	if *expandSynonymsFlag {
+		synonyms = defaultSynonyms
+		if *synonymsFile != "" {
+			lines, err := readWordList(*synonymsFile)
+			if err != nil {
+				log.Fatalf("reading synonyms: %v", err)
+			}
+			if synonyms, err = parseSynonyms(lines); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	queryHooks = defaultQueryHooks(*cleanQueries, *expandSynonymsFlag)
+	if *dev {
+		if err := watchTemplates("."); err != nil {
+			log.Fatalf("watching templates: %v", err)
+		}
+	}
+	maintenance.Store(*maintenanceMode)
+	setSigningKey(*secret)
+	history = newSearchHistory(*historySize)
+
+	var resultCache, staleCache Cache
+	switch *cacheBackend {
+	case "memory":
+		c := newLRUCache(*cacheSize)
+		stats.cacheEntries = c.Len
+		resultCache = c
+		staleCache = newLRUCache(*staleCacheSize)
+	case "redis":
+		c, err := newRedisCache(*redisURL)
+		if err != nil {
+			log.Fatalf("connecting to redis: %v", err)
+		}
+		resultCache = c
+	}
+
+	if *otlpEndpoint != "" {
+		if err := setupTracing(*otlpEndpoint, "news-atgo"); err != nil {
+			log.Fatalf("setting up tracing: %v", err)
+		}
+	}
+
+	newsapi = NewNewsClient(&http.Client{Timeout: 10 * time.Second}, *newsapiBase, *apiKey, resultCache, *cacheTTL)
+	newsapi.staleTTL = *staleTTL
+	newsapi.staleCache = staleCache
+	newsapi.retry = retryBudget{attempts: *retryAttempts, total: *retryBudgetTotal}
+	if *breakerFailures > 0 {
+		newsapi.breaker = newCircuitBreaker(*breakerFailures, *breakerCooldown)
+	}
+	newsapi.debug = *debugUpstream
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -169,24 +909,99 @@ func main() {
 	}
 
 	/* creates new HTTP request multiplexer and assigns it to mux -
-	a request multiplexer matches the URL of incoming requests against a list 
+	a request multiplexer matches the URL of incoming requests against a list
 	of registered paths and calls the associated handler for the path whenever a match is found */
 	mux := http.NewServeMux()
 
-
-	// create one handler to take care of serving all static assets.
-	fs := http.FileServer(http.Dir("assets"))
+	// create one handler to take care of serving all static assets, -assets-override ones first
+	fs := http.FileServer(assetsFS(*assetsOverride))
 
 	//direct the router to use this file server object for all paths beginning with the /assets/ prefix
 	mux.Handle("/assets/", http.StripPrefix("/assets/", fs))
 
 	// direct urls with /search
 	mux.HandleFunc("/search", searchHandler)
+	// the next few results of a search, for the results page's infinite scroll
+	mux.HandleFunc("/search/more", moreHandler)
 
-	// register handler function for the root path '/' and 
+	mux.HandleFunc("/sitemap.xml", sitemapHandler)
+
+	// article previews for the results page dialog
+	mux.HandleFunc("/preview", previewHandler)
+
+	// "surprise me", a search for a random topic
+	mux.HandleFunc("/random", randomHandler)
+
+	// article thumbnails, fetched and resized on our side
+	mux.HandleFunc("/img", imageHandler)
+
+	// remembers the grid/list layout choice
+	mux.HandleFunc("/view", viewHandler)
+
+	// JSON and newline-delimited JSON versions of the same search, described by /openapi.json
+	mux.HandleFunc("/search.json", searchJSONHandler)
+	mux.HandleFunc("/openapi.json", openAPIHandler)
+	mux.HandleFunc("/facets", facetsHandler)
+	mux.HandleFunc("/count", countHandler)
+	mux.HandleFunc("/meta", metaHandler)
+
+	if *articleReader {
+		mux.HandleFunc("/article", articleHandler)
+	}
+
+	// bookmarks, kept in a signed cookie rather than a database
+	mux.HandleFunc("/saved", savedHandler)
+	mux.HandleFunc("/saved/add", savedAddHandler)
+	mux.HandleFunc("/saved/remove", savedRemoveHandler)
+
+	// named searches, in a signed cookie like the bookmarks
+	mux.HandleFunc("/presets", presetsHandler)
+	mux.HandleFunc("/presets/save", presetSaveHandler)
+	mux.HandleFunc("/presets/delete", presetDeleteHandler)
+
+	// newline-delimited JSON export of the same search
+	mux.HandleFunc("/search.ndjson", searchNDJSONHandler)
+
+	// health checks for the platform, /readyz goes red during maintenance
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
+
+	mux.HandleFunc("/admin/maintenance", requireAdmin(maintenanceHandler))
+	mux.HandleFunc("/admin/verify-key", requireAdmin(verifyKeyHandler))
+	mux.HandleFunc("/debug/query", requireAdmin(debugQueryHandler))
+
+	// in-memory counters, for deployments without a metrics stack
+	if *statsEnabled {
+		mux.HandleFunc("/stats", statsHandler)
+		mux.HandleFunc("/admin/stats/reset", requireAdmin(statsResetHandler))
+	}
+	if *inlineImagesRate > 0 {
+		inlineImagesLimiter = newWindowLimiter(*inlineImagesRate, inlineImagesWindow)
+	}
+	if *beaconEnabled {
+		beaconLimiter = newWindowLimiter(*beaconRate, beaconWindow)
+		mux.HandleFunc("/beacon", beaconHandler)
+	}
+
+	// register handler function for the root path '/' and
 	//second argument - handler fuction taking in the request and writing the response
 	mux.HandleFunc("/", indexHandler)
 
-	//starts the server on defined port
-	http.ListenAndServe(":"+port, mux)
+	handler := withBodyLimit(*maxBodyBytes, withSecurityHeaders(withMaintenance(mux)))
+	if *slashRedirect {
+		handler = withoutTrailingSlash(handler)
+	}
+
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           withTracing(withStats(handler)),
+		ReadHeaderTimeout: *readHeaderTimeout,
+		ReadTimeout:       *readTimeout,
+		WriteTimeout:      *writeTimeout,
+		IdleTimeout:       *idleTimeout,
+		MaxHeaderBytes:    *maxHeaderBytes,
+	}
+
+	//starts the server on defined port, over HTTPS when a certificate or domain is configured
+	log.Fatal(serve(srv, tlsOpts))
 }
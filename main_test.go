@@ -0,0 +1,743 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMain gives the flag variables their defaults, as main would for a server started without
+// flags, so handlers can run; tests change them with setFlag
+func TestMain(m *testing.M) {
+	apiKey = ptr("test-key")
+	defaultPageSize = ptr(20)
+	collapseSimilar = ptr(0.0)
+	dedupePages = ptr(false)
+	loadMoreSize = ptr(10)
+	maxPages = ptr(0)
+	displayLimit = ptr(0)
+	mergeHeadlines = ptr(false)
+	headlinesCountry = ptr("us")
+	sourceLabels = ptr(false)
+	siteTitle = ptr("News Headlines")
+	siteDescription = ptr("Search the latest news from thousands of sources.")
+	homeQuery = ptr("")
+	readerPrefix = ptr("")
+	debugPageOverlap = ptr(false)
+	adminToken = ptr("")
+	trendingWindow = ptr(24 * time.Hour)
+	minQueryLength = ptr(2)
+	detectLanguages = ptr(false)
+	faviconService = ptr("")
+	baseURL = ptr("")
+	trustProxy = ptr(false)
+	beaconEnabled = ptr(false)
+	articleReader = ptr(false)
+	breakingWindow = ptr(time.Hour)
+
+	cardFields, _ = parseCardFields(defaultCardFields)
+	shortcuts, _ = parseShortcuts(defaultShortcuts)
+	smartWeights = smartOptions{recencyWeight: 1, imageWeight: 0.3, sourceWeight: 0.5, halfLife: 6 * time.Hour}
+	safeSearchTerms = defaultSafeSearchTerms
+	safeSearchDomains = defaultSafeSearchDomains
+	compileBlocklist()
+	queryHooks = defaultQueryHooks(false, false)
+	history = newSearchHistory(1000)
+	setSigningKey("test secret")
+
+	os.Exit(m.Run())
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// setFlag sets the flag variable for the test, putting the old value back after it
+func setFlag[T any](t testing.TB, flag **T, value T) {
+	t.Helper()
+	old := *flag
+	*flag = &value
+	t.Cleanup(func() { *flag = old })
+}
+
+// setVar is setFlag for the package variables main fills in from flags
+func setVar[T any](t testing.TB, v *T, value T) {
+	t.Helper()
+	old := *v
+	*v = value
+	t.Cleanup(func() { *v = old })
+}
+
+// setBlocklist sets the blocked words and safe search terms for the test and compiles them,
+// as main does at startup
+func setBlocklist(t testing.TB, blocked, safe []string) {
+	t.Helper()
+	// registered first, so it runs once setVar has put the old words back
+	t.Cleanup(compileBlocklist)
+	setVar(t, &blockedWords, blocked)
+	setVar(t, &safeSearchTerms, safe)
+	compileBlocklist()
+}
+
+// fakeNewsAPI is a newsapi.org serving total numbered articles, newest first, counting the
+// article requests it gets
+type fakeNewsAPI struct {
+	*httptest.Server
+	total int
+	hits  atomic.Int32
+}
+
+func newFakeNewsAPI(t *testing.T, total int) *fakeNewsAPI {
+	t.Helper()
+	f := &fakeNewsAPI{total: total}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.hits.Add(1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+		page, pageSize = max(page, 1), max(pageSize, 1)
+		var articles []Articles
+		for n := (page-1)*pageSize + 1; n <= min(page*pageSize, f.total); n++ {
+			articles = append(articles, testArticle(n))
+		}
+		json.NewEncoder(w).Encode(Results{Status: "ok", TotalResults: f.total, Articles: articles})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// testArticle is the nth article of a fakeNewsAPI, published n minutes ago
+func testArticle(n int) Articles {
+	return Articles{
+		Source:      Source{Name: "Example News"},
+		Title:       fmt.Sprintf("Story %d", n),
+		Description: fmt.Sprintf("What happened in story %d", n),
+		URL:         fmt.Sprintf("https://news.example.com/a/%d", n),
+		PublishedAt: Timestamp{time.Now().Add(-time.Duration(n) * time.Minute).Truncate(time.Second)},
+	}
+}
+
+// useNewsAPI points the handlers at a client for srv with an empty cache, until the test ends
+func useNewsAPI(t testing.TB, srv *httptest.Server) *NewsClient {
+	t.Helper()
+	c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(100), time.Minute)
+	setVar(t, &newsapi, c)
+	return c
+}
+
+// get runs handler on a GET of target and returns the recorded response
+func get(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	return do(handler, http.MethodGet, target)
+}
+
+// do runs handler on a bodiless request and returns the recorded response
+func do(handler http.HandlerFunc, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestPageSizeParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{name: "flag default", query: "", want: 30},
+		{name: "override", query: "pageSize=50", want: 50},
+		{name: "smallest", query: "pageSize=1", want: 1},
+		{name: "largest", query: "pageSize=100", want: 100},
+		{name: "zero", query: "pageSize=0", wantErr: true},
+		{name: "too large", query: "pageSize=101", wantErr: true},
+		{name: "not a number", query: "pageSize=ten", wantErr: true},
+		{name: "given twice", query: "pageSize=10&pageSize=20", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &defaultPageSize, 30)
+			params, _ := url.ParseQuery(tt.query)
+			got, err := pageSizeParam(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pageSizeParam(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pageSizeParam(%q) = %d, want %d", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBreaking(t *testing.T) {
+	tests := []struct {
+		name string
+		age  time.Duration
+		want bool
+	}{
+		{name: "just published", age: 0, want: true},
+		{name: "59 minutes old", age: 59 * time.Minute, want: true},
+		{name: "61 minutes old", age: 61 * time.Minute, want: false},
+		{name: "a day old", age: 24 * time.Hour, want: false},
+		{name: "in the future", age: -time.Hour, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &breakingWindow, time.Hour)
+			a := Articles{PublishedAt: Timestamp{time.Now().Add(-tt.age)}}
+			if got := a.IsBreaking(); got != tt.want {
+				t.Errorf("IsBreaking() at %s old = %v, want %v", tt.age, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnounce(t *testing.T) {
+	tests := []struct {
+		name  string
+		total int
+		want  string
+	}{
+		{name: "no results", total: 0, want: "No results found for go"},
+		{name: "one result", total: 1, want: "Found 1 result for go"},
+		{name: "several results", total: 37, want: "Found 37 results for go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Search{SearchKey: "go", Results: Results{TotalResults: tt.total}}
+			if got := announce(s); got != tt.want {
+				t.Errorf("announce() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchHandlerAnnouncesResults(t *testing.T) {
+	tests := []struct {
+		name  string
+		total int
+		want  string
+	}{
+		{name: "results", total: 37, want: `aria-live="polite">Found 37 results for go</div>`},
+		{name: "none", total: 0, want: `aria-live="polite">No results found for go</div>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, newFakeNewsAPI(t, tt.total).Server)
+			w := get(searchHandler, "/search?q=go")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("page lacks %s", tt.want)
+			}
+			if !strings.Contains(body, "<title>go - News Headlines</title>") {
+				t.Error("page title doesn't name the query")
+			}
+		})
+	}
+}
+
+func TestReaderURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		reader bool
+		url    string
+		want   string
+	}{
+		{name: "no proxy", url: "https://news.example.com/a/1", want: "https://news.example.com/a/1"},
+		{name: "proxy prefix", prefix: "https://r.jina.ai/", url: "https://news.example.com/a/1", want: "https://r.jina.ai/https://news.example.com/a/1"},
+		{name: "not http", prefix: "https://r.jina.ai/", url: "javascript:alert(1)", want: "javascript:alert(1)"},
+		{name: "no host", prefix: "https://r.jina.ai/", url: "/a/1", want: "/a/1"},
+		{name: "own extractor", reader: true, url: "https://news.example.com/a/1?x=1", want: "/article?url=https%3A%2F%2Fnews.example.com%2Fa%2F1%3Fx%3D1"},
+		{name: "proxy beats the extractor", prefix: "https://r.jina.ai/", reader: true, url: "https://news.example.com/a/1", want: "https://r.jina.ai/https://news.example.com/a/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &readerPrefix, tt.prefix)
+			setFlag(t, &articleReader, tt.reader)
+			a := Articles{URL: tt.url}
+			if got := a.ReaderURL(); got != tt.want {
+				t.Errorf("ReaderURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// newErrorNewsAPI is a newsapi.org answering every request with status and body
+func newErrorNewsAPI(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSearchHandlerMaximumResultsReached(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{
+			name:       "plan limit",
+			status:     http.StatusUpgradeRequired,
+			body:       `{"status":"error","code":"maximumResultsReached","message":"You have requested too many results."}`,
+			wantStatus: http.StatusOK,
+			want:       "You&#39;ve reached the maximum available results for this plan.",
+		},
+		{
+			name:       "other newsapi error",
+			status:     http.StatusBadRequest,
+			body:       `{"status":"error","code":"parameterInvalid","message":"Bad q"}`,
+			wantStatus: http.StatusInternalServerError,
+			want:       "Bad q",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, newErrorNewsAPI(t, tt.status, tt.body))
+			w := get(searchHandler, "/search?q=go&page=3")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("response lacks %q", tt.want)
+			}
+		})
+	}
+}
+
+func TestLastAvailablePage(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int
+		pageSize int
+		maxPages int
+		want     int
+	}{
+		{name: "fewer than the cap", total: 37, pageSize: 20, want: 2},
+		{name: "capped at the free tier", total: 5000, pageSize: 20, want: 5},
+		{name: "cap with odd page size", total: 5000, pageSize: 30, want: 3},
+		{name: "no results", total: 0, pageSize: 20, want: 1},
+		{name: "max-pages", total: 5000, pageSize: 10, maxPages: 4, want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &maxPages, tt.maxPages)
+			if got := lastAvailablePage(tt.total, tt.pageSize); got != tt.want {
+				t.Errorf("lastAvailablePage(%d, %d) = %d, want %d", tt.total, tt.pageSize, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapPages(t *testing.T) {
+	tests := []struct {
+		pages, limit, want int
+	}{
+		{pages: 5, limit: 0, want: 5},
+		{pages: 5, limit: 3, want: 3},
+		{pages: 5, limit: 5, want: 5},
+		{pages: 2, limit: 3, want: 2},
+		{pages: 5, limit: -1, want: 5},
+	}
+	for _, tt := range tests {
+		if got := capPages(tt.pages, tt.limit); got != tt.want {
+			t.Errorf("capPages(%d, %d) = %d, want %d", tt.pages, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestMaxPagesNotice(t *testing.T) {
+	const notice = "Further results are not available, try narrowing your search."
+	tests := []struct {
+		name       string
+		total      int
+		maxPages   int
+		target     string
+		wantNotice bool
+		wantNext   bool
+	}{
+		{name: "before the cap", total: 5000, maxPages: 2, target: "/search?q=go&page=1", wantNext: true},
+		{name: "at the cap", total: 5000, maxPages: 2, target: "/search?q=go&page=2", wantNotice: true},
+		{name: "past the cap", total: 5000, maxPages: 2, target: "/search?q=go&page=3", wantNotice: false},
+		{name: "results end before the cap", total: 30, maxPages: 2, target: "/search?q=go&page=2"},
+		{name: "free tier cap", total: 5000, maxPages: 0, target: "/search?q=go&page=5", wantNotice: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeNewsAPI(t, tt.total)
+			useNewsAPI(t, api.Server)
+			setFlag(t, &maxPages, tt.maxPages)
+			w := get(searchHandler, tt.target)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if got := strings.Contains(w.Body.String(), notice); got != tt.wantNotice {
+				t.Errorf("further results notice shown = %v, want %v", got, tt.wantNotice)
+			}
+			if got := strings.Contains(w.Body.String(), `class="button next-page"`); got != tt.wantNext {
+				t.Errorf("next page link shown = %v, want %v", got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestNewSearchRejectsRepeatedParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{name: "single values", query: "q=go&page=2&pageSize=10"},
+		{name: "repeated q", query: "q=go&q=rust", wantErr: "q was given 2 times, it may only be given once"},
+		{name: "repeated page", query: "q=go&page=1&page=2", wantErr: "page was given 2 times, it may only be given once"},
+		{name: "repeated pageSize", query: "q=go&pageSize=10&pageSize=10", wantErr: "pageSize was given 2 times, it may only be given once"},
+		{name: "repeated sortBy", query: "q=go&sortBy=relevancy&sortBy=popularity&sortBy=relevancy", wantErr: "sortBy was given 3 times, it may only be given once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, _ := url.ParseQuery(tt.query)
+			_, err := newSearch(params)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("newSearch(%q): %v", tt.query, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("newSearch(%q) error = %v, want %q", tt.query, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDisplayLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		blocked []string
+		want    []string
+	}{
+		{name: "no limit", limit: 0, want: []string{"Story 1", "Story 2", "Story 3", "Story 4", "Story 5", "Story 6"}},
+		{name: "limited", limit: 2, want: []string{"Story 1", "Story 2"}},
+		{name: "above the page", limit: 50, want: []string{"Story 1", "Story 2", "Story 3", "Story 4", "Story 5", "Story 6"}},
+		{name: "after filtering", limit: 2, blocked: []string{"1"}, want: []string{"Story 2", "Story 3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, newFakeNewsAPI(t, 100).Server)
+			setFlag(t, &displayLimit, tt.limit)
+			setBlocklist(t, tt.blocked, safeSearchTerms)
+			s, err := newSearch(url.Values{"q": {"go"}, "pageSize": {"6"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.fetch(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := titles(s.Results.Articles); !slices.Equal(got, tt.want) {
+				t.Errorf("articles = %q, want %q", got, tt.want)
+			}
+			if s.Results.TotalResults != 100 || s.fetched != 6 {
+				t.Errorf("TotalResults = %d, fetched = %d, want 100 and 6", s.Results.TotalResults, s.fetched)
+			}
+		})
+	}
+}
+
+func TestClientGone(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{name: "no error", ctx: canceled, err: nil, want: false},
+		{name: "client still there", ctx: context.Background(), err: errors.New("newsapi down"), want: false},
+		{name: "client disconnected", ctx: canceled, err: context.Canceled, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/search?q=go", nil).WithContext(tt.ctx)
+			if got := clientGone(r, tt.err); got != tt.want {
+				t.Errorf("clientGone() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlersStopWhenTheClientIsGone(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+	}{
+		{name: "search page", handler: searchHandler, target: "/search?q=go"},
+		{name: "search json", handler: searchJSONHandler, target: "/search.json?q=go"},
+		{name: "facets", handler: facetsHandler, target: "/facets?q=go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, newFakeNewsAPI(t, 5).Server)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(http.MethodGet, tt.target, nil).WithContext(ctx))
+			if w.Body.Len() != 0 {
+				t.Errorf("wrote %q to a client that left", w.Body)
+			}
+		})
+	}
+}
+
+func TestHeadSkipsTheFetch(t *testing.T) {
+	tests := []struct {
+		name            string
+		handler         http.HandlerFunc
+		target          string
+		wantStatus      int
+		wantContentType string
+	}{
+		{name: "search page", handler: searchHandler, target: "/search?q=go", wantStatus: http.StatusOK, wantContentType: "text/html; charset=utf-8"},
+		{name: "search json", handler: searchJSONHandler, target: "/search.json?q=go", wantStatus: http.StatusOK, wantContentType: "application/json"},
+		{name: "search ndjson", handler: searchNDJSONHandler, target: "/search.ndjson?q=go", wantStatus: http.StatusOK, wantContentType: "application/x-ndjson"},
+		{name: "facets", handler: facetsHandler, target: "/facets?q=go", wantStatus: http.StatusOK, wantContentType: "application/json"},
+		{name: "count", handler: countHandler, target: "/count?q=go", wantStatus: http.StatusOK, wantContentType: "application/json"},
+		{name: "params still checked", handler: searchHandler, target: "/search?q=go&q=rust", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeNewsAPI(t, 5)
+			useNewsAPI(t, api.Server)
+			w := do(tt.handler, http.MethodHead, tt.target)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := api.hits.Load(); got != 0 {
+				t.Errorf("HEAD made %d newsapi requests, want none", got)
+			}
+			if tt.wantContentType == "" {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("HEAD got a body: %q", w.Body)
+			}
+		})
+	}
+}
+
+func TestSearchHandlerEmptyPageNotice(t *testing.T) {
+	const notice = "No more articles are available for this search."
+	tests := []struct {
+		name       string
+		total      int
+		target     string
+		wantNotice bool
+		wantLink   string
+	}{
+		{name: "counted but empty", total: 50, target: "/search?q=go", wantNotice: true},
+		{name: "later page links back", total: 50, target: "/search?q=go&page=3", wantNotice: true, wantLink: `href="/search?page=2&amp;q=go"`},
+		{name: "nothing counted", total: 0, target: "/search?q=go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(Results{Status: "ok", TotalResults: tt.total, Articles: []Articles{}})
+			}))
+			t.Cleanup(srv.Close)
+			useNewsAPI(t, srv)
+			w := get(searchHandler, tt.target)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			body := w.Body.String()
+			if got := strings.Contains(body, notice); got != tt.wantNotice {
+				t.Errorf("notice shown = %v, want %v", got, tt.wantNotice)
+			}
+			if tt.wantLink != "" && !strings.Contains(body, tt.wantLink) {
+				t.Errorf("page lacks the link back, %s", tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestTotalPages(t *testing.T) {
+	tests := []struct {
+		total    int
+		pageSize int
+		want     int
+	}{
+		{total: 40, pageSize: 20, want: 2},
+		{total: 41, pageSize: 20, want: 3},
+		{total: 19, pageSize: 20, want: 1},
+		{total: 0, pageSize: 20, want: 0},
+		{total: 5, pageSize: 0, want: 5},
+		{total: 5, pageSize: -3, want: 5},
+		{total: -1, pageSize: 20, want: 0},
+	}
+	for _, tt := range tests {
+		if got := totalPages(tt.total, tt.pageSize); got != tt.want {
+			t.Errorf("totalPages(%d, %d) = %d, want %d", tt.total, tt.pageSize, got, tt.want)
+		}
+	}
+}
+
+func TestSearchPaging(t *testing.T) {
+	tests := []struct {
+		name         string
+		nextPage     int
+		totalPages   int
+		wantCurrent  int
+		wantPrevious int
+		wantLast     bool
+	}{
+		{name: "not fetched yet", nextPage: 1, totalPages: 0, wantCurrent: 1, wantPrevious: 0, wantLast: true},
+		{name: "first of three", nextPage: 2, totalPages: 3, wantCurrent: 1, wantPrevious: 0},
+		{name: "middle", nextPage: 3, totalPages: 3, wantCurrent: 2, wantPrevious: 1},
+		{name: "last", nextPage: 4, totalPages: 3, wantCurrent: 3, wantPrevious: 2, wantLast: true},
+		{name: "only page", nextPage: 2, totalPages: 1, wantCurrent: 1, wantPrevious: 0, wantLast: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Search{NextPage: tt.nextPage, TotalPages: tt.totalPages}
+			if got := s.CurrentPage(); got != tt.wantCurrent {
+				t.Errorf("CurrentPage() = %d, want %d", got, tt.wantCurrent)
+			}
+			if got := s.PreviousPage(); got != tt.wantPrevious {
+				t.Errorf("PreviousPage() = %d, want %d", got, tt.wantPrevious)
+			}
+			if got := s.IsLastPage(); got != tt.wantLast {
+				t.Errorf("IsLastPage() = %v, want %v", got, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestSearchHandlerPageLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int
+		target   string
+		wantNext bool
+		wantPrev bool
+	}{
+		{name: "first of two", total: 37, target: "/search?q=go", wantNext: true},
+		{name: "last of two", total: 37, target: "/search?q=go&page=2", wantPrev: true},
+		{name: "exactly one page", total: 20, target: "/search?q=go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, newFakeNewsAPI(t, tt.total).Server)
+			w := get(searchHandler, tt.target)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			body := w.Body.String()
+			if got := strings.Contains(body, `href="/search?page=2&amp;q=go"`); got != tt.wantNext {
+				t.Errorf("link to page 2 = %v, want %v", got, tt.wantNext)
+			}
+			if got := strings.Contains(body, `href="/search?page=1&amp;q=go"`); got != tt.wantPrev {
+				t.Errorf("link to page 1 = %v, want %v", got, tt.wantPrev)
+			}
+		})
+	}
+}
+
+func TestSearchHandlerStaleNotice(t *testing.T) {
+	const notice = "Showing cached results, new articles can't be fetched right now."
+	tests := []struct {
+		name       string
+		failing    bool
+		wantNotice bool
+		wantCache  string
+	}{
+		{name: "fresh", failing: false, wantCache: "private, max-age=60"},
+		{name: "stale while rate limited", failing: true, wantNotice: true, wantCache: "private, no-cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var failing atomic.Bool
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if failing.Load() {
+					w.WriteHeader(http.StatusTooManyRequests)
+					w.Write([]byte(`{"status":"error","code":"rateLimited","message":"Too many requests"}`))
+					return
+				}
+				json.NewEncoder(w).Encode(Results{Status: "ok", TotalResults: 1, Articles: []Articles{testArticle(1)}})
+			}))
+			t.Cleanup(srv.Close)
+			c := useNewsAPI(t, srv)
+			c.staleTTL, c.staleCache = time.Hour, newLRUCache(10)
+			get(searchHandler, "/search?q=go")
+
+			// the fresh copy is gone, only the stale one is left
+			c.cache = newLRUCache(10)
+			failing.Store(tt.failing)
+			w := get(searchHandler, "/search?q=go")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if got := strings.Contains(w.Body.String(), notice); got != tt.wantNotice {
+				t.Errorf("stale notice shown = %v, want %v", got, tt.wantNotice)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+		})
+	}
+}
+
+func TestPastLastPageSkipsTheFetch(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		target     string
+		wantStatus int
+		want       string
+		wantHits   int32
+	}{
+		{name: "last page", handler: searchHandler, target: "/search?q=go&page=5", wantStatus: http.StatusOK, want: "Story 81", wantHits: 1},
+		{name: "past it", handler: searchHandler, target: "/search?q=go&page=6", wantStatus: http.StatusOK, want: `You&#39;re past the last page of results that can be shown.`},
+		{name: "notice links the last page", handler: searchHandler, target: "/search?q=go&page=50", wantStatus: http.StatusOK, want: `href="/search?page=5&amp;q=go"`},
+		{name: "smaller pages go further", handler: searchHandler, target: "/search?q=go&page=10&pageSize=10", wantStatus: http.StatusOK, want: "Story 91", wantHits: 1},
+		{name: "JSON", handler: searchJSONHandler, target: "/search.json?q=go&page=6", wantStatus: http.StatusBadRequest, want: "the last page is 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeNewsAPI(t, 5000)
+			useNewsAPI(t, api.Server)
+			w := get(tt.handler, tt.target)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("response lacks %q", tt.want)
+			}
+			if got := api.hits.Load(); got != tt.wantHits {
+				t.Errorf("newsapi got %d requests, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// metaEntry is a code newsapi accepts and the name the UI shows for it
+type metaEntry struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// The codes newsapi accepts, served by /meta and used to validate the matching params,
+// so the dropdowns a frontend builds from /meta never offer something we'd reject.
+var (
+	newsapiCategories = []metaEntry{
+		{"business", "Business"}, {"entertainment", "Entertainment"}, {"general", "General"},
+		{"health", "Health"}, {"science", "Science"}, {"sports", "Sports"}, {"technology", "Technology"},
+	}
+
+	newsapiLanguages = []metaEntry{
+		{"ar", "Arabic"}, {"de", "German"}, {"en", "English"}, {"es", "Spanish"}, {"fr", "French"},
+		{"he", "Hebrew"}, {"it", "Italian"}, {"nl", "Dutch"}, {"no", "Norwegian"}, {"pt", "Portuguese"},
+		{"ru", "Russian"}, {"sv", "Swedish"}, {"ud", "Urdu"}, {"zh", "Chinese"},
+	}
+
+	newsapiCountries = []metaEntry{
+		{"ae", "United Arab Emirates"}, {"ar", "Argentina"}, {"at", "Austria"}, {"au", "Australia"},
+		{"be", "Belgium"}, {"bg", "Bulgaria"}, {"br", "Brazil"}, {"ca", "Canada"},
+		{"ch", "Switzerland"}, {"cn", "China"}, {"co", "Colombia"}, {"cu", "Cuba"},
+		{"cz", "Czechia"}, {"de", "Germany"}, {"eg", "Egypt"}, {"fr", "France"},
+		{"gb", "United Kingdom"}, {"gr", "Greece"}, {"hk", "Hong Kong"}, {"hu", "Hungary"},
+		{"id", "Indonesia"}, {"ie", "Ireland"}, {"il", "Israel"}, {"in", "India"},
+		{"it", "Italy"}, {"jp", "Japan"}, {"kr", "South Korea"}, {"lt", "Lithuania"},
+		{"lv", "Latvia"}, {"ma", "Morocco"}, {"mx", "Mexico"}, {"my", "Malaysia"},
+		{"ng", "Nigeria"}, {"nl", "Netherlands"}, {"no", "Norway"}, {"nz", "New Zealand"},
+		{"ph", "Philippines"}, {"pl", "Poland"}, {"pt", "Portugal"}, {"ro", "Romania"},
+		{"rs", "Serbia"}, {"ru", "Russia"}, {"sa", "Saudi Arabia"}, {"se", "Sweden"},
+		{"sg", "Singapore"}, {"si", "Slovenia"}, {"sk", "Slovakia"}, {"th", "Thailand"},
+		{"tr", "Turkey"}, {"tw", "Taiwan"}, {"ua", "Ukraine"}, {"us", "United States"},
+		{"ve", "Venezuela"}, {"za", "South Africa"},
+	}
+)
+
+// metaName returns the name of code in entries, ok is false when newsapi doesn't accept the code
+func metaName(entries []metaEntry, code string) (name string, ok bool) {
+	for _, e := range entries {
+		if e.Code == code {
+			return e.Name, true
+		}
+	}
+	return "", false
+}
+
+// metaHandler lists the categories, languages and countries for frontends to build dropdowns from
+func metaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// the lists only change with a deploy
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	err := json.NewEncoder(w).Encode(map[string][]metaEntry{
+		"categories": newsapiCategories,
+		"languages":  newsapiLanguages,
+		"countries":  newsapiCountries,
+	})
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMetaName(t *testing.T) {
+	tests := []struct {
+		entries []metaEntry
+		code    string
+		want    string
+		wantOK  bool
+	}{
+		{entries: newsapiLanguages, code: "de", want: "German", wantOK: true},
+		{entries: newsapiCountries, code: "gb", want: "United Kingdom", wantOK: true},
+		{entries: newsapiCategories, code: "science", want: "Science", wantOK: true},
+		{entries: newsapiLanguages, code: "DE"},
+		{entries: newsapiCountries, code: "uk"},
+		{entries: newsapiLanguages, code: ""},
+	}
+	for _, tt := range tests {
+		got, ok := metaName(tt.entries, tt.code)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("metaName(%q) = %q, %v, want %q, %v", tt.code, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestMetaHandler(t *testing.T) {
+	w := get(metaHandler, "/meta")
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=86400" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+	var body map[string][]metaEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		list string
+		want []metaEntry
+	}{
+		{list: "categories", want: newsapiCategories},
+		{list: "languages", want: newsapiLanguages},
+		{list: "countries", want: newsapiCountries},
+	}
+	for _, tt := range tests {
+		if got := body[tt.list]; len(got) != len(tt.want) || got[0] != tt.want[0] {
+			t.Errorf("%s = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+}
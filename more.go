@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// loadMoreSize is how many articles each "load more" call returns, set by -load-more-size
+var loadMoreSize *int
+
+// moreResults is the data of the "more" fragment: the batch, in place of the page's articles,
+// with how many of the page are left after it and where the batch after it is
+type moreResults struct {
+	*Search
+	Remaining int
+	NextURL   string
+}
+
+// loadMoreBatch is the size articles from offset on, and how many are left after them. The
+// last batch of a page may be short, an offset at or past its end gives an empty one.
+func loadMoreBatch(articles []Articles, offset, size int) (batch []Articles, remaining int) {
+	if offset >= len(articles) {
+		return nil, 0
+	}
+	end := min(offset+size, len(articles))
+	return articles[offset:end], len(articles) - end
+}
+
+// offsetParam reads the optional offset into the page, 0 when missing
+func offsetParam(params url.Values) (int, error) {
+	o, err := singleParam(params, "offset")
+	if err != nil || o == "" {
+		return 0, err
+	}
+	n, err := strconv.Atoi(o)
+	if err != nil || n < 0 || n >= maxPageSize {
+		return 0, errors.New("offset must be between 0 and " + strconv.Itoa(maxPageSize-1))
+	}
+	return n, nil
+}
+
+// MoreURL is the first "load more" batch after this page, "" on the last page. It is called
+// once the page has been fetched, when NextPage is the page after it.
+func (s *Search) MoreURL() string {
+	if s.IsLastPage() {
+		return ""
+	}
+	return s.moreURL(s.NextPage, 0)
+}
+
+func (s *Search) moreURL(page, offset int) string {
+	v := s.linkParams()
+	v.Set("page", strconv.Itoa(page))
+	v.Set("offset", strconv.Itoa(offset))
+	return "/search/more?" + v.Encode()
+}
+
+// moreHandler renders the next few results as an HTML fragment for infinite scroll: the
+// -load-more-size articles of page from offset on, linking the batch after them. Pages are
+// fetched whole, so the calls walking through one page are cache hits after the first.
+func moreHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	search, err := newSearch(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	offset, err := offsetParam(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	search.ViewMode = viewMode(r)
+	search.Variant = variantParam(params)
+	search.readSafeSearch(w, r)
+	// batches of the results page, headlines included, so their offsets line up with it
+	search.withHeadlines = true
+
+	if err := search.fetch(r.Context()); err != nil {
+		if clientGone(r, err) {
+			return
+		}
+		writeFetchError(w, err)
+		return
+	}
+	page := search.NextPage
+	search.NextPage++
+	search.TotalPages = lastAvailablePage(search.Results.TotalResults, search.PageSize)
+
+	search.Results.Articles = withoutInvalidURLs(search.Results.Articles)
+	// the whole page is filtered, so the offsets of its batches stay put
+	if *dedupePages || *debugPageOverlap {
+		search.trackSeen(w, r)
+	}
+
+	more := &moreResults{Search: search}
+	search.Results.Articles, more.Remaining = loadMoreBatch(search.Results.Articles, offset, *loadMoreSize)
+	switch {
+	case more.Remaining > 0:
+		more.NextURL = search.moreURL(page, offset+*loadMoreSize)
+	case search.fetched > 0 && !search.IsLastPage():
+		more.NextURL = search.moreURL(page+1, 0)
+	}
+
+	w.Header().Set("X-Remaining", strconv.Itoa(more.Remaining))
+	setCacheControl(w, "private", search.Results)
+	if err := tpl.Get().ExecuteTemplate(w, "more", more); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestLoadMoreBatch(t *testing.T) {
+	articles := make([]Articles, 25)
+	for i := range articles {
+		articles[i].Title = strconv.Itoa(i)
+	}
+	tests := []struct {
+		name          string
+		offset        int
+		wantFirst     string
+		wantLen       int
+		wantRemaining int
+	}{
+		{name: "first batch", offset: 0, wantFirst: "0", wantLen: 10, wantRemaining: 15},
+		{name: "middle", offset: 10, wantFirst: "10", wantLen: 10, wantRemaining: 5},
+		{name: "short last batch", offset: 20, wantFirst: "20", wantLen: 5, wantRemaining: 0},
+		{name: "at the end", offset: 25, wantLen: 0, wantRemaining: 0},
+		{name: "past the end", offset: 40, wantLen: 0, wantRemaining: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch, remaining := loadMoreBatch(articles, tt.offset, 10)
+			if len(batch) != tt.wantLen || remaining != tt.wantRemaining {
+				t.Fatalf("loadMoreBatch(%d) = %d articles and %d left, want %d and %d", tt.offset, len(batch), remaining, tt.wantLen, tt.wantRemaining)
+			}
+			if tt.wantLen > 0 && batch[0].Title != tt.wantFirst {
+				t.Errorf("batch starts at %s, want %s", batch[0].Title, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestOffsetParam(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{query: "", want: 0},
+		{query: "offset=10", want: 10},
+		{query: "offset=99", want: 99},
+		{query: "offset=100", wantErr: true},
+		{query: "offset=-1", wantErr: true},
+		{query: "offset=ten", wantErr: true},
+		{query: "offset=1&offset=2", wantErr: true},
+	}
+	for _, tt := range tests {
+		params, _ := url.ParseQuery(tt.query)
+		got, err := offsetParam(params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("offsetParam(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("offsetParam(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
+
+var (
+	moreNextPattern  = regexp.MustCompile(`data-next="([^"]*)"`)
+	moreTitlePattern = regexp.MustCompile(`<h3 class="title">(?:<span[^>]*>[^<]*</span> )*([^<]+)</h3>`)
+)
+
+func TestMoreHandlerWalk(t *testing.T) {
+	api := newFakeNewsAPI(t, 25)
+	useNewsAPI(t, api.Server)
+	tests := []struct {
+		wantFirst     string
+		wantCount     int
+		wantRemaining string
+		wantNext      bool
+	}{
+		{wantFirst: "Story 1", wantCount: 10, wantRemaining: "10", wantNext: true},
+		{wantFirst: "Story 11", wantCount: 10, wantRemaining: "0", wantNext: true},
+		{wantFirst: "Story 21", wantCount: 5, wantRemaining: "0", wantNext: false},
+	}
+	target := "/search/more?q=go&page=1&offset=0"
+	for i, tt := range tests {
+		w := get(moreHandler, target)
+		if w.Code != http.StatusOK {
+			t.Fatalf("batch %d: status = %d: %s", i, w.Code, w.Body)
+		}
+		var got []string
+		for _, m := range moreTitlePattern.FindAllStringSubmatch(w.Body.String(), -1) {
+			got = append(got, m[1])
+		}
+		if len(got) != tt.wantCount || got[0] != tt.wantFirst {
+			t.Errorf("batch %d = %q, want %d articles from %s", i, got, tt.wantCount, tt.wantFirst)
+		}
+		if r := w.Header().Get("X-Remaining"); r != tt.wantRemaining {
+			t.Errorf("batch %d: X-Remaining = %s, want %s", i, r, tt.wantRemaining)
+		}
+		next := html.UnescapeString(moreNextPattern.FindStringSubmatch(w.Body.String())[1])
+		if (next != "") != tt.wantNext {
+			t.Fatalf("batch %d: next = %q, want one: %v", i, next, tt.wantNext)
+		}
+		target = next
+	}
+	// the batches of a page are cut from one fetch
+	if got := api.hits.Load(); got != 2 {
+		t.Errorf("newsapi got %d requests for two pages, want 2", got)
+	}
+}
+
+func TestMoreHandlerRejects(t *testing.T) {
+	tests := []string{
+		"/search/more?q=go&offset=100",
+		"/search/more?q=go&offset=-1",
+		"/search/more?q=go&pageSize=500",
+	}
+	for _, target := range tests {
+		if w := get(moreHandler, target); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, w.Code)
+		}
+	}
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+// NewsAPIError is the error payload newsapi.org returns for non-200 responses
+type NewsAPIError struct {
+	Status  string `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *NewsAPIError) Error() string {
+	return fmt.Sprintf("newsapi: %s: %s", e.Code, e.Message)
+}
+
+// error codes the handlers treat differently from a generic failure
+const (
+	// codeMaximumResultsReached means the request paged past what the plan allows (100 results on the free tier)
+	codeMaximumResultsReached = "maximumResultsReached"
+	// codeRateLimited means the key's request quota is used up, stale results are served if there are any
+	codeRateLimited = "rateLimited"
+	// codeUnexpectedError is newsapi's code for a failure on its side, worth asking again
+	codeUnexpectedError = "unexpectedError"
+	// codeUnexpectedStatus is ours, for a 200 whose status isn't "ok" and that carries no code
+	codeUnexpectedStatus = "unexpectedStatus"
+)
+
+// ErrNotConfigured means newsapi rejected our key, an operator problem rather than anything the user did
+var ErrNotConfigured = errors.New("news service not configured")
+
+// freeTierResultCap is how many results the newsapi developer plan will page through
+const freeTierResultCap = 100
+
+// apiErrorCode returns the newsapi error code carried by err, or "" when there is none
+func apiErrorCode(err error) string {
+	var apiErr *NewsAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// NewsClient wraps the newsapi.org endpoints used by the handlers
+type NewsClient struct {
+	http *http.Client
+	// base is the scheme and host requests go to, e.g. https://newsapi.org
+	base string
+	key  string
+
+	// flight collapses identical concurrent requests into one upstream call
+	flight singleflight.Group
+
+	// cache holds JSON encoded results for cacheTTL, zero disables it
+	cache    Cache
+	cacheTTL time.Duration
+	// staleTTL keeps a second copy of each result that is served, however old, while newsapi is
+	// rate limiting us or the breaker is open; zero disables it
+	staleTTL time.Duration
+	// staleCache holds those copies apart from cache, under its own bound, so the long-lived
+	// copies don't crowd out fresh results. Nil keeps them in cache.
+	staleCache Cache
+
+	// debug logs every upstream call, with the key redacted, at debug level
+	debug bool
+
+	// breaker, when set, stops calling newsapi during an outage
+	breaker *circuitBreaker
+
+	// retry bounds how often and for how long a failed upstream call is repeated, the zero
+	// value makes a single attempt
+	retry retryBudget
+}
+
+func NewNewsClient(httpClient *http.Client, base, key string, cache Cache, cacheTTL time.Duration) *NewsClient {
+	return &NewsClient{
+		http:     httpClient,
+		base:     strings.TrimSuffix(base, "/"),
+		key:      key,
+		cache:    cache,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// Everything fetches a single page of results from /v2/everything, params carries q, page and pageSize.
+// Concurrent calls for equivalent params share one request and get the same *Results,
+// so callers must treat it as read-only.
+//
+// The cache and singleflight key is built from params alone. The API key is added when the
+// request is sent and deliberately left out: any valid key gets the same results for the same
+// query, so rotating keys keeps the cache warm and never mixes up results between queries.
+//
+// The upstream request is tied to ctx, so it stops when the caller goes away. A shared request
+// runs under the context of the caller that started it; if that caller cancels, the others
+// retry with their own context instead of failing with someone else's cancellation.
+func (c *NewsClient) Everything(ctx context.Context, params url.Values) (*Results, error) {
+	return c.shared(ctx, endpointEverything, params)
+}
+
+// TopHeadlines fetches a page of newsapi's curated /v2/top-headlines, params carries q, page,
+// pageSize and optionally country or sources. It is cached and shared like Everything.
+func (c *NewsClient) TopHeadlines(ctx context.Context, params url.Values) (*Results, error) {
+	return c.shared(ctx, endpointTopHeadlines, params)
+}
+
+// the article endpoints, by their newsapi path
+const (
+	endpointEverything   = "everything"
+	endpointTopHeadlines = "top-headlines"
+)
+
+// shared runs one singleflight call per endpoint and params, see Everything. A cache hit is
+// answered straight away, without the goroutine and channel a singleflight call costs.
+func (c *NewsClient) shared(ctx context.Context, endpoint string, params url.Values) (*Results, error) {
+	key := endpoint + "|" + normalizeParams(params)
+	// a miss is left for load to count, so callers sharing a request count once as before
+	if results, ok := c.lookup(key); ok {
+		stats.cacheHits.Add(1)
+		return results, nil
+	}
+	ch := c.flight.DoChan(key, func() (interface{}, error) {
+		return c.load(ctx, key, endpoint, params)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			if isCanceled(res.Err) && ctx.Err() == nil {
+				return c.load(ctx, key, endpoint, params)
+			}
+			return nil, res.Err
+		}
+		return res.Val.(*Results), nil
+	}
+}
+
+// load serves params from the cache or newsapi, storing what newsapi returned. The cache is
+// looked at again, shared's miss may have been filled by a call that finished since.
+func (c *NewsClient) load(ctx context.Context, key, endpoint string, params url.Values) (*Results, error) {
+	if results, ok := c.cached(key); ok {
+		return results, nil
+	}
+
+	if c.breaker != nil {
+		if err := c.breaker.Allow(); err != nil {
+			if results, ok := c.stale(key); ok {
+				return results, nil
+			}
+			return nil, err
+		}
+	}
+	var results *Results
+	err := c.retry.do(ctx, func(ctx context.Context) (err error) {
+		results, err = c.articles(ctx, endpoint, params)
+		return err
+	})
+	if c.breaker != nil {
+		c.breaker.Record(err)
+	}
+	if err != nil {
+		if !isCanceled(err) {
+			stats.upstreamErrors.Add(1)
+		}
+		if apiErrorCode(err) == codeRateLimited {
+			if results, ok := c.stale(key); ok {
+				return results, nil
+			}
+		}
+		return nil, err
+	}
+	c.store(key, results)
+	return results, nil
+}
+
+// isCanceled reports whether err comes from a canceled or expired context
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (c *NewsClient) cached(key string) (*Results, bool) {
+	if c.cacheTTL <= 0 {
+		return nil, false
+	}
+	results, ok := c.lookup(key)
+	if ok {
+		stats.cacheHits.Add(1)
+	} else {
+		stats.cacheMisses.Add(1)
+	}
+	return results, ok
+}
+
+// lookup is cached without counting towards the stats
+func (c *NewsClient) lookup(key string) (*Results, bool) {
+	if c.cacheTTL <= 0 {
+		return nil, false
+	}
+	body, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	results := &Results{}
+	if err := json.Unmarshal(body, results); err != nil {
+		return nil, false
+	}
+	return results, true
+}
+
+func (c *NewsClient) store(key string, results *Results) {
+	if c.cacheTTL <= 0 {
+		return
+	}
+	body, err := json.Marshal(results)
+	if err != nil {
+		return
+	}
+	c.cache.Set(key, body, c.cacheTTL)
+	if c.staleTTL > 0 {
+		c.staleStore().Set(staleKey(key), body, c.staleTTL)
+	}
+}
+
+// staleStore is where the stale copies are kept, staleCache or else cache
+func (c *NewsClient) staleStore() Cache {
+	if c.staleCache != nil {
+		return c.staleCache
+	}
+	return c.cache
+}
+
+// staleKey is where the long-lived copy of a cached result is kept
+func staleKey(key string) string {
+	return "stale|" + key
+}
+
+// stale returns the long-lived copy of a result, marked Stale, for when newsapi can't be asked
+func (c *NewsClient) stale(key string) (*Results, bool) {
+	if c.cacheTTL <= 0 || c.staleTTL <= 0 {
+		return nil, false
+	}
+	body, ok := c.staleStore().Get(staleKey(key))
+	if !ok {
+		return nil, false
+	}
+	results := &Results{}
+	if err := json.Unmarshal(body, results); err != nil {
+		return nil, false
+	}
+	results.Stale = true
+	return results, true
+}
+
+func (c *NewsClient) logUpstream(endpoint string, status int, start time.Time, err error) {
+	if !c.debug {
+		return
+	}
+	attrs := []any{"url", redactKey(endpoint), "status", status, "elapsed", time.Since(start)}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	slog.Debug("newsapi request", attrs...)
+}
+
+// redactKey hides the apiKey param so upstream URLs are safe to log
+func redactKey(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "<unparseable url>"
+	}
+	q := u.Query()
+	if q.Has("apiKey") {
+		q.Set("apiKey", "REDACTED")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// everythingDefaults are sent unless params asks for something else
+var everythingDefaults = url.Values{
+	"sortBy":   {"publishedAt"},
+	"language": {"en"},
+}
+
+// buildEverythingURL returns the /v2/everything URL for params, with every value escaped by net/url.
+// params is copied, not modified, and is expected to carry the apiKey.
+func buildEverythingURL(base string, params url.Values) string {
+	return buildArticlesURL(base, endpointEverything, params)
+}
+
+// buildArticlesURL is buildEverythingURL for any of the article endpoints, only /v2/everything
+// gets the everythingDefaults
+func buildArticlesURL(base, endpoint string, params url.Values) string {
+	// Encode only reads the values, so they are shared rather than copied
+	query := make(url.Values, len(everythingDefaults)+len(params))
+	if endpoint == endpointEverything {
+		for key, values := range everythingDefaults {
+			query[key] = values
+		}
+	}
+	for key, values := range params {
+		query[key] = values
+	}
+	return strings.TrimSuffix(base, "/") + "/v2/" + endpoint + "?" + query.Encode()
+}
+
+// articles fetches a page of articles from endpoint, /v2/everything or /v2/top-headlines
+func (c *NewsClient) articles(ctx context.Context, endpoint string, params url.Values) (_ *Results, err error) {
+	ctx, span := tracer.Start(ctx, "newsapi "+endpoint, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("newsapi.q", params.Get("q")),
+			attribute.String("newsapi.page", params.Get("page")),
+			attribute.String("newsapi.page_size", params.Get("pageSize")),
+		))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	withKey := make(url.Values, len(params)+1)
+	for key, values := range params {
+		withKey[key] = values
+	}
+	withKey.Set("apiKey", c.key)
+
+	endpointURL := buildArticlesURL(c.base, endpoint, withKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpointURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("newsapi: invalid request url %s", redactKey(endpointURL))
+	}
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// the transport error quotes the URL, keep the key out of anything that logs it
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactKey(urlErr.URL)
+		}
+		c.logUpstream(endpointURL, 0, start, err)
+		return nil, err
+	}
+	c.logUpstream(endpointURL, resp.StatusCode, start, nil)
+	span.SetAttributes(
+		attribute.String("url.full", redactKey(endpointURL)),
+		attribute.Int("http.response.status_code", resp.StatusCode),
+	)
+
+	defer resp.Body.Close()
+
+	// newsapi describes what went wrong in the body, hand that back to the caller
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &NewsAPIError{}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil {
+			apiErr = nil
+		}
+		// a missing, invalid or disabled key: log the detail for operators, keep it from users
+		if resp.StatusCode == http.StatusUnauthorized {
+			if apiErr != nil {
+				log.Printf("newsapi rejected the api key: %s", apiErr.Message)
+			}
+			return nil, ErrNotConfigured
+		}
+		if apiErr == nil {
+			return nil, fmt.Errorf("newsapi: unexpected status %d", resp.StatusCode)
+		}
+		return nil, apiErr
+	}
+
+	// nobody is waiting for the answer anymore, don't bother decoding it
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// reading the body fails promptly once ctx is canceled, the request carries it. The error
+	// fields are read too, newsapi has been seen sending an error payload with a 200.
+	var body struct {
+		Results
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	buf := bodyBuffers.Get().(*bytes.Buffer)
+	defer putBodyBuffer(buf)
+	_, err = buf.ReadFrom(resp.Body)
+	if err == nil {
+		err = json.Unmarshal(buf.Bytes(), &body)
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	if body.Status != "ok" {
+		return nil, unexpectedStatusError(body.Status, body.Code, body.Message)
+	}
+	results := &body.Results
+	span.SetAttributes(
+		attribute.Int("newsapi.total_results", results.TotalResults),
+		attribute.Int("newsapi.result_count", len(results.Articles)),
+	)
+
+	return results, nil
+}
+
+// bodyBuffers hold newsapi responses while they are decoded, a page of results is tens of KB and
+// reading it into a fresh buffer each time means growing one over and over
+var bodyBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// maxPooledBody keeps the buffers of unusually large responses out of the pool
+const maxPooledBody = 1 << 20
+
+func putBodyBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBody {
+		buf.Reset()
+		bodyBuffers.Put(buf)
+	}
+}
+
+// unexpectedStatusError is the error for a 200 whose status isn't "ok", carrying newsapi's code
+// and message when it gave them
+func unexpectedStatusError(status, code, message string) *NewsAPIError {
+	if code == "" {
+		code = codeUnexpectedStatus
+	}
+	if message == "" {
+		message = fmt.Sprintf("newsapi answered with status %q", status)
+	}
+	return &NewsAPIError{Status: status, Code: code, Message: message}
+}
+
+// KeyCheck is the outcome of VerifyKey
+type KeyCheck struct {
+	Valid bool `json:"valid"`
+	// Status is the upstream HTTP status, 0 when newsapi couldn't be reached
+	Status  int    `json:"status"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Quota holds any rate limit headers newsapi sent back
+	Quota map[string]string `json:"quota,omitempty"`
+}
+
+// VerifyKey makes the smallest request newsapi accepts, one top headline, to check the key.
+// It skips the cache so the answer is always current. A non-nil error means newsapi
+// couldn't be reached at all, the key's validity is then unknown.
+func (c *NewsClient) VerifyKey(ctx context.Context) (*KeyCheck, error) {
+	endpoint := c.base + "/v2/top-headlines?" + url.Values{
+		"country":  {"us"},
+		"pageSize": {"1"},
+		"apiKey":   {c.key},
+	}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("newsapi: invalid request url %s", redactKey(endpoint))
+	}
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactKey(urlErr.URL)
+		}
+		c.logUpstream(endpoint, 0, start, err)
+		return nil, err
+	}
+	c.logUpstream(endpoint, resp.StatusCode, start, nil)
+	defer resp.Body.Close()
+
+	check := &KeyCheck{
+		Valid:  resp.StatusCode == http.StatusOK,
+		Status: resp.StatusCode,
+	}
+	for name, values := range resp.Header {
+		if strings.Contains(strings.ToLower(name), "ratelimit") {
+			if check.Quota == nil {
+				check.Quota = map[string]string{}
+			}
+			check.Quota[name] = strings.Join(values, ", ")
+		}
+	}
+	if !check.Valid {
+		apiErr := &NewsAPIError{}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err == nil {
+			check.Code = apiErr.Code
+			check.Message = apiErr.Message
+		}
+		// a rate limited key is still a working key
+		check.Valid = resp.StatusCode == http.StatusTooManyRequests
+	}
+	return check, nil
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEverythingCoalescesConcurrentCalls(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   func(i int) url.Values
+		wantHits int32
+	}{
+		{
+			name:     "identical searches share one request",
+			params:   func(int) url.Values { return url.Values{"q": {"go"}, "page": {"1"}} },
+			wantHits: 1,
+		},
+		{
+			name:     "different pages don't",
+			params:   func(i int) url.Values { return url.Values{"q": {"go"}, "page": {fmt.Sprint(i + 1)}} },
+			wantHits: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				<-release
+				w.Write([]byte(`{"status":"ok","totalResults":1,"articles":[{"title":"Story 1","url":"https://news.example.com/a/1"}]}`))
+			}))
+			defer srv.Close()
+			// no cache, so only the singleflight can save a request
+			c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(10), 0)
+
+			var wg sync.WaitGroup
+			results := make([]*Results, 5)
+			errs := make([]error, 5)
+			for i := range results {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results[i], errs[i] = c.Everything(context.Background(), tt.params(i))
+				}()
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			for i, err := range errs {
+				if err != nil {
+					t.Fatalf("call %d: %v", i, err)
+				}
+				if len(results[i].Articles) != 1 {
+					t.Errorf("call %d got %d articles, want 1", i, len(results[i].Articles))
+				}
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("newsapi got %d requests, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestEverythingCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		cacheTTL time.Duration
+		keys     []string
+		wantHits int32
+	}{
+		{name: "repeat search is a cache hit", cacheTTL: time.Minute, keys: []string{"key-a", "key-a"}, wantHits: 1},
+		{name: "rotated key keeps the cache", cacheTTL: time.Minute, keys: []string{"key-a", "key-b"}, wantHits: 1},
+		{name: "zero TTL disables it", cacheTTL: 0, keys: []string{"key-a", "key-a"}, wantHits: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			var sentKeys []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				sentKeys = append(sentKeys, r.URL.Query().Get("apiKey"))
+				w.Write([]byte(`{"status":"ok","totalResults":0,"articles":[]}`))
+			}))
+			defer srv.Close()
+
+			cache := newLRUCache(10)
+			for _, key := range tt.keys {
+				c := NewNewsClient(srv.Client(), srv.URL, key, cache, tt.cacheTTL)
+				if _, err := c.Everything(context.Background(), url.Values{"q": {"go"}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("newsapi got %d requests, want %d", got, tt.wantHits)
+			}
+			if sentKeys[0] != tt.keys[0] {
+				t.Errorf("newsapi got apiKey %q, want %q", sentKeys[0], tt.keys[0])
+			}
+		})
+	}
+}
+
+func TestNewsClientBase(t *testing.T) {
+	tests := []struct {
+		name     string
+		suffix   string
+		wantPath string
+	}{
+		{name: "host only", suffix: "", wantPath: "/v2/everything"},
+		{name: "trailing slash", suffix: "/", wantPath: "/v2/everything"},
+		{name: "proxy under a path", suffix: "/newsapi", wantPath: "/newsapi/v2/everything"},
+		{name: "proxy path with trailing slash", suffix: "/newsapi/", wantPath: "/newsapi/v2/everything"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				w.Write([]byte(`{"status":"ok","totalResults":0,"articles":[]}`))
+			}))
+			defer srv.Close()
+
+			c := NewNewsClient(srv.Client(), srv.URL+tt.suffix, "test-key", newLRUCache(10), 0)
+			if _, err := c.Everything(context.Background(), url.Values{"q": {"go"}}); err != nil {
+				t.Fatal(err)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("request went to %s, want %s", gotPath, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestBuildArticlesURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		endpoint string
+		params   url.Values
+		want     string
+	}{
+		{
+			name:     "defaults added",
+			base:     "https://newsapi.org",
+			endpoint: endpointEverything,
+			params:   url.Values{"q": {"go"}, "apiKey": {"k"}},
+			want:     "https://newsapi.org/v2/everything?apiKey=k&language=en&q=go&sortBy=publishedAt",
+		},
+		{
+			name:     "params override the defaults",
+			base:     "https://newsapi.org/",
+			endpoint: endpointEverything,
+			params:   url.Values{"q": {"go"}, "language": {"de"}, "sortBy": {"relevancy"}},
+			want:     "https://newsapi.org/v2/everything?language=de&q=go&sortBy=relevancy",
+		},
+		{
+			name:     "values are escaped",
+			base:     "https://newsapi.org",
+			endpoint: endpointEverything,
+			params:   url.Values{"q": {`"climate change" & more`}},
+			want:     "https://newsapi.org/v2/everything?language=en&q=%22climate+change%22+%26+more&sortBy=publishedAt",
+		},
+		{
+			name:     "top headlines get no defaults",
+			base:     "https://newsapi.org",
+			endpoint: endpointTopHeadlines,
+			params:   url.Values{"country": {"us"}},
+			want:     "https://newsapi.org/v2/top-headlines?country=us",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.params.Encode()
+			if got := buildArticlesURL(tt.base, tt.endpoint, tt.params); got != tt.want {
+				t.Errorf("buildArticlesURL() = %s, want %s", got, tt.want)
+			}
+			if tt.params.Encode() != before {
+				t.Error("buildArticlesURL changed its params")
+			}
+		})
+	}
+}
+
+func TestRedactKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		want     string
+	}{
+		{name: "key hidden", endpoint: "https://newsapi.org/v2/everything?apiKey=s3cret&q=go", want: "https://newsapi.org/v2/everything?apiKey=REDACTED&q=go"},
+		{name: "no key", endpoint: "https://newsapi.org/v2/everything?q=go", want: "https://newsapi.org/v2/everything?q=go"},
+		{name: "unparseable", endpoint: "http://[::1", want: "<unparseable url>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactKey(tt.endpoint); got != tt.want {
+				t.Errorf("redactKey(%q) = %q, want %q", tt.endpoint, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebugLogRedactsKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		debug   bool
+		wantLog bool
+	}{
+		{name: "debug on", debug: true, wantLog: true},
+		{name: "debug off", debug: false, wantLog: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			old := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+			defer slog.SetDefault(old)
+
+			api := newFakeNewsAPI(t, 1)
+			c := NewNewsClient(api.Client(), api.URL, "s3cret-key", newLRUCache(10), 0)
+			c.debug = tt.debug
+			if _, err := c.Everything(context.Background(), url.Values{"q": {"go"}}); err != nil {
+				t.Fatal(err)
+			}
+			out := logs.String()
+			if strings.Contains(out, "s3cret-key") {
+				t.Errorf("log has the api key: %s", out)
+			}
+			if got := strings.Contains(out, "apiKey=REDACTED") && strings.Contains(out, "status=200"); got != tt.wantLog {
+				t.Errorf("logged the request = %v, want %v: %s", got, tt.wantLog, out)
+			}
+		})
+	}
+}
+
+func TestVerifyKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		header    http.Header
+		want      KeyCheck
+		wantQuery url.Values
+	}{
+		{
+			name:   "working key",
+			status: http.StatusOK,
+			body:   `{"status":"ok","totalResults":1,"articles":[]}`,
+			header: http.Header{"X-Ratelimit-Remaining": {"99"}, "Cache-Control": {"no-cache"}},
+			want:   KeyCheck{Valid: true, Status: 200, Quota: map[string]string{"X-Ratelimit-Remaining": "99"}},
+		},
+		{
+			name:   "invalid key",
+			status: http.StatusUnauthorized,
+			body:   `{"status":"error","code":"apiKeyInvalid","message":"Your API key is invalid."}`,
+			want:   KeyCheck{Valid: false, Status: 401, Code: "apiKeyInvalid", Message: "Your API key is invalid."},
+		},
+		{
+			name:   "rate limited key still works",
+			status: http.StatusTooManyRequests,
+			body:   `{"status":"error","code":"rateLimited","message":"Too many requests."}`,
+			want:   KeyCheck{Valid: true, Status: 429, Code: "rateLimited", Message: "Too many requests."},
+		},
+		{
+			name:   "unreadable error body",
+			status: http.StatusInternalServerError,
+			body:   "oops",
+			want:   KeyCheck{Valid: false, Status: 500},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.Query()
+				for name, values := range tt.header {
+					w.Header()[name] = values
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(10), time.Minute)
+
+			got, err := c.VerifyKey(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Valid != tt.want.Valid || got.Status != tt.want.Status || got.Code != tt.want.Code || got.Message != tt.want.Message {
+				t.Errorf("VerifyKey() = %+v, want %+v", got, tt.want)
+			}
+			if fmt.Sprint(got.Quota) != fmt.Sprint(tt.want.Quota) {
+				t.Errorf("Quota = %v, want %v", got.Quota, tt.want.Quota)
+			}
+			if query.Get("pageSize") != "1" || query.Get("apiKey") != "test-key" {
+				t.Errorf("request query = %v, want one headline with the key", query)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(10), time.Minute)
+
+	_, err := c.VerifyKey(context.Background())
+	if err == nil {
+		t.Fatal("VerifyKey against a closed server succeeded")
+	}
+	if strings.Contains(err.Error(), "test-key") {
+		t.Errorf("error leaks the key: %v", err)
+	}
+}
+
+func TestEverythingCancellation(t *testing.T) {
+	tests := []struct {
+		name      string
+		followers int
+		wantHits  int32
+	}{
+		{name: "canceled caller stops waiting", followers: 0, wantHits: 1},
+		{name: "followers retry with their own context", followers: 1, wantHits: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			started := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if hits.Add(1) == 1 {
+					// the first request hangs until its caller gives up
+					close(started)
+					<-r.Context().Done()
+					return
+				}
+				w.Write([]byte(`{"status":"ok","totalResults":1,"articles":[{"title":"Story 1","url":"https://news.example.com/a/1"}]}`))
+			}))
+			defer srv.Close()
+			c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(10), time.Minute)
+			params := url.Values{"q": {"go"}}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			starterErr := make(chan error, 1)
+			go func() {
+				_, err := c.Everything(ctx, params)
+				starterErr <- err
+			}()
+			<-started
+
+			var wg sync.WaitGroup
+			followerErrs := make([]error, tt.followers)
+			for i := range followerErrs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, followerErrs[i] = c.Everything(context.Background(), params)
+				}()
+			}
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+
+			select {
+			case err := <-starterErr:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("canceled caller got %v, want context.Canceled", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("canceled caller still waiting")
+			}
+			wg.Wait()
+			for i, err := range followerErrs {
+				if err != nil {
+					t.Errorf("follower %d: %v", i, err)
+				}
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("newsapi got %d requests, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestStaleFallback(t *testing.T) {
+	rateLimited := `{"status":"error","code":"rateLimited","message":"You have made too many requests recently."}`
+	tests := []struct {
+		name      string
+		staleTTL  time.Duration
+		status    int
+		body      string
+		breaker   bool
+		wantStale bool
+		wantCode  string
+	}{
+		{name: "rate limited", staleTTL: time.Hour, status: http.StatusTooManyRequests, body: rateLimited, wantStale: true},
+		{name: "rate limited without stale copies", staleTTL: 0, status: http.StatusTooManyRequests, body: rateLimited, wantCode: codeRateLimited},
+		{name: "bad request isn't covered", staleTTL: time.Hour, status: http.StatusBadRequest, body: `{"status":"error","code":"parameterInvalid","message":"Bad q"}`, wantCode: "parameterInvalid"},
+		{name: "open breaker", staleTTL: time.Hour, status: http.StatusBadGateway, breaker: true, wantStale: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var failing atomic.Bool
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if failing.Load() {
+					w.WriteHeader(tt.status)
+					w.Write([]byte(tt.body))
+					return
+				}
+				w.Write([]byte(`{"status":"ok","totalResults":1,"articles":[{"title":"Story 1","url":"https://news.example.com/a/1"}]}`))
+			}))
+			defer srv.Close()
+			cache := newLRUCache(10)
+			c := NewNewsClient(srv.Client(), srv.URL, "test-key", cache, time.Minute)
+			c.staleTTL = tt.staleTTL
+			params := url.Values{"q": {"go"}}
+			if _, err := c.Everything(context.Background(), params); err != nil {
+				t.Fatal(err)
+			}
+
+			// the fresh copy expires and newsapi starts failing
+			cache.Delete(endpointEverything + "|" + normalizeParams(params))
+			failing.Store(true)
+			if tt.breaker {
+				c.breaker = newCircuitBreaker(1, time.Minute)
+				c.breaker.Record(errors.New("connection refused"))
+			}
+
+			results, err := c.Everything(context.Background(), params)
+			if tt.wantStale {
+				if err != nil {
+					t.Fatalf("Everything: %v, want the stale copy", err)
+				}
+				if !results.Stale || len(results.Articles) != 1 {
+					t.Errorf("results = %+v, want the stale copy", results)
+				}
+				return
+			}
+			if got := apiErrorCode(err); got != tt.wantCode {
+				t.Errorf("error = %v, want code %q", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestEverythingStatusNotOK(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    string
+		wantMessage string
+	}{
+		{name: "ok", body: `{"status":"ok","totalResults":0,"articles":[]}`},
+		{name: "error payload", body: `{"status":"error","code":"rateLimited","message":"Too many requests"}`, wantCode: codeRateLimited, wantMessage: "Too many requests"},
+		{name: "no code", body: `{"status":"maintenance"}`, wantCode: codeUnexpectedStatus, wantMessage: `newsapi answered with status "maintenance"`},
+		{name: "no status", body: `{"totalResults":3,"articles":[]}`, wantCode: codeUnexpectedStatus, wantMessage: `newsapi answered with status ""`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(srv.Close)
+			c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(10), time.Minute)
+			for range 2 {
+				_, err := c.Everything(context.Background(), url.Values{"q": {"go"}})
+				if got := apiErrorCode(err); got != tt.wantCode {
+					t.Fatalf("error = %v, want code %q", err, tt.wantCode)
+				}
+				var apiErr *NewsAPIError
+				if errors.As(err, &apiErr) && apiErr.Message != tt.wantMessage {
+					t.Errorf("message = %q, want %q", apiErr.Message, tt.wantMessage)
+				}
+			}
+			// only good results are cached
+			wantHits := int32(2)
+			if tt.wantCode == "" {
+				wantHits = 1
+			}
+			if got := hits.Load(); got != wantHits {
+				t.Errorf("newsapi got %d requests, want %d", got, wantHits)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// queryOperators are newsapi's boolean keywords, they only mean something in upper case
+var queryOperators = map[string]bool{"AND": true, "OR": true, "NOT": true}
+
+// normalizeQuery collapses whitespace and lowercases the query so equivalent searches compare equal.
+// newsapi matching is case-insensitive, but the boolean operators are left alone and quotes,
+// brackets and +/- prefixes are kept as typed.
+func normalizeQuery(q string) string {
+	fields := strings.Fields(q)
+	for i, f := range fields {
+		if queryOperators[f] {
+			continue
+		}
+		fields[i] = strings.ToLower(f)
+	}
+	return strings.Join(fields, " ")
+}
+
+// normalizeParams returns a canonical encoding of params: values are trimmed and empty ones dropped,
+// q is normalized, multi-value params are sorted and keys come out in order.
+// It is the key used to share upstream calls between equivalent requests.
+func normalizeParams(params url.Values) string {
+	canonical := url.Values{}
+	for key, values := range params {
+		var kept []string
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if key == "q" {
+				v = normalizeQuery(v)
+			}
+			if v != "" {
+				kept = append(kept, v)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		sort.Strings(kept)
+		canonical[key] = kept
+	}
+	return canonical.Encode()
+}
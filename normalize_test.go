@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{q: "Climate Change", want: "climate change"},
+		{q: "  climate \t  change ", want: "climate change"},
+		{q: "Bitcoin OR Ethereum", want: "bitcoin OR ethereum"},
+		{q: "apple AND NOT fruit", want: "apple AND NOT fruit"},
+		{q: "apple or pear", want: "apple or pear"},
+		{q: `"Elon Musk" -Tesla +SpaceX`, want: `"elon musk" -tesla +spacex`},
+		{q: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeQuery(tt.q); got != tt.want {
+			t.Errorf("normalizeQuery(%q) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeParams(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{name: "param order", a: "q=go&page=2", b: "page=2&q=go", same: true},
+		{name: "query case and spaces", a: "q=Go+Lang", b: "q=go++lang+", same: true},
+		{name: "empty params", a: "q=go&sources=", b: "q=go", same: true},
+		{name: "multi-value order", a: "q=go&keyword=b&keyword=a", b: "q=go&keyword=a&keyword=b", same: true},
+		{name: "trimmed values", a: "q=go&page=+2", b: "q=go&page=2", same: true},
+		{name: "different page", a: "q=go&page=1", b: "q=go&page=2", same: false},
+		{name: "operator case matters", a: "q=a+OR+b", b: "q=a+or+b", same: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := url.ParseQuery(tt.a)
+			b, _ := url.ParseQuery(tt.b)
+			if got := normalizeParams(a) == normalizeParams(b); got != tt.same {
+				t.Errorf("normalizeParams(%q) == normalizeParams(%q) is %v, want %v", tt.a, tt.b, got, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	presetsCookie = "presets"
+	// maxPresetsCookie keeps the signed cookie comfortably under the 4KB browsers allow
+	maxPresetsCookie = 3500
+	maxPresetName    = 40
+)
+
+var presetsTpl = newTemplateStore("presets.html")
+
+// searchPreset is a named search, Params is the encoded query string that reproduces it
+type searchPreset struct {
+	Name   string `json:"n"`
+	Params string `json:"p"`
+}
+
+// URL replays the preset
+func (p searchPreset) URL() string {
+	return "/search?" + p.Params
+}
+
+// marshalPresets and unmarshalPresets are the cookie payload format, kept apart from the cookie itself
+func marshalPresets(presets []searchPreset) []byte {
+	payload, _ := json.Marshal(presets)
+	return payload
+}
+
+func unmarshalPresets(payload []byte) ([]searchPreset, error) {
+	var presets []searchPreset
+	if err := json.Unmarshal(payload, &presets); err != nil {
+		return nil, err
+	}
+	kept := presets[:0]
+	for _, p := range presets {
+		if p.Name != "" {
+			kept = append(kept, p)
+		}
+	}
+	return kept, nil
+}
+
+// newPreset validates the posted name and search params. The params are read like any other
+// search, so a preset can't replay something /search would reject, and stored in the same
+// form search links use, without the page.
+func newPreset(name, rawParams string) (searchPreset, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return searchPreset{}, errors.New("a preset needs a name")
+	}
+	if r := []rune(name); len(r) > maxPresetName {
+		name = string(r[:maxPresetName])
+	}
+
+	params, err := url.ParseQuery(rawParams)
+	if err != nil {
+		return searchPreset{}, errors.New("invalid search params")
+	}
+	search, err := newSearch(params)
+	if err != nil {
+		return searchPreset{}, err
+	}
+	search.Variant = variantParam(params)
+	return searchPreset{Name: name, Params: search.linkParams().Encode()}, nil
+}
+
+// addPreset stores p, replacing any preset with the same name (ignoring case) so saving twice updates it
+func addPreset(presets []searchPreset, p searchPreset) []searchPreset {
+	return append(removePreset(presets, p.Name), p)
+}
+
+func removePreset(presets []searchPreset, name string) []searchPreset {
+	kept := make([]searchPreset, 0, len(presets))
+	for _, p := range presets {
+		if !strings.EqualFold(p.Name, name) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// readPresets returns the presets in the request, oldest first. A missing or tampered cookie reads as empty.
+func readPresets(r *http.Request) []searchPreset {
+	c, err := r.Cookie(presetsCookie)
+	if err != nil {
+		return nil
+	}
+	payload, err := verify(c.Value)
+	if err != nil {
+		return nil
+	}
+	presets, err := unmarshalPresets(payload)
+	if err != nil {
+		return nil
+	}
+	return presets
+}
+
+// writePresets signs the presets, dropping the oldest until the cookie fits in maxPresetsCookie
+func writePresets(w http.ResponseWriter, presets []searchPreset) {
+	token := sign(marshalPresets(presets))
+	for len(token) > maxPresetsCookie && len(presets) > 0 {
+		presets = presets[1:]
+		token = sign(marshalPresets(presets))
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     presetsCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// presetSaveHandler stores the posted params under name, then replays it
+func presetSaveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !sameOrigin(r) {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !parseForm(w, r) {
+		return
+	}
+
+	p, err := newPreset(r.FormValue("name"), r.FormValue("params"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writePresets(w, addPreset(readPresets(r), p))
+	http.Redirect(w, r, p.URL(), http.StatusSeeOther)
+}
+
+// presetDeleteHandler drops the posted preset name
+func presetDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !sameOrigin(r) {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !parseForm(w, r) {
+		return
+	}
+
+	writePresets(w, removePreset(readPresets(r), r.FormValue("name")))
+	http.Redirect(w, r, "/presets", http.StatusSeeOther)
+}
+
+// presetsHandler lists the presets with links to replay them
+func presetsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := presetsTpl.Get().Execute(w, readPresets(r)); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewPreset(t *testing.T) {
+	tests := []struct {
+		name       string
+		presetName string
+		params     string
+		want       searchPreset
+		wantErr    bool
+	}{
+		{name: "page left out", presetName: "Go news", params: "q=go&page=3", want: searchPreset{Name: "Go news", Params: "q=go"}},
+		{name: "name whitespace collapsed", presetName: "  Go \n news ", params: "q=go", want: searchPreset{Name: "Go news", Params: "q=go"}},
+		{name: "long name cut", presetName: strings.Repeat("é", 50), params: "q=go", want: searchPreset{Name: strings.Repeat("é", maxPresetName), Params: "q=go"}},
+		{name: "variant kept", presetName: "b", params: "q=go&variant=b", want: searchPreset{Name: "b", Params: "q=go&variant=b"}},
+		{name: "no name", presetName: " ", params: "q=go", wantErr: true},
+		{name: "unparseable params", presetName: "bad", params: "q=%zz", wantErr: true},
+		{name: "search rejects them", presetName: "bad", params: "q=go&q=rust", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newPreset(tt.presetName, tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPreset error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("newPreset = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddAndRemovePreset(t *testing.T) {
+	a := searchPreset{Name: "Go", Params: "q=go"}
+	b := searchPreset{Name: "Rust", Params: "q=rust"}
+	tests := []struct {
+		name    string
+		presets []searchPreset
+		add     *searchPreset
+		remove  string
+		want    []searchPreset
+	}{
+		{name: "added last", presets: []searchPreset{a}, add: &b, want: []searchPreset{a, b}},
+		{name: "same name replaces", presets: []searchPreset{a, b}, add: &searchPreset{Name: "go", Params: "q=golang"}, want: []searchPreset{b, {Name: "go", Params: "q=golang"}}},
+		{name: "removed ignoring case", presets: []searchPreset{a, b}, remove: "RUST", want: []searchPreset{a}},
+		{name: "removing a missing name", presets: []searchPreset{a}, remove: "Zig", want: []searchPreset{a}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []searchPreset
+			if tt.add != nil {
+				got = addPreset(tt.presets, *tt.add)
+			} else {
+				got = removePreset(tt.presets, tt.remove)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPresets(t *testing.T) {
+	presets := []searchPreset{{Name: "Go", Params: "q=go"}}
+	token := sign(marshalPresets(presets))
+	tests := []struct {
+		name   string
+		cookie string
+		want   []searchPreset
+	}{
+		{name: "signed", cookie: token, want: presets},
+		{name: "no cookie", want: nil},
+		{name: "tampered", cookie: "W3sibiI6IkV2aWwiLCJwIjoicT1ldmlsIn1d" + token[strings.Index(token, "."):], want: nil},
+		{name: "signed but not presets", cookie: sign([]byte("not json")), want: nil},
+		{name: "nameless presets dropped", cookie: sign([]byte(`[{"n":"","p":"q=x"},{"n":"Go","p":"q=go"}]`)), want: presets},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/presets", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: presetsCookie, Value: tt.cookie})
+			}
+			if got := readPresets(r); !slices.Equal(got, tt.want) {
+				t.Errorf("readPresets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// presetsFrom reads back the presets a response stored
+func presetsFrom(w *httptest.ResponseRecorder) []searchPreset {
+	r := httptest.NewRequest(http.MethodGet, "/presets", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	return readPresets(r)
+}
+
+func TestWritePresetsDropsOldest(t *testing.T) {
+	var presets []searchPreset
+	for i := range 100 {
+		presets = append(presets, searchPreset{Name: strings.Repeat("n", 30) + string(rune('a'+i%26)), Params: "q=" + strings.Repeat("q", 40)})
+	}
+	w := httptest.NewRecorder()
+	writePresets(w, presets)
+	if c := w.Result().Cookies(); len(c) != 1 || len(c[0].Value) > maxPresetsCookie {
+		t.Fatalf("cookies = %v, want one of at most %d bytes", c, maxPresetsCookie)
+	}
+	kept := presetsFrom(w)
+	if len(kept) == 0 || len(kept) == len(presets) || kept[len(kept)-1] != presets[len(presets)-1] {
+		t.Errorf("kept %d of %d presets, want the newest that fit", len(kept), len(presets))
+	}
+}
+
+func TestPresetSaveHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		origin       string
+		form         url.Values
+		wantStatus   int
+		wantLocation string
+	}{
+		{name: "saves and replays", method: http.MethodPost, form: url.Values{"name": {"Go"}, "params": {"q=go&page=2"}}, wantStatus: http.StatusSeeOther, wantLocation: "/search?q=go"},
+		{name: "cross origin", method: http.MethodPost, origin: "https://evil.test", form: url.Values{"name": {"Go"}, "params": {"q=go"}}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "GET", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+		{name: "no name", method: http.MethodPost, form: url.Values{"params": {"q=go"}}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/presets/save", strings.NewReader(tt.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			presetSaveHandler(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusSeeOther {
+				if len(w.Result().Cookies()) != 0 {
+					t.Error("a rejected save still set the cookie")
+				}
+				return
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+			if got := presetsFrom(w); len(got) != 1 || got[0].Name != tt.form.Get("name") {
+				t.Errorf("cookie holds %v, want the posted preset", got)
+			}
+		})
+	}
+}
+
+func TestPresetsHandlerLists(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/presets", nil)
+	r.AddCookie(&http.Cookie{Name: presetsCookie, Value: sign(marshalPresets([]searchPreset{{Name: "Go news", Params: "q=go"}}))})
+	w := httptest.NewRecorder()
+	presetsHandler(w, r)
+	if body := w.Body.String(); !strings.Contains(body, "Go news") || !strings.Contains(body, "/search?q=go") {
+		t.Errorf("presets page lacks the preset:\n%s", body)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// previewTpl is an HTML fragment, loaded into a dialog on the results page
+var previewTpl = newTemplateStore("preview.html")
+
+// PreviewURL is the preview fragment for one of this page's articles. It carries the search
+// so /preview can find the article among the same results instead of trusting the url param.
+func (s *Search) PreviewURL(a Articles) string {
+	v := s.linkParams()
+	v.Set("page", strconv.Itoa(s.CurrentPage()))
+	v.Set("url", a.URL)
+	return "/preview?" + v.Encode()
+}
+
+// previewHandler renders the title, image, description and source of an article in the results
+// of the given search. Only articles on that results page can be previewed, and nothing is fetched
+// from the url itself, so the endpoint can't be pointed at arbitrary pages.
+func previewHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	u, err := validateRemoteURL(params.Get("url"), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	search, err := newSearch(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// the articles of the results page, headlines included
+	search.withHeadlines = true
+
+	// normally a cache hit, the results page linking here was just rendered
+	if err := search.fetch(r.Context()); err != nil {
+		if clientGone(r, err) {
+			return
+		}
+		writeFetchError(w, err)
+		return
+	}
+
+	for _, a := range search.Results.Articles {
+		if a.URL == u.String() || a.URL == params.Get("url") {
+			setCacheControl(w, "public", search.Results)
+			if err := previewTpl.Get().Execute(w, &a); err != nil {
+				log.Println(err)
+			}
+			return
+		}
+	}
+	http.Error(w, "Article not found in these results", http.StatusNotFound)
+}
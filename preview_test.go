@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPreviewURL(t *testing.T) {
+	s := &Search{SearchKey: "go", PageSize: 20, NextPage: 3}
+	got, err := url.Parse(s.PreviewURL(testArticle(7)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != "/preview" {
+		t.Errorf("path = %q, want /preview", got.Path)
+	}
+	want := url.Values{"q": {"go"}, "page": {"2"}, "url": {"https://news.example.com/a/7"}}
+	if got.Query().Encode() != want.Encode() {
+		t.Errorf("params = %q, want %q", got.Query().Encode(), want.Encode())
+	}
+}
+
+func TestPreviewHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		want       string
+	}{
+		{name: "article on the page", target: "/preview?q=go&url=https%3A%2F%2Fnews.example.com%2Fa%2F3", wantStatus: http.StatusOK, want: "What happened in story 3"},
+		{name: "article on another page", target: "/preview?q=go&url=https%3A%2F%2Fnews.example.com%2Fa%2F25", wantStatus: http.StatusNotFound},
+		{name: "page taken into account", target: "/preview?q=go&page=2&url=https%3A%2F%2Fnews.example.com%2Fa%2F25", wantStatus: http.StatusOK, want: "What happened in story 25"},
+		{name: "arbitrary url", target: "/preview?q=go&url=https%3A%2F%2Fevil.test%2F", wantStatus: http.StatusNotFound},
+		{name: "not a web url", target: "/preview?q=go&url=javascript%3Aalert(1)", wantStatus: http.StatusBadRequest},
+		{name: "bad search", target: "/preview?q=go&q=rust&url=https%3A%2F%2Fnews.example.com%2Fa%2F3", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, newFakeNewsAPI(t, 40).Server)
+			w := get(previewHandler, tt.target)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("preview lacks %q:\n%s", tt.want, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxQueryTerms is how many plain words cleanQuery keeps, quoted phrases and operators aside
+const maxQueryTerms = 6
+
+// minQueryLength is the shortest query sent upstream, set by -min-query-length, 0 turns the check off
+var minQueryLength *int
+
+// maxQueryLength is the longest q newsapi accepts, in characters
+const maxQueryLength = 500
+
+// errQueryTooShort is wrapped by validateQueryLength, the HTML search shows a hint for it instead of a 400
+var errQueryTooShort = errors.New("query too short")
+
+// validateQueryLength rejects queries too short to give useful results, counting characters
+// of the query as sent, so keywords count towards it
+func validateQueryLength(s *Search) error {
+	if n := utf8.RuneCountInString(strings.TrimSpace(s.query())); n < *minQueryLength {
+		return fmt.Errorf("%w, it must be at least %d characters", errQueryTooShort, *minQueryLength)
+	}
+	return nil
+}
+
+// queryHook rewrites a query before it is sent to newsapi
+type queryHook func(string) string
+
+// queryPipeline is the hooks a query goes through, in order, each one seeing the previous one's output
+type queryPipeline []queryHook
+
+func (p queryPipeline) apply(q string) string {
+	for _, hook := range p {
+		q = hook(q)
+	}
+	return q
+}
+
+// queryHooks is the pipeline every search query goes through, set up in main from the flags
+var queryHooks queryPipeline
+
+// defaultQueryHooks builds the pipeline for the query flags. To add a hook, append it here at
+// the point of the pipeline it should run.
+func defaultQueryHooks(clean, expand bool) queryPipeline {
+	hooks := queryPipeline{strings.TrimSpace}
+	if clean {
+		hooks = append(hooks, cleanQuery)
+	}
+	// last, so cleanQuery doesn't count the synonyms towards its terms
+	if expand {
+		hooks = append(hooks, expandQuerySynonyms)
+	}
+	return hooks
+}
+
+// queryTokens splits q on whitespace, keeping a "quoted phrase" (with its quotes) as one token
+func queryTokens(q string) []string {
+	var tokens []string
+	for {
+		q = strings.TrimSpace(q)
+		if q == "" {
+			return tokens
+		}
+		if i := strings.IndexAny(q, " \t\n\""); i >= 0 && q[i] == '"' {
+			// a quote inside the token: read up to the closing quote and any trailing text
+			end := strings.IndexByte(q[i+1:], '"')
+			if end >= 0 {
+				end += i + 2
+				if sp := strings.IndexAny(q[end:], " \t\n"); sp >= 0 {
+					end += sp
+				} else {
+					end = len(q)
+				}
+				tokens = append(tokens, q[:end])
+				q = q[end:]
+				continue
+			}
+		}
+		f := strings.Fields(q)[0]
+		tokens = append(tokens, f)
+		q = q[len(f):]
+	}
+}
+
+// cleanQuery drops stopwords from a pasted sentence and keeps only the first few significant
+// words. Quoted phrases, AND/OR/NOT and +/- prefixed words are kept as typed. When nothing
+// significant is left the query is returned unchanged.
+func cleanQuery(q string) string {
+	var kept []string
+	plain := 0
+	for _, t := range queryTokens(q) {
+		switch {
+		case strings.Contains(t, `"`), queryOperators[t], strings.HasPrefix(t, "+"), strings.HasPrefix(t, "-"):
+			kept = append(kept, t)
+		case stopwords[strings.ToLower(strings.Trim(t, ".,;:!?()"))]:
+		default:
+			if plain < maxQueryTerms {
+				kept = append(kept, strings.Trim(t, ".,;:!?"))
+				plain++
+			}
+		}
+	}
+
+	// operators left dangling by dropped words would make newsapi reject the query
+	for len(kept) > 0 && queryOperators[kept[0]] {
+		kept = kept[1:]
+	}
+	for len(kept) > 0 && queryOperators[kept[len(kept)-1]] {
+		kept = kept[:len(kept)-1]
+	}
+	if len(kept) == 0 {
+		return strings.TrimSpace(q)
+	}
+	return strings.Join(kept, " ")
+}
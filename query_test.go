@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestQueryTokens(t *testing.T) {
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{q: "climate change", want: []string{"climate", "change"}},
+		{q: `"climate change" policy`, want: []string{`"climate change"`, "policy"}},
+		{q: `-"fake news" +real`, want: []string{`-"fake news"`, "+real"}},
+		{q: `title:"big deal"s now`, want: []string{`title:"big deal"s`, "now"}},
+		{q: `unclosed "quote here`, want: []string{"unclosed", `"quote`, "here"}},
+		{q: "  ", want: nil},
+	}
+	for _, tt := range tests {
+		if got := queryTokens(tt.q); !slices.Equal(got, tt.want) {
+			t.Errorf("queryTokens(%q) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestCleanQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		q    string
+		want string
+	}{
+		{name: "stopwords dropped", q: "what is the latest on the mars rover?", want: "latest mars rover"},
+		{name: "at most six words", q: "one two three four five six seven eight", want: "one two three four five six"},
+		{name: "phrases and prefixes kept", q: `the "climate change" -hoax +policy`, want: `"climate change" -hoax +policy`},
+		{name: "operators kept", q: "bitcoin OR ethereum", want: "bitcoin OR ethereum"},
+		{name: "dangling operators dropped", q: "AND the OR", want: "AND the OR"},
+		{name: "operator left at the end", q: "bitcoin OR the", want: "bitcoin"},
+		{name: "only stopwords", q: "the and of", want: "the and of"},
+		{name: "punctuation trimmed", q: "elections, results!", want: "elections results"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanQuery(tt.q); got != tt.want {
+				t.Errorf("cleanQuery(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateQueryLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		min     int
+		query   url.Values
+		wantErr bool
+	}{
+		{name: "long enough", min: 2, query: url.Values{"q": {"go"}}},
+		{name: "too short", min: 2, query: url.Values{"q": {"g"}}, wantErr: true},
+		{name: "spaces don't count", min: 2, query: url.Values{"q": {"  g "}}, wantErr: true},
+		{name: "characters, not bytes", min: 2, query: url.Values{"q": {"é"}}, wantErr: true},
+		{name: "two characters of four bytes", min: 2, query: url.Values{"q": {"日本"}}},
+		{name: "keywords count", min: 2, query: url.Values{"keyword": {"go"}}},
+		{name: "check off", min: 0, query: url.Values{"q": {"g"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &minQueryLength, tt.min)
+			_, err := newSearch(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newSearch(%v) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, errQueryTooShort) {
+				t.Errorf("error %v isn't errQueryTooShort", err)
+			}
+		})
+	}
+}
+
+func TestShortQueryResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		target     string
+		wantStatus int
+		want       string
+	}{
+		{name: "search page hints", handler: searchHandler, target: "/search?q=g", wantStatus: http.StatusOK, want: "Enter at least 2 characters to search."},
+		{name: "json is a bad request", handler: searchJSONHandler, target: "/search.json?q=g", wantStatus: http.StatusBadRequest, want: "at least 2 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeNewsAPI(t, 5)
+			useNewsAPI(t, api.Server)
+			w := get(tt.handler, tt.target)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("response lacks %q:\n%s", tt.want, w.Body)
+			}
+			if strings.Contains(w.Body.String(), "pageSize=0") {
+				t.Error("links carry pageSize=0")
+			}
+			if got := api.hits.Load(); got != 0 {
+				t.Errorf("a short query made %d newsapi requests, want none", got)
+			}
+		})
+	}
+}
+
+func TestQueryPipeline(t *testing.T) {
+	upper := queryHook(strings.ToUpper)
+	exclaim := queryHook(func(q string) string { return q + "!" })
+	tests := []struct {
+		name     string
+		pipeline queryPipeline
+		q        string
+		want     string
+	}{
+		{name: "no hooks", pipeline: nil, q: " go ", want: " go "},
+		{name: "in order", pipeline: queryPipeline{strings.TrimSpace, upper, exclaim}, q: " go ", want: "GO!"},
+		{name: "each sees the last one's output", pipeline: queryPipeline{exclaim, strings.TrimSpace}, q: " go ", want: "go !"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pipeline.apply(tt.q); got != tt.want {
+				t.Errorf("apply(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultQueryHooks(t *testing.T) {
+	tests := []struct {
+		name          string
+		clean         bool
+		searchKey     string
+		wantQuery     string
+		wantEffective string
+	}{
+		{name: "trimmed only", searchKey: "  the mars rover ", wantQuery: "the mars rover", wantEffective: ""},
+		{name: "cleaned", clean: true, searchKey: "the latest on the mars rover", wantQuery: "latest mars rover", wantEffective: "latest mars rover"},
+		{name: "nothing to clean", clean: true, searchKey: "mars rover", wantQuery: "mars rover", wantEffective: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &queryHooks, defaultQueryHooks(tt.clean, false))
+			s := &Search{SearchKey: tt.searchKey}
+			if got := s.query(); got != tt.wantQuery {
+				t.Errorf("query() = %q, want %q", got, tt.wantQuery)
+			}
+			if got := s.EffectiveQuery(); got != tt.wantEffective {
+				t.Errorf("EffectiveQuery() = %q, want %q", got, tt.wantEffective)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+)
+
+// defaultTopics are picked from by /random when -random-topics and -random-topics-file are unset
+var defaultTopics = []string{
+	"technology", "science", "space", "climate", "health",
+	"economy", "football", "music", "film", "travel",
+}
+
+// randomTopics is the list /random picks from
+var randomTopics = defaultTopics
+
+// randomHandler redirects to the search for a random topic, which then goes through the usual flow and cache
+func randomHandler(w http.ResponseWriter, r *http.Request) {
+	if len(randomTopics) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	topic := randomTopics[rand.IntN(len(randomTopics))]
+
+	// every hit should land on a fresh pick
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, "/search?"+url.Values{"q": {topic}}.Encode(), http.StatusFound)
+}
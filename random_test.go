@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestRandomHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		topics     []string
+		wantStatus int
+		want       []string
+	}{
+		{name: "one topic", topics: []string{"space"}, wantStatus: http.StatusFound, want: []string{"/search?q=space"}},
+		{name: "escaped", topics: []string{"climate change"}, wantStatus: http.StatusFound, want: []string{"/search?q=climate+change"}},
+		{name: "picks from the list", topics: []string{"go", "rust"}, wantStatus: http.StatusFound, want: []string{"/search?q=go", "/search?q=rust"}},
+		{name: "no topics", topics: nil, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &randomTopics, tt.topics)
+			for range 10 {
+				w := get(randomHandler, "/random")
+				if w.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+				}
+				if tt.wantStatus != http.StatusFound {
+					return
+				}
+				if got := w.Header().Get("Location"); !slices.Contains(tt.want, got) {
+					t.Errorf("Location = %q, want one of %q", got, tt.want)
+				}
+				if got := w.Header().Get("Cache-Control"); got != "no-store" {
+					t.Errorf("Cache-Control = %q, want no-store", got)
+				}
+			}
+		})
+	}
+}
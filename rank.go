@@ -0,0 +1,134 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// preferredSources holds the -preferred-sources names or domains searchHandler floats to the top
+var preferredSources []string
+
+// boostSources moves articles from preferred sources ahead of the rest, keeping the original
+// order within both groups. Only the page that was fetched is reordered, a preferred article
+// on a later page stays there. It returns a new slice and leaves articles untouched.
+func boostSources(articles []Articles, preferred []string) []Articles {
+	if len(preferred) == 0 {
+		return articles
+	}
+
+	boosted := make([]Articles, 0, len(articles))
+	var rest []Articles
+	for _, a := range articles {
+		if isPreferred(&a, preferred) {
+			boosted = append(boosted, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	return append(boosted, rest...)
+}
+
+// isPreferred matches the source name case-insensitively, or the article host against a preferred domain
+func isPreferred(a *Articles, preferred []string) bool {
+	host := ""
+	if u, err := url.Parse(a.URL); err == nil {
+		host = u.Hostname()
+	}
+	for _, p := range preferred {
+		if strings.EqualFold(a.Source.Name, p) {
+			return true
+		}
+		if host != "" && hostAllowed(host, []string{p}) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortChoice is an option of the search form's sort select, Value "" is newest first
+type sortChoice struct {
+	Value string
+	Label string
+}
+
+// sortChoices are the sortBy values accepted: newsapi's own orders, and smart, our smartScore
+// over the page fetched newest first
+var sortChoices = []sortChoice{
+	{"", "Newest"},
+	{"relevancy", "Relevance"},
+	{"popularity", "Popularity"},
+	{"smart", "Smart"},
+}
+
+// sortParam reads the optional sortBy param, publishedAt (the default) comes back as ""
+func sortParam(params url.Values) (string, error) {
+	v, err := singleParam(params, "sortBy")
+	if err != nil || v == "" || v == "publishedAt" {
+		return "", err
+	}
+	for _, c := range sortChoices {
+		if c.Value == v {
+			return v, nil
+		}
+	}
+	return "", errors.New("sortBy must be publishedAt, relevancy, popularity or smart")
+}
+
+// SortChoices lists the sortBy options for the search form
+func (s *Search) SortChoices() []sortChoice {
+	return sortChoices
+}
+
+// smartOptions weigh the parts of smartScore, set from the -smart-* flags
+type smartOptions struct {
+	recencyWeight, imageWeight, sourceWeight float64
+	// halfLife is the age at which an article gets half the recency weight
+	halfLife  time.Duration
+	preferred []string
+}
+
+var smartWeights smartOptions
+
+// smartScore rates an article for sortBy=smart: recency, decaying by half every halfLife and 0
+// without a date, plus the image weight when it has an image and the source weight when it comes
+// from a preferred source
+func smartScore(a Articles, opts smartOptions, now time.Time) float64 {
+	score := 0.0
+	if !a.PublishedAt.IsZero() && opts.halfLife > 0 {
+		age := max(now.Sub(a.PublishedAt.Time), 0)
+		score += opts.recencyWeight * math.Exp2(-float64(age)/float64(opts.halfLife))
+	}
+	if a.URLToImage != "" {
+		score += opts.imageWeight
+	}
+	if isPreferred(&a, opts.preferred) {
+		score += opts.sourceWeight
+	}
+	return score
+}
+
+// sortSmart orders articles by smartScore, highest first, keeping newsapi's order between equal
+// scores. It returns a new slice and leaves articles untouched.
+func sortSmart(articles []Articles, opts smartOptions, now time.Time) []Articles {
+	type scored struct {
+		article Articles
+		score   float64
+	}
+	ranked := make([]scored, len(articles))
+	for i, a := range articles {
+		ranked[i] = scored{a, smartScore(a, opts, now)}
+	}
+	slices.SortStableFunc(ranked, func(a, b scored) int {
+		return cmp.Compare(b.score, a.score)
+	})
+	sorted := make([]Articles, len(ranked))
+	for i, r := range ranked {
+		sorted[i] = r.article
+	}
+	return sorted
+}
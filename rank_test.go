@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// titles lists the articles' titles, to compare orders by
+func titles(articles []Articles) []string {
+	out := make([]string, len(articles))
+	for i, a := range articles {
+		out[i] = a.Title
+	}
+	return out
+}
+
+func TestBoostSources(t *testing.T) {
+	articles := []Articles{
+		{Title: "a", Source: Source{Name: "Daily Blog"}, URL: "https://blog.example.org/a"},
+		{Title: "b", Source: Source{Name: "BBC News"}, URL: "https://www.bbc.co.uk/b"},
+		{Title: "c", Source: Source{Name: "Wire"}, URL: "https://reuters.com/c"},
+		{Title: "d", Source: Source{Name: "bbc news"}, URL: "https://bbc.co.uk/d"},
+	}
+	tests := []struct {
+		name      string
+		preferred []string
+		want      []string
+	}{
+		{name: "none preferred", preferred: nil, want: []string{"a", "b", "c", "d"}},
+		{name: "by source name, any case", preferred: []string{"BBC NEWS"}, want: []string{"b", "d", "a", "c"}},
+		{name: "by domain and subdomain", preferred: []string{"bbc.co.uk"}, want: []string{"b", "d", "a", "c"}},
+		{name: "several keep the page order", preferred: []string{"reuters.com", "Daily Blog"}, want: []string{"a", "c", "b", "d"}},
+		{name: "no match", preferred: []string{"example.net"}, want: []string{"a", "b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := titles(boostSources(articles, tt.preferred))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("boostSources order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := titles(articles); !slices.Equal(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("boostSources reordered its input: %v", got)
+	}
+}
+
+func TestSortParam(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "", want: ""},
+		{query: "sortBy=publishedAt", want: ""},
+		{query: "sortBy=relevancy", want: "relevancy"},
+		{query: "sortBy=popularity", want: "popularity"},
+		{query: "sortBy=smart", want: "smart"},
+		{query: "sortBy=Smart", wantErr: true},
+		{query: "sortBy=random", wantErr: true},
+		{query: "sortBy=smart&sortBy=relevancy", wantErr: true},
+	}
+	for _, tt := range tests {
+		params, _ := url.ParseQuery(tt.query)
+		got, err := sortParam(params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("sortParam(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("sortParam(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSmartScore(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	opts := smartOptions{recencyWeight: 1, imageWeight: 0.3, sourceWeight: 0.5, halfLife: 6 * time.Hour, preferred: []string{"reuters.com"}}
+	tests := []struct {
+		name    string
+		article Articles
+		opts    smartOptions
+		want    float64
+	}{
+		{name: "brand new", article: Articles{PublishedAt: Timestamp{now}}, opts: opts, want: 1},
+		{name: "one half life old", article: Articles{PublishedAt: Timestamp{now.Add(-6 * time.Hour)}}, opts: opts, want: 0.5},
+		{name: "two half lives old", article: Articles{PublishedAt: Timestamp{now.Add(-12 * time.Hour)}}, opts: opts, want: 0.25},
+		{name: "from the future counts as new", article: Articles{PublishedAt: Timestamp{now.Add(time.Hour)}}, opts: opts, want: 1},
+		{name: "no date", article: Articles{}, opts: opts, want: 0},
+		{name: "image", article: Articles{URLToImage: "https://images.example.com/a.jpg"}, opts: opts, want: 0.3},
+		{name: "preferred source", article: Articles{URL: "https://www.reuters.com/a"}, opts: opts, want: 0.5},
+		{name: "everything", article: Articles{PublishedAt: Timestamp{now}, URLToImage: "https://images.example.com/a.jpg", URL: "https://reuters.com/a"}, opts: opts, want: 1.8},
+		{name: "no half life", article: Articles{PublishedAt: Timestamp{now}}, opts: smartOptions{recencyWeight: 1}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := smartScore(tt.article, tt.opts, now); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("smartScore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortSmart(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	articles := []Articles{
+		{Title: "old", PublishedAt: Timestamp{now.Add(-24 * time.Hour)}},
+		{Title: "undated"},
+		{Title: "new", PublishedAt: Timestamp{now}},
+		{Title: "old with image", PublishedAt: Timestamp{now.Add(-24 * time.Hour)}, URLToImage: "https://images.example.com/a.jpg"},
+		{Title: "undated too"},
+	}
+	tests := []struct {
+		name string
+		opts smartOptions
+		want []string
+	}{
+		{name: "recency", opts: smartOptions{recencyWeight: 1, halfLife: 6 * time.Hour}, want: []string{"new", "old", "old with image", "undated", "undated too"}},
+		{name: "images outweigh recency", opts: smartOptions{recencyWeight: 1, imageWeight: 2, halfLife: 6 * time.Hour}, want: []string{"old with image", "new", "old", "undated", "undated too"}},
+		{name: "all equal keeps the order", opts: smartOptions{}, want: []string{"old", "undated", "new", "old with image", "undated too"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := titles(sortSmart(articles, tt.opts, now)); !slices.Equal(got, tt.want) {
+				t.Errorf("sortSmart order = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if articles[0].Title != "old" {
+		t.Error("sortSmart reordered its input")
+	}
+}
+
+func TestSortByUpstream(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantSortBy string
+		wantLink   string
+	}{
+		{name: "default", query: "q=go", wantSortBy: "publishedAt"},
+		{name: "newsapi's order", query: "q=go&sortBy=relevancy", wantSortBy: "relevancy", wantLink: "sortBy=relevancy"},
+		{name: "smart ranks the newest", query: "q=go&sortBy=smart", wantSortBy: "publishedAt", wantLink: "sortBy=smart"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent atomic.Value
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sent.Store(r.URL.Query().Get("sortBy"))
+				json.NewEncoder(w).Encode(Results{Status: "ok", TotalResults: 40, Articles: []Articles{testArticle(1)}})
+			}))
+			t.Cleanup(srv.Close)
+			useNewsAPI(t, srv)
+			w := get(searchHandler, "/search?"+tt.query)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if got := sent.Load(); got != tt.wantSortBy {
+				t.Errorf("newsapi got sortBy=%v, want %s", got, tt.wantSortBy)
+			}
+			if tt.wantLink != "" && !strings.Contains(w.Body.String(), tt.wantLink) {
+				t.Errorf("page links don't keep %s", tt.wantLink)
+			}
+		})
+	}
+}
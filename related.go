@@ -0,0 +1,105 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxRelatedTerms is how many related searches are suggested under the results
+const maxRelatedTerms = 5
+
+// stopwords are common English words that say nothing about what an article is about
+var stopwords = map[string]bool{
+	"a": true, "about": true, "above": true, "after": true, "again": true, "against": true, "all": true,
+	"am": true, "an": true, "and": true, "any": true, "are": true, "as": true, "at": true, "be": true,
+	"because": true, "been": true, "before": true, "being": true, "below": true, "between": true,
+	"both": true, "but": true, "by": true, "can": true, "could": true, "did": true, "do": true,
+	"does": true, "doing": true, "down": true, "during": true, "each": true, "few": true, "for": true,
+	"from": true, "further": true, "had": true, "has": true, "have": true, "having": true, "he": true,
+	"her": true, "here": true, "hers": true, "him": true, "his": true, "how": true, "i": true,
+	"if": true, "in": true, "into": true, "is": true, "it": true, "its": true, "just": true,
+	"me": true, "more": true, "most": true, "my": true, "new": true, "no": true, "nor": true,
+	"not": true, "now": true, "of": true, "off": true, "on": true, "once": true, "only": true,
+	"or": true, "other": true, "our": true, "out": true, "over": true, "own": true, "says": true,
+	"said": true, "same": true, "she": true, "should": true, "so": true, "some": true, "such": true,
+	"than": true, "that": true, "the": true, "their": true, "them": true, "then": true, "there": true,
+	"these": true, "they": true, "this": true, "those": true, "through": true, "to": true, "too": true,
+	"under": true, "until": true, "up": true, "very": true, "was": true, "we": true, "were": true,
+	"what": true, "when": true, "where": true, "which": true, "while": true, "who": true, "whom": true,
+	"why": true, "will": true, "with": true, "would": true, "you": true, "your": true,
+}
+
+// words splits text into lowercase runs of letters and digits
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// relatedTerms suggests follow-up searches from the words and word pairs that recur across the
+// article titles, leaving out stopwords and the words already searched for. Only terms seen in
+// at least two titles count. Pairs beat single words on a tie, then it's alphabetical, so the
+// same articles always give the same suggestions.
+func relatedTerms(articles []Articles, query string) []string {
+	skip := map[string]bool{}
+	for _, w := range words(query) {
+		skip[w] = true
+	}
+	salient := func(w string) bool {
+		return len([]rune(w)) > 2 && !stopwords[w] && !skip[w]
+	}
+
+	counts := map[string]int{}
+	for _, a := range articles {
+		// count each term once per title, a headline repeating a word isn't a trend
+		seen := map[string]bool{}
+		ws := words(cleanText(a.Title))
+		for i, w := range ws {
+			if !salient(w) {
+				continue
+			}
+			seen[w] = true
+			if i+1 < len(ws) && salient(ws[i+1]) {
+				seen[w+" "+ws[i+1]] = true
+			}
+		}
+		for term := range seen {
+			counts[term]++
+		}
+	}
+
+	var terms []string
+	for term, n := range counts {
+		if n >= 2 {
+			terms = append(terms, term)
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		a, b := terms[i], terms[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		if pa, pb := strings.Contains(a, " "), strings.Contains(b, " "); pa != pb {
+			return pa
+		}
+		return a < b
+	})
+
+	// a word already covered by a chosen pair would be a near-duplicate suggestion
+	var related []string
+	covered := map[string]bool{}
+	for _, term := range terms {
+		if len(related) == maxRelatedTerms {
+			break
+		}
+		if covered[term] {
+			continue
+		}
+		related = append(related, term)
+		for _, w := range strings.Fields(term) {
+			covered[w] = true
+		}
+	}
+	return related
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRelatedTerms(t *testing.T) {
+	headlines := func(titles ...string) []Articles {
+		articles := make([]Articles, len(titles))
+		for i, title := range titles {
+			articles[i] = Articles{Title: title}
+		}
+		return articles
+	}
+	tests := []struct {
+		name     string
+		articles []Articles
+		query    string
+		want     []string
+	}{
+		{
+			name:     "pair beats its words",
+			articles: headlines("Interest rates rise again", "Bank raises interest rates", "Why interest rates matter"),
+			query:    "economy",
+			want:     []string{"interest rates"},
+		},
+		{
+			name:     "query words left out",
+			articles: headlines("Tesla recalls cars", "Tesla profits fall", "Tesla cars sell"),
+			query:    "Tesla",
+			want:     []string{"cars"},
+		},
+		{
+			name:     "needs two titles",
+			articles: headlines("Tesla recalls cars", "Apple ships phones"),
+			query:    "news",
+			want:     nil,
+		},
+		{
+			name:     "once per title",
+			articles: headlines("Vote vote vote", "Election day"),
+			query:    "news",
+			want:     nil,
+		},
+		{
+			name:     "stopwords and short words skipped",
+			articles: headlines("The AI and the law", "The AI and the city"),
+			query:    "news",
+			want:     nil,
+		},
+		{
+			name:     "markup ignored, ties alphabetical",
+			articles: headlines("<b>Mars</b> rover lands", "Mars mission delayed", "Moon rover tested", "Moon base plans"),
+			query:    "space",
+			want:     []string{"mars", "moon", "rover"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := relatedTerms(tt.articles, tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("relatedTerms() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
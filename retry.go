@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// retryBackoff is the wait before the first retry, doubling for each one after it
+const retryBackoff = 200 * time.Millisecond
+
+// retryBudget bounds the calls one upstream request may make: at most attempts of them, all
+// within total. The breaker and singleflight sit in front, so a request retries once however
+// many callers share it, and an open breaker stops it from being tried at all.
+type retryBudget struct {
+	attempts int
+	// total covers every attempt and the waits between them, zero leaves it to ctx
+	total time.Duration
+}
+
+// retryable reports whether err might go away when asked again: transport errors and failures
+// on newsapi's side, but not an answer about the request or the key, nor a cancellation
+func retryable(err error) bool {
+	if isCanceled(err) || errors.Is(err, ErrNotConfigured) {
+		return false
+	}
+	var apiErr *NewsAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == codeUnexpectedError || apiErr.Code == codeUnexpectedStatus
+	}
+	return true
+}
+
+// do calls fn until it succeeds, fails for good or the budget runs out, returning its last
+// error. A retry is only started when its backoff still fits in the budget. Running out of
+// time is reported as its own error, not as ctx's, so shared doesn't take it for a caller
+// that went away and try again.
+func (b retryBudget) do(ctx context.Context, fn func(context.Context) error) error {
+	parent := ctx
+	if b.total > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.total)
+		defer cancel()
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || !retryable(err) || attempt >= b.attempts || !backoff(ctx, retryBackoff<<(attempt-1)) {
+			break
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	if parentErr := parent.Err(); parentErr != nil {
+		return parentErr
+	}
+	if isCanceled(err) {
+		return fmt.Errorf("newsapi: no answer within the %s retry budget", b.total)
+	}
+	return err
+}
+
+// backoff waits for d, reporting false straight away when ctx would expire first, or when it
+// does while waiting
+func backoff(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "transport error", err: errors.New("connection reset by peer"), want: true},
+		{name: "unexpected status", err: fmt.Errorf("newsapi: unexpected status %d", http.StatusBadGateway), want: true},
+		{name: "newsapi's failure", err: &NewsAPIError{Code: codeUnexpectedError}, want: true},
+		{name: "status not ok", err: &NewsAPIError{Code: codeUnexpectedStatus}, want: true},
+		{name: "rate limited", err: &NewsAPIError{Code: codeRateLimited}, want: false},
+		{name: "bad request", err: &NewsAPIError{Code: "parameterInvalid"}, want: false},
+		{name: "bad key", err: ErrNotConfigured, want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "deadline", err: fmt.Errorf("get: %w", context.DeadlineExceeded), want: false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("%s: retryable(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryBudgetDo(t *testing.T) {
+	failing := errors.New("connection reset by peer")
+	tests := []struct {
+		name      string
+		budget    retryBudget
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "zero budget tries once", budget: retryBudget{}, errs: []error{failing, nil}, wantCalls: 1, wantErr: failing},
+		{name: "succeeds on a retry", budget: retryBudget{attempts: 3}, errs: []error{failing, nil}, wantCalls: 2},
+		{name: "out of attempts", budget: retryBudget{attempts: 2}, errs: []error{failing, failing, nil}, wantCalls: 2, wantErr: failing},
+		{name: "not retryable", budget: retryBudget{attempts: 3}, errs: []error{ErrNotConfigured, nil}, wantCalls: 1, wantErr: ErrNotConfigured},
+		{name: "backoff doesn't fit", budget: retryBudget{attempts: 3, total: retryBackoff / 2}, errs: []error{failing, nil}, wantCalls: 1, wantErr: failing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := tt.budget.do(context.Background(), func(context.Context) error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("do() = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("fn called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryBudgetTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		cancel  bool
+		wantErr string
+	}{
+		{name: "budget runs out", wantErr: "retry budget"},
+		{name: "caller goes away", cancel: true, wantErr: context.Canceled.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			b := retryBudget{attempts: 3, total: 50 * time.Millisecond}
+			err := b.do(ctx, func(ctx context.Context) error {
+				if tt.cancel {
+					cancel()
+				}
+				<-ctx.Done()
+				return ctx.Err()
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("do() = %v, want an error about %q", err, tt.wantErr)
+			}
+			// shared tells a caller that went away from a request that ran out of time this way
+			if got := errors.Is(err, context.Canceled); got != tt.cancel {
+				t.Errorf("do() is context.Canceled = %v, want %v", got, tt.cancel)
+			}
+		})
+	}
+}
+
+func TestNewsClientRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantErr  bool
+		wantHits int32
+	}{
+		{name: "retried after a 502", status: http.StatusBadGateway, wantHits: 2},
+		{name: "retried after newsapi's failure", status: http.StatusInternalServerError, body: `{"status":"error","code":"unexpectedError","message":"Try again"}`, wantHits: 2},
+		{name: "rate limit not retried", status: http.StatusTooManyRequests, body: `{"status":"error","code":"rateLimited","message":"Slow down"}`, wantErr: true, wantHits: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if hits.Add(1) == 1 {
+					w.WriteHeader(tt.status)
+					w.Write([]byte(tt.body))
+					return
+				}
+				w.Write([]byte(`{"status":"ok","totalResults":0,"articles":[]}`))
+			}))
+			t.Cleanup(srv.Close)
+			c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(10), time.Minute)
+			c.retry = retryBudget{attempts: 2}
+			_, err := c.Everything(context.Background(), url.Values{"q": {"go"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Everything() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("newsapi got %d requests, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
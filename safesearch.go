@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const safeSearchCookie = "safesearch"
+
+// defaultSafeSearchTerms are kept out of safe searches unless -safe-search-terms gives others
+var defaultSafeSearchTerms = []string{"porn", "porno", "xxx", "nsfw", "nude", "nudes", "sex tape", "hentai", "erotic"}
+
+// defaultSafeSearchDomains are adult sites excluded from safe searches unless
+// -safe-search-domains gives others
+var defaultSafeSearchDomains = []string{
+	"pornhub.com", "xvideos.com", "xnxx.com", "xhamster.com", "redtube.com",
+	"youporn.com", "onlyfans.com", "brazzers.com", "chaturbate.com", "spankbang.com",
+}
+
+// safeSearchTerms and safeSearchDomains are what safe search keeps out, set from the flags
+var (
+	safeSearchTerms   = defaultSafeSearchTerms
+	safeSearchDomains = defaultSafeSearchDomains
+)
+
+// safeSearchParam reads the optional safeSearch param, set reports whether it was given at all
+func safeSearchParam(params url.Values) (on, set bool, err error) {
+	v, err := singleParam(params, "safeSearch")
+	if err != nil || v == "" {
+		return false, false, err
+	}
+	switch v {
+	case "1", "true":
+		return true, true, nil
+	case "0", "false":
+		return false, true, nil
+	}
+	return false, false, errors.New("safeSearch must be 1 or 0")
+}
+
+// upstreamQuery is the q sent to newsapi: the query, with the safe search terms excluded
+// when safe search is on
+func (s *Search) upstreamQuery() string {
+	if !s.SafeSearch {
+		return s.query()
+	}
+	return safeQuery(s.query(), safeSearchTerms)
+}
+
+// safeQuery appends each term to q as an excluded word, -"a phrase" for several words, skipping
+// terms q already excludes
+func safeQuery(q string, terms []string) string {
+	excluded := queryTokens(q)
+	for _, t := range terms {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		neg := "-" + quoteTerm(t)
+		if slices.ContainsFunc(excluded, func(e string) bool { return strings.EqualFold(e, neg) }) {
+			continue
+		}
+		excluded = append(excluded, neg)
+		q = strings.TrimSpace(q + " " + neg)
+	}
+	return q
+}
+
+// safeExcludeDomains adds the adult domains to the search's own excluded ones, without duplicates
+func safeExcludeDomains(exclude, domains []string) []string {
+	merged := append([]string(nil), exclude...)
+	for _, d := range domains {
+		merged = appendListParam(merged, strings.ToLower(d))
+	}
+	return merged
+}
+
+// blockedFor is the pattern filterBlocked drops for the search: the blocked words, and the safe
+// search terms when it is on
+func (s *Search) blockedFor() *regexp.Regexp {
+	if !s.SafeSearch {
+		return blockedPattern
+	}
+	return safeBlockedPattern
+}
+
+// excludedFor is the domains kept out of the search: its own, and the adult ones when safe
+// search is on
+func (s *Search) excludedFor() []string {
+	if !s.SafeSearch {
+		return s.ExcludeDomains
+	}
+	return safeExcludeDomains(s.ExcludeDomains, safeSearchDomains)
+}
+
+// readSafeSearch applies the safe search cookie when the request didn't give safeSearch, and
+// remembers a given one in the cookie for the next searches
+func (s *Search) readSafeSearch(w http.ResponseWriter, r *http.Request) {
+	if !s.safeSearchSet {
+		s.SafeSearch = safeSearchCookieOn(r)
+		return
+	}
+	value := "0"
+	if s.SafeSearch {
+		value = "1"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     safeSearchCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// safeSearchCookieOn reports whether the safe search cookie asks for it
+func safeSearchCookieOn(r *http.Request) bool {
+	c, err := r.Cookie(safeSearchCookie)
+	return err == nil && c.Value == "1"
+}
+
+// SafeSearchURL is the current search with safe search turned on or off, back on the first page
+func (s *Search) SafeSearchURL(on bool) string {
+	v := s.linkParams()
+	v.Set("safeSearch", "0")
+	if on {
+		v.Set("safeSearch", "1")
+	}
+	return "/search?" + v.Encode()
+}
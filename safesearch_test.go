@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSafeSearchParam(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantOn  bool
+		wantSet bool
+		wantErr bool
+	}{
+		{query: ""},
+		{query: "safeSearch=1", wantOn: true, wantSet: true},
+		{query: "safeSearch=true", wantOn: true, wantSet: true},
+		{query: "safeSearch=0", wantSet: true},
+		{query: "safeSearch=false", wantSet: true},
+		{query: "safeSearch=maybe", wantErr: true},
+		{query: "safeSearch=1&safeSearch=0", wantErr: true},
+	}
+	for _, tt := range tests {
+		params, _ := url.ParseQuery(tt.query)
+		on, set, err := safeSearchParam(params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("safeSearchParam(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if on != tt.wantOn || set != tt.wantSet {
+			t.Errorf("safeSearchParam(%q) = %v, %v, want %v, %v", tt.query, on, set, tt.wantOn, tt.wantSet)
+		}
+	}
+}
+
+func TestSafeQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		q     string
+		terms []string
+		want  string
+	}{
+		{name: "words and phrases", q: "celebrity news", terms: []string{"nsfw", "sex tape"}, want: `celebrity news -nsfw -"sex tape"`},
+		{name: "already excluded", q: "celebrity -NSFW", terms: []string{"nsfw"}, want: "celebrity -NSFW"},
+		{name: "blank terms skipped", q: "go", terms: []string{" ", ""}, want: "go"},
+		{name: "no terms", q: "go", terms: nil, want: "go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := safeQuery(tt.q, tt.terms); got != tt.want {
+				t.Errorf("safeQuery(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeExcludeDomains(t *testing.T) {
+	exclude := []string{"example.com"}
+	got := safeExcludeDomains(exclude, []string{"Adult.example", "example.com"})
+	if want := []string{"example.com", "adult.example"}; !slices.Equal(got, want) {
+		t.Errorf("safeExcludeDomains() = %q, want %q", got, want)
+	}
+	if len(exclude) != 1 {
+		t.Errorf("safeExcludeDomains changed the search's own domains to %q", exclude)
+	}
+}
+
+func TestSafeSearchHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		cookie      string
+		wantSafe    bool
+		wantCookie  string
+		wantArticle bool
+	}{
+		{name: "off by default", query: "q=go", wantArticle: true},
+		{name: "turned on", query: "q=go&safeSearch=1", wantSafe: true, wantCookie: "1"},
+		{name: "from the cookie", query: "q=go", cookie: "1", wantSafe: true},
+		{name: "param wins over the cookie", query: "q=go&safeSearch=0", cookie: "1", wantCookie: "0", wantArticle: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setBlocklist(t, blockedWords, []string{"nsfw"})
+			setVar(t, &safeSearchDomains, []string{"adult.example"})
+			var sent atomic.Value
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sent.Store(r.URL.Query())
+				flagged := testArticle(2)
+				flagged.Title = "NSFW story"
+				json.NewEncoder(w).Encode(Results{Status: "ok", TotalResults: 2, Articles: []Articles{testArticle(1), flagged}})
+			}))
+			t.Cleanup(srv.Close)
+			useNewsAPI(t, srv)
+
+			r := httptest.NewRequest(http.MethodGet, "/search?"+tt.query, nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: safeSearchCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			searchHandler(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			params := sent.Load().(url.Values)
+			if got := strings.HasSuffix(params.Get("q"), "-nsfw"); got != tt.wantSafe {
+				t.Errorf("q = %q, safe search terms excluded = %v, want %v", params.Get("q"), got, tt.wantSafe)
+			}
+			if got := params.Get("excludeDomains") == "adult.example"; got != tt.wantSafe {
+				t.Errorf("excludeDomains = %q, adult domains excluded = %v, want %v", params.Get("excludeDomains"), got, tt.wantSafe)
+			}
+			if got := strings.Contains(w.Body.String(), "NSFW story"); got != tt.wantArticle {
+				t.Errorf("flagged article shown = %v, want %v", got, tt.wantArticle)
+			}
+			var cookie string
+			for _, c := range w.Result().Cookies() {
+				if c.Name == safeSearchCookie {
+					cookie = c.Value
+				}
+			}
+			if cookie != tt.wantCookie {
+				t.Errorf("safe search cookie set to %q, want %q", cookie, tt.wantCookie)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"strings"
+)
+
+// stripTags drops anything that looks like a markup tag or comment, leaving a lone "<" as in "a < b" alone
+func stripTags(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '<' && i+1 < len(s) && isTagStart(s[i+1]) {
+			if end := strings.IndexByte(s[i:], '>'); end >= 0 {
+				i += end
+				b.WriteByte(' ')
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isTagStart(c byte) bool {
+	return c == '/' || c == '!' || c == '?' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// cleanText turns a newsapi snippet into plain text: tags are removed, entities decoded
+// and whitespace collapsed. Entity-encoded markup such as &lt;p&gt; is stripped as well.
+func cleanText(s string) string {
+	s = html.UnescapeString(stripTags(s))
+	return strings.Join(strings.Fields(stripTags(s)), " ")
+}
+
+// sanitizeHTML is cleanText made safe to drop into a page. It is the only place snippet text
+// is marked as template.HTML, and what it marks has been re-escaped.
+func sanitizeHTML(s string) template.HTML {
+	return template.HTML(html.EscapeString(cleanText(s)))
+}
+
+func (a *Articles) CleanTitle() template.HTML {
+	return sanitizeHTML(a.Title)
+}
+
+func (a *Articles) CleanDescription() template.HTML {
+	return sanitizeHTML(a.Description)
+}
+
+func (a *Articles) CleanContent() template.HTML {
+	return sanitizeHTML(a.Content)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain", in: "Markets rally", want: "Markets rally"},
+		{name: "tags", in: "<p>Markets <b>rally</b></p>", want: "Markets rally"},
+		{name: "entities", in: "Q&amp;A with &quot;experts&quot;", want: `Q&A with "experts"`},
+		{name: "encoded markup", in: "&lt;script&gt;alert(1)&lt;/script&gt;News", want: "alert(1) News"},
+		{name: "comments", in: "a<!-- hidden -->b", want: "a b"},
+		{name: "lone less-than", in: "a < b and 3<4", want: "a < b and 3<4"},
+		{name: "whitespace", in: "  two\n\tlines  ", want: "two lines"},
+		{name: "unclosed tag kept", in: "width <b", want: "width <b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanText(tt.in); got != tt.want {
+				t.Errorf("cleanText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		in   string
+		want template.HTML
+	}{
+		{in: "<b>Tom</b> &amp; Jerry", want: "Tom &amp; Jerry"},
+		{in: "&lt;img src=x onerror=alert(1)&gt;", want: ""},
+		{in: `say "hi" <i>now</i>`, want: "say &#34;hi&#34; now"},
+		{in: "1 < 2", want: "1 &lt; 2"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeHTML(tt.in); got != tt.want {
+			t.Errorf("sanitizeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
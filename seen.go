@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	seenCookie = "seen"
+	// maxSeenCookie leaves room beside the saved articles cookie in the same request
+	maxSeenCookie = 2000
+	// seenKeyLength is how many hex digits of a URL's SHA-256 identify it, plenty within a search
+	seenKeyLength = 8
+)
+
+// dedupePages turns on leaving articles already shown on earlier pages off later ones, set by
+// -dedupe-pages
+var dedupePages *bool
+
+// debugPageOverlap logs results pages that repeat much of the page before them, set by
+// -debug-page-overlap
+var debugPageOverlap *bool
+
+// pageOverlapWarn is the share of a page's articles also on the page before it that gets logged:
+// newsapi reordering its results between requests, pages then seem to loop
+const pageOverlapWarn = 0.3
+
+// seenPages is what the seen cookie remembers of one search: a short key of each article's URL,
+// by the page it was shown on, run together since they all have seenKeyLength. A different
+// search starts over.
+type seenPages struct {
+	Search string         `json:"s"`
+	Pages  map[int]string `json:"p"`
+}
+
+// seenKey identifies an article in the cookie, from its URL as compared for duplicates
+func seenKey(rawURL string) string {
+	sum := sha256.Sum256([]byte(dedupeKey(rawURL)))
+	return hex.EncodeToString(sum[:])[:seenKeyLength]
+}
+
+// filterSeen drops the articles whose seenKey is in seen. It returns a new slice and leaves
+// articles untouched.
+func filterSeen(articles []Articles, seen map[string]bool) []Articles {
+	if len(seen) == 0 {
+		return articles
+	}
+	kept := make([]Articles, 0, len(articles))
+	for _, a := range articles {
+		if !seen[seenKey(a.URL)] {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+// pageOverlap is the share of articles whose seenKey is in previous, 0 for no articles
+func pageOverlap(articles []Articles, previous map[string]bool) float64 {
+	if len(articles) == 0 {
+		return 0
+	}
+	shared := 0
+	for _, a := range articles {
+		if previous[seenKey(a.URL)] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(articles))
+}
+
+// before is the set of articles shown on the pages before page, the ones to leave off it
+func (p seenPages) before(page int) map[string]bool {
+	seen := map[string]bool{}
+	for n, keys := range p.Pages {
+		if n < page {
+			addKeys(seen, keys)
+		}
+	}
+	return seen
+}
+
+// on is the set of articles shown on page, empty when it isn't remembered
+func (p seenPages) on(page int) map[string]bool {
+	seen := map[string]bool{}
+	addKeys(seen, p.Pages[page])
+	return seen
+}
+
+func addKeys(seen map[string]bool, keys string) {
+	for i := 0; i+seenKeyLength <= len(keys); i += seenKeyLength {
+		seen[keys[i:i+seenKeyLength]] = true
+	}
+}
+
+// record remembers the articles shown on page. What was remembered for it and later pages is
+// forgotten: going back shows them again, paging forward filters them anew.
+func (p *seenPages) record(page int, articles []Articles) {
+	for n := range p.Pages {
+		if n >= page {
+			delete(p.Pages, n)
+		}
+	}
+	var keys strings.Builder
+	for _, a := range articles {
+		keys.WriteString(seenKey(a.URL))
+	}
+	p.Pages[page] = keys.String()
+}
+
+// readSeen returns what the request's cookie remembers of search, empty for another search or a
+// missing or tampered cookie
+func readSeen(r *http.Request, search string) seenPages {
+	fresh := seenPages{Search: search, Pages: map[int]string{}}
+	c, err := r.Cookie(seenCookie)
+	if err != nil {
+		return fresh
+	}
+	payload, err := verify(c.Value)
+	if err != nil {
+		return fresh
+	}
+	var p seenPages
+	if err := json.Unmarshal(payload, &p); err != nil || p.Search != search || p.Pages == nil {
+		return fresh
+	}
+	return p
+}
+
+// encodeSeen signs the seen pages, forgetting the earliest pages until the cookie fits in
+// maxSeenCookie
+func encodeSeen(p seenPages) string {
+	for {
+		payload, _ := json.Marshal(p)
+		token := sign(payload)
+		if len(token) <= maxSeenCookie || len(p.Pages) <= 1 {
+			return token
+		}
+		delete(p.Pages, slices.Min(mapKeys(p.Pages)))
+	}
+}
+
+func mapKeys(pages map[int]string) []int {
+	keys := make([]int, 0, len(pages))
+	for n := range pages {
+		keys = append(keys, n)
+	}
+	return keys
+}
+
+// searchKey tells searches apart in the seen cookie: the same query with other filters is
+// another search
+func (s *Search) searchKey() string {
+	return queryHash(s.linkParams().Encode())
+}
+
+// trackSeen remembers the articles of the fetched page in the seen cookie, once NextPage has
+// moved past it. With -debug-page-overlap it first logs how much of the page the one before held,
+// as fetched, the first time the page is seen, and with -dedupe-pages it leaves the articles of
+// earlier pages off. The results page and each load more batch of a page call it alike.
+func (s *Search) trackSeen(w http.ResponseWriter, r *http.Request) {
+	page := s.CurrentPage()
+	seen := readSeen(r, s.searchKey())
+	_, again := seen.Pages[page]
+	if _, ok := seen.Pages[page-1]; *debugPageOverlap && ok && !again {
+		if share := pageOverlap(s.Results.Articles, seen.on(page-1)); share >= pageOverlapWarn {
+			slog.Warn("results page overlaps the previous one", "query_hash", s.QueryHash(), "page", page, "overlap", fmt.Sprintf("%.0f%%", share*100))
+		}
+	}
+	if *dedupePages {
+		s.Results.Articles = filterSeen(s.Results.Articles, seen.before(page))
+	}
+	seen.record(page, s.Results.Articles)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     seenCookie,
+		Value:    encodeSeen(seen),
+		Path:     "/search",
+		MaxAge:   int((24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
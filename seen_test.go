@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSeenKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{a: "https://www.news.example.com/a/1", b: "http://news.example.com/a/1/", same: true},
+		{a: "https://news.example.com/a/1#top", b: "https://news.example.com/a/1", same: true},
+		{a: "https://news.example.com/a/1", b: "https://news.example.com/a/2", same: false},
+	}
+	for _, tt := range tests {
+		if got := seenKey(tt.a) == seenKey(tt.b); got != tt.same {
+			t.Errorf("seenKey(%q) == seenKey(%q) is %v, want %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+	if got := len(seenKey("https://news.example.com/a/1")); got != seenKeyLength {
+		t.Errorf("seenKey is %d long, want %d", got, seenKeyLength)
+	}
+}
+
+func TestFilterSeen(t *testing.T) {
+	articles := []Articles{testArticle(1), testArticle(2), testArticle(3)}
+	tests := []struct {
+		name string
+		seen map[string]bool
+		want []string
+	}{
+		{name: "nothing seen", seen: nil, want: []string{"Story 1", "Story 2", "Story 3"}},
+		{name: "some seen", seen: map[string]bool{seenKey(articles[1].URL): true}, want: []string{"Story 1", "Story 3"}},
+		{name: "all seen", seen: map[string]bool{seenKey(articles[0].URL): true, seenKey(articles[1].URL): true, seenKey(articles[2].URL): true}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := titles(filterSeen(articles, tt.seen)); !slices.Equal(got, tt.want) {
+				t.Errorf("filterSeen() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if len(articles) != 3 {
+		t.Error("filterSeen changed its input")
+	}
+}
+
+func TestSeenPagesRecord(t *testing.T) {
+	p := seenPages{Search: "s", Pages: map[int]string{}}
+	p.record(1, []Articles{testArticle(1), testArticle(2)})
+	p.record(2, []Articles{testArticle(3)})
+	p.record(3, []Articles{testArticle(4)})
+
+	tests := []struct {
+		name   string
+		got    map[string]bool
+		wantOf []int
+	}{
+		{name: "before page 1", got: p.before(1), wantOf: nil},
+		{name: "before page 3", got: p.before(3), wantOf: []int{1, 2, 3}},
+		{name: "on page 3", got: p.on(3), wantOf: []int{4}},
+		{name: "on a page not seen", got: p.on(9), wantOf: nil},
+	}
+	for _, tt := range tests {
+		want := map[string]bool{}
+		for _, n := range tt.wantOf {
+			want[seenKey(testArticle(n).URL)] = true
+		}
+		if !maps.Equal(tt.got, want) {
+			t.Errorf("%s: %d articles, want those of stories %v", tt.name, len(tt.got), tt.wantOf)
+		}
+	}
+
+	// going back to page 2 forgets what came after it
+	p.record(2, []Articles{testArticle(5)})
+	if got := slices.Sorted(maps.Keys(p.Pages)); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("pages after going back = %v, want [1 2]", got)
+	}
+	if !p.on(2)[seenKey(testArticle(5).URL)] {
+		t.Error("page 2 doesn't hold what was recorded for it again")
+	}
+}
+
+// seenRequest is a request carrying value as its seen cookie, none when it is empty
+func seenRequest(target, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if value != "" {
+		r.AddCookie(&http.Cookie{Name: seenCookie, Value: value})
+	}
+	return r
+}
+
+// tampered changes the first character of a signed token's payload, so its MAC no longer
+// matches
+func tampered(token string) string {
+	first := "A"
+	if strings.HasPrefix(token, first) {
+		first = "B"
+	}
+	return first + token[1:]
+}
+
+func TestReadSeen(t *testing.T) {
+	remembered := seenPages{Search: "s", Pages: map[int]string{1: seenKey("https://news.example.com/a/1")}}
+	valid := encodeSeen(remembered)
+	payload, _ := json.Marshal(remembered)
+	tests := []struct {
+		name      string
+		cookie    string
+		wantPages int
+	}{
+		{name: "no cookie", cookie: "", wantPages: 0},
+		{name: "remembered", cookie: valid, wantPages: 1},
+		{name: "tampered", cookie: tampered(valid), wantPages: 0},
+		{name: "unsigned", cookie: string(payload), wantPages: 0},
+		{name: "another search", cookie: encodeSeen(seenPages{Search: "other", Pages: remembered.Pages}), wantPages: 0},
+		{name: "signed but not seen pages", cookie: sign([]byte(`{"s":"s"}`)), wantPages: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readSeen(seenRequest("/search", tt.cookie), "s")
+			if got.Search != "s" || got.Pages == nil {
+				t.Fatalf("readSeen() = %+v, want seen pages of the search", got)
+			}
+			if len(got.Pages) != tt.wantPages {
+				t.Errorf("readSeen() remembers %d pages, want %d", len(got.Pages), tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestEncodeSeenFits(t *testing.T) {
+	p := seenPages{Search: "s", Pages: map[int]string{}}
+	for page := 1; page <= 20; page++ {
+		var keys strings.Builder
+		for n := range 20 {
+			keys.WriteString(seenKey(fmt.Sprintf("https://news.example.com/%d/%d", page, n)))
+		}
+		p.Pages[page] = keys.String()
+	}
+	token := encodeSeen(p)
+	if len(token) > maxSeenCookie {
+		t.Fatalf("seen cookie is %d bytes, want at most %d", len(token), maxSeenCookie)
+	}
+	got := readSeen(seenRequest("/search", token), "s")
+	if _, ok := got.Pages[20]; !ok {
+		t.Error("the latest page was forgotten")
+	}
+	if _, ok := got.Pages[1]; ok {
+		t.Error("the earliest page was kept")
+	}
+}
+
+// newShiftingNewsAPI serves pages of five that each start with the last two articles of the page
+// before, as newsapi does when new articles push its results along between requests
+func newShiftingNewsAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+		var articles []Articles
+		for n := (page-1)*3 + 1; n <= (page-1)*3+5; n++ {
+			articles = append(articles, testArticle(n))
+		}
+		json.NewEncoder(w).Encode(Results{Status: "ok", TotalResults: 100, Articles: articles})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// shownTitles lists the card titles of a results page or load more batch
+func shownTitles(body string) []string {
+	var got []string
+	for _, m := range moreTitlePattern.FindAllStringSubmatch(body, -1) {
+		got = append(got, m[1])
+	}
+	return got
+}
+
+func seenCookieOf(w *httptest.ResponseRecorder) string {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == seenCookie {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestDedupePages(t *testing.T) {
+	tests := []struct {
+		name    string
+		dedupe  bool
+		handler http.HandlerFunc
+		page2   string
+		tamper  bool
+		want    []string
+	}{
+		{name: "off", dedupe: false, handler: searchHandler, page2: "/search?q=go&pageSize=5&page=2", want: []string{"Story 4", "Story 5", "Story 6", "Story 7", "Story 8"}},
+		{name: "results page", dedupe: true, handler: searchHandler, page2: "/search?q=go&pageSize=5&page=2", want: []string{"Story 6", "Story 7", "Story 8"}},
+		{name: "load more batch", dedupe: true, handler: moreHandler, page2: "/search/more?q=go&pageSize=5&page=2&offset=0", want: []string{"Story 6", "Story 7", "Story 8"}},
+		{name: "tampered cookie", dedupe: true, handler: searchHandler, page2: "/search?q=go&pageSize=5&page=2", tamper: true, want: []string{"Story 4", "Story 5", "Story 6", "Story 7", "Story 8"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, newShiftingNewsAPI(t))
+			setFlag(t, &dedupePages, tt.dedupe)
+			// the overlap log keeps the cookie when dedupe is off
+			setFlag(t, &debugPageOverlap, !tt.dedupe)
+
+			first := httptest.NewRecorder()
+			tt.handler(first, seenRequest(strings.Replace(tt.page2, "page=2", "page=1", 1), ""))
+			cookie := seenCookieOf(first)
+			if cookie == "" {
+				t.Fatal("page 1 set no seen cookie")
+			}
+			if tt.tamper {
+				cookie = tampered(cookie)
+			}
+
+			w := httptest.NewRecorder()
+			tt.handler(w, seenRequest(tt.page2, cookie))
+			if w.Code != http.StatusOK {
+				t.Fatalf("page 2 status = %d: %s", w.Code, w.Body)
+			}
+			if got := shownTitles(w.Body.String()); !slices.Equal(got, tt.want) {
+				t.Errorf("page 2 shows %q, want %q", got, tt.want)
+			}
+			// page 2 is remembered for page 3
+			seen := readSeen(seenRequest("/search", seenCookieOf(w)), searchKeyOf(t, tt.page2))
+			if _, ok := seen.Pages[2]; !ok {
+				t.Error("page 2 wasn't recorded in the seen cookie")
+			}
+		})
+	}
+}
+
+// searchKeyOf is the seen cookie's key for the search of target
+func searchKeyOf(t *testing.T, target string) string {
+	t.Helper()
+	s, err := newSearch(httptest.NewRequest(http.MethodGet, target, nil).URL.Query())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.searchKey()
+}
+
+func TestPageOverlap(t *testing.T) {
+	previous := map[string]bool{seenKey(testArticle(1).URL): true, seenKey(testArticle(2).URL): true}
+	tests := []struct {
+		name     string
+		articles []Articles
+		want     float64
+	}{
+		{name: "no articles", articles: nil, want: 0},
+		{name: "none shared", articles: []Articles{testArticle(3), testArticle(4)}, want: 0},
+		{name: "some shared", articles: []Articles{testArticle(2), testArticle(3), testArticle(4), testArticle(5)}, want: 0.25},
+		{name: "all shared", articles: []Articles{testArticle(1), testArticle(2)}, want: 1},
+	}
+	for _, tt := range tests {
+		if got := pageOverlap(tt.articles, previous); got != tt.want {
+			t.Errorf("%s: pageOverlap() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDebugPageOverlap(t *testing.T) {
+	tests := []struct {
+		name    string
+		debug   bool
+		api     func(t *testing.T) *httptest.Server
+		again   bool
+		wantLog bool
+	}{
+		{name: "overlapping pages", debug: true, api: newShiftingNewsAPI, wantLog: true},
+		{name: "off", debug: false, api: newShiftingNewsAPI, wantLog: false},
+		{name: "pages that don't overlap", debug: true, api: func(t *testing.T) *httptest.Server { return newFakeNewsAPI(t, 100).Server }, wantLog: false},
+		{name: "page seen before", debug: true, api: newShiftingNewsAPI, again: true, wantLog: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useNewsAPI(t, tt.api(t))
+			setFlag(t, &debugPageOverlap, tt.debug)
+			// dedupe keeps the cookie when the log is off
+			setFlag(t, &dedupePages, !tt.debug)
+
+			first := httptest.NewRecorder()
+			searchHandler(first, seenRequest("/search?q=go&pageSize=5&page=1", ""))
+			cookie := seenCookieOf(first)
+			if tt.again {
+				second := httptest.NewRecorder()
+				searchHandler(second, seenRequest("/search?q=go&pageSize=5&page=2", cookie))
+				cookie = seenCookieOf(second)
+			}
+
+			var logs bytes.Buffer
+			old := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+			defer slog.SetDefault(old)
+
+			w := httptest.NewRecorder()
+			searchHandler(w, seenRequest("/search?q=go&pageSize=5&page=2", cookie))
+			if w.Code != http.StatusOK {
+				t.Fatalf("page 2 status = %d: %s", w.Code, w.Body)
+			}
+			out := logs.String()
+			if got := strings.Contains(out, "results page overlaps the previous one"); got != tt.wantLog {
+				t.Errorf("overlap logged = %v, want %v: %s", got, tt.wantLog, out)
+			}
+			if tt.wantLog && (!strings.Contains(out, "overlap=40%") || !strings.Contains(out, "page=2") || strings.Contains(out, "q=go")) {
+				t.Errorf("log = %s, want page 2's 40%% overlap without the query", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsOptions pick how main serves: provided cert/key files, Let's Encrypt for domains, or plain HTTP
+type tlsOptions struct {
+	certFile, keyFile string
+	domains           []string
+	certCache         string
+	// redirectAddr, when set, gets a plain HTTP listener that sends clients to HTTPS
+	redirectAddr string
+	// http2 offers HTTP/2 to TLS clients, h2c speaks it without TLS to clients that know to ask
+	http2, h2c bool
+}
+
+func (o tlsOptions) validate() error {
+	if (o.certFile == "") != (o.keyFile == "") {
+		return errors.New("tls-cert and tls-key must be given together")
+	}
+	if o.certFile != "" && len(o.domains) > 0 {
+		return errors.New("use either tls-cert/tls-key or domain, not both")
+	}
+	if o.redirectAddr != "" && !o.tls() {
+		return errors.New("https-redirect needs TLS to be enabled")
+	}
+	if o.h2c && o.tls() {
+		return errors.New("h2c is only for plain HTTP, over TLS use http2")
+	}
+	return nil
+}
+
+func (o tlsOptions) tls() bool {
+	return o.certFile != "" || len(o.domains) > 0
+}
+
+// protocols is what srv speaks: HTTP/1.1 always, HTTP/2 over TLS unless turned off, and h2c on request
+func (o tlsOptions) protocols() *http.Protocols {
+	p := &http.Protocols{}
+	p.SetHTTP1(true)
+	p.SetHTTP2(o.http2)
+	p.SetUnencryptedHTTP2(o.h2c)
+	return p
+}
+
+// withoutH2 stops advertising h2 in ALPN, which a TLS config from autocert does by default
+func withoutH2(protos []string) []string {
+	var kept []string
+	for _, p := range protos {
+		if p != "h2" {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// serve runs srv until it fails, over TLS when o asks for it
+func serve(srv *http.Server, o tlsOptions) error {
+	srv.Protocols = o.protocols()
+
+	switch {
+	case len(o.domains) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(o.domains...),
+			Cache:      autocert.DirCache(o.certCache),
+		}
+		srv.TLSConfig = m.TLSConfig()
+		if !o.http2 {
+			srv.TLSConfig.NextProtos = withoutH2(srv.TLSConfig.NextProtos)
+		}
+		// the redirect listener doubles as the ACME http-01 challenge responder
+		if o.redirectAddr != "" {
+			go redirectListener(o.redirectAddr, m.HTTPHandler(nil))
+		}
+		return srv.ListenAndServeTLS("", "")
+
+	case o.certFile != "":
+		if o.redirectAddr != "" {
+			go redirectListener(o.redirectAddr, httpsRedirect(srv.Addr))
+		}
+		return srv.ListenAndServeTLS(o.certFile, o.keyFile)
+
+	default:
+		return srv.ListenAndServe()
+	}
+}
+
+func redirectListener(addr string, h http.Handler) {
+	if err := http.ListenAndServe(addr, h); err != nil {
+		log.Printf("https redirect listener: %v", err)
+	}
+}
+
+// httpsRedirect sends every request to the same URL over HTTPS on the port tlsAddr listens on
+func httpsRedirect(tlsAddr string) http.Handler {
+	_, tlsPort, _ := net.SplitHostPort(tlsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if tlsPort != "" && tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// slashPrefixes are routes that are directories, their trailing slash is the canonical form
+var slashPrefixes = []string{"/assets/"}
+
+// withoutTrailingSlash redirects /search/ to /search, and the same for every other route, keeping
+// the query string. GET and HEAD get a 301, other methods a 308 so forms still post their body.
+func withoutTrailingSlash(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if path == "/" || !strings.HasSuffix(path, "/") || hasSlashPrefix(path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		target := "/" + strings.Trim(path, "/")
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, target, status)
+	})
+}
+
+func hasSlashPrefix(path string) bool {
+	for _, p := range slashPrefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// withBodyLimit caps request bodies at limit bytes, reading past it fails with *http.MaxBytesError
+func withBodyLimit(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parseForm parses a posted form, answering 413 when it is over the -max-body-bytes limit and
+// 400 when it is malformed. Handlers stop when it reports false; FormValue alone would quietly
+// see an empty form instead.
+func parseForm(w http.ResponseWriter, r *http.Request) bool {
+	err := r.ParseForm()
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	http.Error(w, "Malformed form", http.StatusBadRequest)
+	return false
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTLSOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    tlsOptions
+		wantErr bool
+	}{
+		{name: "plain HTTP", opts: tlsOptions{}},
+		{name: "cert and key", opts: tlsOptions{certFile: "cert.pem", keyFile: "key.pem"}},
+		{name: "autocert", opts: tlsOptions{domains: []string{"news.example.com"}}},
+		{name: "redirect with TLS", opts: tlsOptions{domains: []string{"news.example.com"}, redirectAddr: ":80"}},
+		{name: "cert without key", opts: tlsOptions{certFile: "cert.pem"}, wantErr: true},
+		{name: "key without cert", opts: tlsOptions{keyFile: "key.pem"}, wantErr: true},
+		{name: "cert and autocert", opts: tlsOptions{certFile: "cert.pem", keyFile: "key.pem", domains: []string{"news.example.com"}}, wantErr: true},
+		{name: "redirect without TLS", opts: tlsOptions{redirectAddr: ":80"}, wantErr: true},
+		{name: "h2c over plain HTTP", opts: tlsOptions{h2c: true}},
+		{name: "h2c with TLS", opts: tlsOptions{certFile: "cert.pem", keyFile: "key.pem", h2c: true}, wantErr: true},
+		{name: "h2c with autocert", opts: tlsOptions{domains: []string{"news.example.com"}, h2c: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		name    string
+		tlsAddr string
+		target  string
+		want    string
+	}{
+		{name: "default port", tlsAddr: ":443", target: "http://news.example.com/search?q=go", want: "https://news.example.com/search?q=go"},
+		{name: "port dropped from the host", tlsAddr: ":443", target: "http://news.example.com:80/", want: "https://news.example.com/"},
+		{name: "other TLS port", tlsAddr: ":8443", target: "http://news.example.com:8080/saved", want: "https://news.example.com:8443/saved"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			httpsRedirect(tt.tlsAddr).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != http.StatusMovedPermanently {
+				t.Errorf("status = %d, want 301", w.Code)
+			}
+			if got := w.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithoutTrailingSlash(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{name: "search", method: http.MethodGet, target: "/search/", wantStatus: http.StatusMovedPermanently, wantLocation: "/search"},
+		{name: "query kept", method: http.MethodGet, target: "/search/?q=go&page=2", wantStatus: http.StatusMovedPermanently, wantLocation: "/search?q=go&page=2"},
+		{name: "several slashes", method: http.MethodGet, target: "/saved//", wantStatus: http.StatusMovedPermanently, wantLocation: "/saved"},
+		{name: "HEAD", method: http.MethodHead, target: "/search/", wantStatus: http.StatusMovedPermanently, wantLocation: "/search"},
+		{name: "POST keeps its body", method: http.MethodPost, target: "/saved/add/", wantStatus: http.StatusPermanentRedirect, wantLocation: "/saved/add"},
+		{name: "no slash", method: http.MethodGet, target: "/search", wantStatus: http.StatusOK},
+		{name: "homepage", method: http.MethodGet, target: "/", wantStatus: http.StatusOK},
+		{name: "directory route", method: http.MethodGet, target: "/assets/", wantStatus: http.StatusOK},
+		{name: "inside a directory route", method: http.MethodGet, target: "/assets/icons/", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := withoutTrailingSlash(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestTLSOptionsProtocols(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      tlsOptions
+		wantHTTP2 bool
+		wantH2C   bool
+	}{
+		{name: "defaults", opts: tlsOptions{http2: true}, wantHTTP2: true},
+		{name: "http2 off", opts: tlsOptions{http2: false}},
+		{name: "h2c", opts: tlsOptions{http2: true, h2c: true}, wantHTTP2: true, wantH2C: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.opts.protocols()
+			if !p.HTTP1() {
+				t.Error("HTTP/1.1 is off")
+			}
+			if p.HTTP2() != tt.wantHTTP2 || p.UnencryptedHTTP2() != tt.wantH2C {
+				t.Errorf("protocols = %v, want http2 %v and h2c %v", p, tt.wantHTTP2, tt.wantH2C)
+			}
+		})
+	}
+}
+
+func TestWithoutH2(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want []string
+	}{
+		{in: []string{"h2", "http/1.1", "acme-tls/1"}, want: []string{"http/1.1", "acme-tls/1"}},
+		{in: []string{"http/1.1"}, want: []string{"http/1.1"}},
+		{in: nil, want: nil},
+	}
+	for _, tt := range tests {
+		if got := withoutH2(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("withoutH2(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestH2CServer(t *testing.T) {
+	tests := []struct {
+		name      string
+		h2c       bool
+		wantProto string
+	}{
+		{name: "h2c on", h2c: true, wantProto: "HTTP/2.0"},
+		{name: "h2c off", h2c: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Proto))
+			}))
+			srv.Config.Protocols = tlsOptions{http2: true, h2c: tt.h2c}.protocols()
+			srv.Start()
+			defer srv.Close()
+
+			// a client that only speaks h2c, as a proxy configured for it would
+			var h2cOnly http.Protocols
+			h2cOnly.SetUnencryptedHTTP2(true)
+			client := &http.Client{Transport: &http.Transport{Protocols: &h2cOnly}}
+			resp, err := client.Get(srv.URL)
+			if tt.wantProto == "" {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("h2c request succeeded with h2c off")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.wantProto {
+				t.Errorf("served over %s, want %s", body, tt.wantProto)
+			}
+		})
+	}
+}
+
+func TestParseFormBodyLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "under the limit", body: "url=https%3A%2F%2Fnews.example.com%2Fa", wantStatus: http.StatusOK},
+		{name: "over the limit", body: "url=" + strings.Repeat("a", 100), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "malformed", body: "url=%zz", wantStatus: http.StatusBadRequest},
+		{name: "empty", body: "", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := withBodyLimit(64, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !parseForm(w, r) {
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			r := httptest.NewRequest(http.MethodPost, "/saved/add", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestFormHandlersBodyLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		handler http.HandlerFunc
+	}{
+		{name: "save bookmark", target: "/saved/add", handler: savedAddHandler},
+		{name: "remove bookmark", target: "/saved/remove", handler: savedRemoveHandler},
+		{name: "save preset", target: "/presets/save", handler: presetSaveHandler},
+		{name: "delete preset", target: "/presets/delete", handler: presetDeleteHandler},
+		{name: "maintenance", target: "/admin/maintenance", handler: maintenanceHandler},
+	}
+	body := "name=" + strings.Repeat("a", 1<<10)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			withBodyLimit(64, tt.handler).ServeHTTP(w, r)
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("status = %d, want 413", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultShortcuts are the homepage's topic shortcuts unless -shortcuts-file gives others,
+// in the file's "Label: params" format
+var defaultShortcuts = []string{
+	"Climate: q=climate&maxAgeHours=72",
+	"Elections: q=election OR elections",
+	"Tech: q=technology&keyword=AI&keyword=startups",
+	"Science: q=science",
+	"Business: q=business&maxAgeHours=24",
+}
+
+// shortcuts are the topic shortcut buttons on the homepage
+var shortcuts []searchPreset
+
+// parseShortcuts reads "Label: params" lines into searches, params being a query string such as
+// q=climate&maxAgeHours=72. Each one is checked like a posted preset, so a shortcut can't link
+// to a search /search would reject.
+func parseShortcuts(lines []string) ([]searchPreset, error) {
+	var parsed []searchPreset
+	for _, line := range lines {
+		label, params, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("shortcut %q should look like Label: q=topic", line)
+		}
+		p, err := newPreset(label, strings.TrimSpace(params))
+		if err != nil {
+			return nil, fmt.Errorf("shortcut %q: %w", line, err)
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
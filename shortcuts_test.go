@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseShortcuts(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    []searchPreset
+		wantErr bool
+	}{
+		{
+			name:  "label and params",
+			lines: []string{"Climate: q=climate&maxAgeHours=72", "  World   news : q=world"},
+			want:  []searchPreset{{Name: "Climate", Params: "maxAgeHours=72&q=climate"}, {Name: "World news", Params: "q=world"}},
+		},
+		{name: "no colon", lines: []string{"Climate q=climate"}, wantErr: true},
+		{name: "no label", lines: []string{" : q=climate"}, wantErr: true},
+		{name: "malformed params", lines: []string{"Climate: q=%zz"}, wantErr: true},
+		{name: "search /search rejects", lines: []string{"Climate: q=climate&maxAgeHours=-1"}, wantErr: true},
+		{name: "one bad line fails them all", lines: []string{"Science: q=science", "Climate: pageSize=500&q=climate"}, wantErr: true},
+		{name: "none", lines: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseShortcuts(tt.lines)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseShortcuts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseShortcuts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultShortcutsParse(t *testing.T) {
+	got, err := parseShortcuts(defaultShortcuts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(defaultShortcuts) {
+		t.Errorf("parsed %d of %d default shortcuts", len(got), len(defaultShortcuts))
+	}
+}
+
+func TestHomepageShortcuts(t *testing.T) {
+	tests := []struct {
+		name      string
+		shortcuts []searchPreset
+		want      string
+	}{
+		{name: "shown", shortcuts: []searchPreset{{Name: "Climate", Params: "maxAgeHours=72&q=climate"}}, want: `<a class="button" href="/search?maxAgeHours=72&amp;q=climate">Climate</a>`},
+		{name: "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar(t, &shortcuts, tt.shortcuts)
+			body := get(indexHandler, "/").Body.String()
+			if got := strings.Contains(body, `class="shortcuts"`); got != (tt.want != "") {
+				t.Errorf("shortcuts nav shown = %v, want %v", got, tt.want != "")
+			}
+			if tt.want != "" && !strings.Contains(body, tt.want) {
+				t.Errorf("homepage doesn't link the shortcut %s", tt.want)
+			}
+		})
+	}
+}
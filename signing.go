@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+)
+
+// signingKey authenticates the data we hand to clients (cookies, tokens), set from -secret
+var signingKey []byte
+
+var errBadSignature = errors.New("invalid or tampered token")
+
+// setSigningKey uses secret, or a random key when it is empty, which means signed data
+// doesn't survive a restart
+func setSigningKey(secret string) {
+	if secret != "" {
+		signingKey = []byte(secret)
+		return
+	}
+	signingKey = make([]byte, 32)
+	if _, err := rand.Read(signingKey); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("no -secret set, using a random key: saved articles and other signed data reset on restart")
+}
+
+// sign returns payload and its HMAC-SHA256 as "payload.mac", both base64url encoded
+func sign(payload []byte) string {
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write(payload)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks a token made by sign and returns its payload
+func verify(token string) ([]byte, error) {
+	payloadPart, macPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errBadSignature
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(payloadPart)
+	if err != nil {
+		return nil, errBadSignature
+	}
+	got, err := enc.DecodeString(macPart)
+	if err != nil {
+		return nil, errBadSignature
+	}
+
+	mac := hmac.New(sha256.New, signingKey)
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return nil, errBadSignature
+	}
+	return payload, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	token := sign([]byte(`[{"u":"https://news.example.com/a/1"}]`))
+	payload, mac, _ := strings.Cut(token, ".")
+	tests := []struct {
+		name    string
+		token   string
+		key     string
+		wantErr bool
+	}{
+		{name: "as signed", token: token},
+		{name: "tampered payload", token: "W10." + mac, wantErr: true},
+		{name: "tampered mac", token: payload + "." + strings.Repeat("A", len(mac)), wantErr: true},
+		{name: "other key", token: token, key: "another secret", wantErr: true},
+		{name: "no mac", token: payload, wantErr: true},
+		{name: "not base64", token: "!!!." + mac, wantErr: true},
+		{name: "empty", token: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.key != "" {
+				setVar(t, &signingKey, []byte(tt.key))
+			}
+			got, err := verify(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, []byte(`[{"u":"https://news.example.com/a/1"}]`)) {
+				t.Errorf("verify() = %s, want the signed payload", got)
+			}
+		})
+	}
+}
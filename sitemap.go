@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// sitemapPages are the indexable paths, search URLs are left out on purpose
+var sitemapPages = []string{"/"}
+
+// baseURL is the public scheme and host, e.g. https://news.example.com, from -base-url.
+// When empty it is derived from each request.
+var baseURL *string
+
+// trustProxy lets X-Forwarded-Proto and X-Forwarded-Host decide the public URL, only safe behind a proxy that sets them
+var trustProxy *bool
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+}
+
+// requestBaseURL is the scheme and host clients reached us on
+func requestBaseURL(r *http.Request) string {
+	if *baseURL != "" {
+		return strings.TrimSuffix(*baseURL, "/")
+	}
+
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if *trustProxy {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if fwdHost := r.Header.Get("X-Forwarded-Host"); fwdHost != "" {
+			host = fwdHost
+		}
+	}
+	return scheme + "://" + host
+}
+
+func sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	base := requestBaseURL(r)
+	set := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, page := range sitemapPages {
+		set.URLs = append(set.URLs, sitemapURL{Loc: base + page, ChangeFreq: "hourly"})
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		log.Println(err)
+	}
+}
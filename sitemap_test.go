@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestBaseURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		baseURL    string
+		trustProxy bool
+		tls        bool
+		header     http.Header
+		want       string
+	}{
+		{name: "from the request", want: "http://news.example.com"},
+		{name: "over TLS", tls: true, want: "https://news.example.com"},
+		{name: "flag wins", baseURL: "https://public.example.com/", want: "https://public.example.com"},
+		{
+			name:   "proxy headers ignored by default",
+			header: http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.test"}},
+			want:   "http://news.example.com",
+		},
+		{
+			name:       "trusted proxy",
+			trustProxy: true,
+			header:     http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"public.example.com"}},
+			want:       "https://public.example.com",
+		},
+		{
+			name:       "odd proto ignored",
+			trustProxy: true,
+			header:     http.Header{"X-Forwarded-Proto": {"javascript"}},
+			want:       "http://news.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlag(t, &baseURL, tt.baseURL)
+			setFlag(t, &trustProxy, tt.trustProxy)
+			r := httptest.NewRequest(http.MethodGet, "http://news.example.com/sitemap.xml", nil)
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for name, values := range tt.header {
+				r.Header[name] = values
+			}
+			if got := requestBaseURL(r); got != tt.want {
+				t.Errorf("requestBaseURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSitemapHandler(t *testing.T) {
+	w := get(sitemapHandler, "http://news.example.com/sitemap.xml")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+		t.Errorf("Content-Type = %q, want application/xml", ct)
+	}
+	var set sitemapURLSet
+	if err := xml.Unmarshal(w.Body.Bytes(), &set); err != nil {
+		t.Fatalf("sitemap isn't XML: %v\n%s", err, w.Body)
+	}
+	if set.Xmlns != "http://www.sitemaps.org/schemas/sitemap/0.9" {
+		t.Errorf("xmlns = %q", set.Xmlns)
+	}
+	if len(set.URLs) != 1 || set.URLs[0].Loc != "http://news.example.com/" {
+		t.Errorf("urls = %+v, want only the homepage", set.URLs)
+	}
+	if strings.Contains(w.Body.String(), "/search") {
+		t.Error("sitemap lists search pages")
+	}
+}
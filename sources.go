@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	sourcesCacheKey = "sources"
+	// sourcesTTL is long, newsapi's source catalog changes rarely
+	sourcesTTL = 24 * time.Hour
+	// sourcesFailedKey remembers a failed catalog fetch for sourcesFailedTTL, so every page
+	// doesn't ask again while newsapi is failing
+	sourcesFailedKey = "sources|failed"
+	sourcesFailedTTL = time.Minute
+)
+
+// sourceLabels turns on the country and category labels on cards, set by -source-labels
+var sourceLabels *bool
+
+// SourceInfo is a source from newsapi's /v2/sources catalog
+type SourceInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Category string `json:"category"`
+	Language string `json:"language"`
+	Country  string `json:"country"`
+}
+
+// Sources returns newsapi's source catalog by source id, fetched at most once per sourcesTTL. A
+// failed fetch is answered from the cache for sourcesFailedTTL, and the fetch goes through the
+// breaker like the article endpoints.
+func (c *NewsClient) Sources(ctx context.Context) (map[string]SourceInfo, error) {
+	v, err, _ := c.flight.Do(sourcesCacheKey, func() (interface{}, error) {
+		var sources []SourceInfo
+		if body, ok := c.cache.Get(sourcesCacheKey); ok && json.Unmarshal(body, &sources) == nil {
+			return sources, nil
+		}
+		if body, ok := c.cache.Get(sourcesFailedKey); ok {
+			return nil, errors.New(string(body))
+		}
+		if c.breaker != nil {
+			if err := c.breaker.Allow(); err != nil {
+				return nil, err
+			}
+		}
+		sources, err := c.fetchSources(ctx)
+		if c.breaker != nil {
+			c.breaker.Record(err)
+		}
+		if err != nil {
+			if !isCanceled(err) {
+				c.cache.Set(sourcesFailedKey, []byte(err.Error()), sourcesFailedTTL)
+			}
+			return nil, err
+		}
+		if body, err := json.Marshal(sources); err == nil {
+			c.cache.Set(sourcesCacheKey, body, sourcesTTL)
+		}
+		return sources, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string]SourceInfo{}
+	for _, s := range v.([]SourceInfo) {
+		byID[s.ID] = s
+	}
+	return byID, nil
+}
+
+func (c *NewsClient) fetchSources(ctx context.Context) ([]SourceInfo, error) {
+	endpoint := c.base + "/v2/sources?" + url.Values{"apiKey": {c.key}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("newsapi: invalid request url %s", redactKey(endpoint))
+	}
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactKey(urlErr.URL)
+		}
+		c.logUpstream(endpoint, 0, start, err)
+		return nil, err
+	}
+	c.logUpstream(endpoint, resp.StatusCode, start, nil)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &NewsAPIError{}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil {
+			return nil, fmt.Errorf("newsapi: unexpected status %d", resp.StatusCode)
+		}
+		return nil, apiErr
+	}
+
+	var catalog struct {
+		Sources []SourceInfo `json:"sources"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+		return nil, err
+	}
+	return catalog.Sources, nil
+}
+
+// enrichWithSource copies the country and category of each article's source from sourcesByID.
+// Articles without a source id, or with one the catalog doesn't know, are left as they are.
+// It returns a new slice and leaves articles untouched.
+func enrichWithSource(articles []Articles, sourcesByID map[string]SourceInfo) []Articles {
+	enriched := make([]Articles, len(articles))
+	for i, a := range articles {
+		if id, ok := a.Source.ID.(string); ok {
+			if info, ok := sourcesByID[id]; ok {
+				a.Source.Country = info.Country
+				a.Source.Category = info.Category
+			}
+		}
+		enriched[i] = a
+	}
+	return enriched
+}
+
+// addSourceLabels enriches the page with the source catalog, a catalog failure only costs the labels
+func (s *Search) addSourceLabels(ctx context.Context) {
+	sources, err := newsapi.Sources(ctx)
+	if err != nil {
+		if !isCanceled(err) {
+			log.Printf("loading the source catalog: %v", err)
+		}
+		return
+	}
+	s.Results.Articles = enrichWithSource(s.Results.Articles, sources)
+}
+
+// SourceLabels are the small labels under a card's source name, e.g. GB and Technology
+func (a *Articles) SourceLabels() []string {
+	var labels []string
+	if a.Source.Country != "" {
+		labels = append(labels, strings.ToUpper(a.Source.Country))
+	}
+	if name, ok := metaName(newsapiCategories, a.Source.Category); ok {
+		labels = append(labels, name)
+	}
+	return labels
+}
+
+// maxSources is how many source ids newsapi accepts in one search
+const maxSources = 20
+
+// popularSources are the source ids offered as quick filters, set by -popular-sources
+var popularSources []string
+
+// sourcesParam reads the comma separated sources param of newsapi source ids
+func sourcesParam(params url.Values) ([]string, error) {
+	sources, err := listParam(params, "sources")
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) > maxSources {
+		return nil, fmt.Errorf("sources may list at most %d sources", maxSources)
+	}
+	return sources, nil
+}
+
+// sourceFilter is a quick filter link limiting the search to one more source
+type sourceFilter struct {
+	Name string
+	URL  string
+}
+
+// quickSources are the popular sources the search isn't limited to yet, named from the source
+// catalog. Without the catalog the ids stand in for the names.
+func (s *Search) quickSources(ctx context.Context) []sourceFilter {
+	if len(popularSources) == 0 || len(s.Sources) >= maxSources {
+		return nil
+	}
+	catalog, err := newsapi.Sources(ctx)
+	if err != nil && !isCanceled(err) {
+		log.Printf("loading the source catalog: %v", err)
+	}
+	var filters []sourceFilter
+	for _, id := range popularSources {
+		id = strings.ToLower(id)
+		if slices.Contains(s.Sources, id) {
+			continue
+		}
+		name := id
+		if info, ok := catalog[id]; ok && info.Name != "" {
+			name = info.Name
+		}
+		filters = append(filters, sourceFilter{Name: name, URL: s.addToListURL("sources", s.Sources, id)})
+	}
+	return filters
+}
+
+// RemoveSourceURL is the current search no longer limited to the source id, for its chip
+func (s *Search) RemoveSourceURL(id string) string {
+	return s.removeFromListURL("sources", s.Sources, id)
+}
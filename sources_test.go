@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testCatalog = `{"status":"ok","sources":[
+	{"id":"bbc-news","name":"BBC News","url":"https://www.bbc.co.uk/news","category":"general","language":"en","country":"gb"},
+	{"id":"techcrunch","name":"TechCrunch","url":"https://techcrunch.com","category":"technology","language":"en","country":"us"}]}`
+
+// newSourcesAPI answers /v2/sources with status and body, counting the requests
+func newSourcesAPI(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path != "/v2/sources" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestSources(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantErr  bool
+		wantHits int32
+	}{
+		{name: "fetched once", status: http.StatusOK, body: testCatalog, wantHits: 1},
+		{name: "failure remembered", status: http.StatusInternalServerError, body: "", wantErr: true, wantHits: 1},
+		{name: "newsapi error remembered", status: http.StatusBadRequest, body: `{"status":"error","code":"parameterInvalid","message":"Bad"}`, wantErr: true, wantHits: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := newSourcesAPI(t, tt.status, tt.body)
+			c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(10), time.Minute)
+			for i := range 3 {
+				sources, err := c.Sources(context.Background())
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("call %d: error = %v, wantErr %v", i, err, tt.wantErr)
+				}
+				if !tt.wantErr && (len(sources) != 2 || sources["bbc-news"].Country != "gb") {
+					t.Errorf("call %d: sources = %v", i, sources)
+				}
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("newsapi got %d requests, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestSourcesFailureExpires(t *testing.T) {
+	srv, hits := newSourcesAPI(t, http.StatusInternalServerError, "")
+	cache := newLRUCache(10)
+	c := NewNewsClient(srv.Client(), srv.URL, "test-key", cache, time.Minute)
+	c.Sources(context.Background())
+	// what sourcesFailedTTL passing looks like
+	cache.Delete(sourcesFailedKey)
+	c.Sources(context.Background())
+	if got := hits.Load(); got != 2 {
+		t.Errorf("newsapi got %d requests, want the catalog asked for again", got)
+	}
+}
+
+func TestSourcesCanceledIsNotRemembered(t *testing.T) {
+	srv, hits := newSourcesAPI(t, http.StatusOK, testCatalog)
+	c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(10), time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Sources(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled Sources = %v, want context.Canceled", err)
+	}
+	if _, err := c.Sources(context.Background()); err != nil {
+		t.Fatalf("Sources after a canceled call: %v", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("newsapi got %d requests, want 1", got)
+	}
+}
+
+func TestSourcesBreaker(t *testing.T) {
+	srv, hits := newSourcesAPI(t, http.StatusOK, testCatalog)
+	c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(10), time.Minute)
+	c.breaker = newCircuitBreaker(1, time.Minute)
+	c.breaker.Record(errors.New("connection refused"))
+
+	if _, err := c.Sources(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Sources with the breaker open = %v, want ErrCircuitOpen", err)
+	}
+	if got := hits.Load(); got != 0 {
+		t.Errorf("newsapi got %d requests with the breaker open", got)
+	}
+}
+
+func TestEnrichWithSource(t *testing.T) {
+	catalog := map[string]SourceInfo{"bbc-news": {ID: "bbc-news", Country: "gb", Category: "general"}}
+	articles := []Articles{
+		{Title: "known", Source: Source{ID: "bbc-news"}},
+		{Title: "unknown", Source: Source{ID: "other"}},
+		{Title: "no id", Source: Source{Name: "Blog"}},
+	}
+	got := enrichWithSource(articles, catalog)
+	want := [][]string{{"GB", "General"}, nil, nil}
+	for i, a := range got {
+		if labels := a.SourceLabels(); !slices.Equal(labels, want[i]) {
+			t.Errorf("%s: labels = %q, want %q", a.Title, labels, want[i])
+		}
+	}
+	if articles[0].Source.Country != "" {
+		t.Error("enrichWithSource changed its input")
+	}
+}
+
+func TestSourceLabels(t *testing.T) {
+	tests := []struct {
+		source Source
+		want   []string
+	}{
+		{source: Source{Country: "us", Category: "technology"}, want: []string{"US", "Technology"}},
+		{source: Source{Country: "de"}, want: []string{"DE"}},
+		{source: Source{Category: "nonsense"}, want: nil},
+		{source: Source{}, want: nil},
+	}
+	for _, tt := range tests {
+		a := Articles{Source: tt.source}
+		if got := a.SourceLabels(); !slices.Equal(got, tt.want) {
+			t.Errorf("SourceLabels() for %+v = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestSourcesParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "none", query: "", want: nil},
+		{name: "lowercased without duplicates", query: "sources=BBC-News,techcrunch,bbc-news", want: []string{"bbc-news", "techcrunch"}},
+		{name: "blanks dropped", query: "sources=bbc-news,,%20", want: []string{"bbc-news"}},
+		{name: "at the limit", query: "sources=" + numberedSources(maxSources), want: strings.Split(numberedSources(maxSources), ",")},
+		{name: "over the limit", query: "sources=" + numberedSources(maxSources+1), wantErr: true},
+		{name: "repeated", query: "sources=bbc-news&sources=techcrunch", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, _ := url.ParseQuery(tt.query)
+			got, err := sourcesParam(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sourcesParam(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sourcesParam(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// numberedSources is a sources param of n distinct ids
+func numberedSources(n int) string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("source-%d", i)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestQuickSources(t *testing.T) {
+	tests := []struct {
+		name     string
+		popular  []string
+		sources  []string
+		status   int
+		wantName []string
+		wantURL  []string
+	}{
+		{
+			name:     "named from the catalog",
+			popular:  []string{"bbc-news", "TechCrunch", "reuters"},
+			status:   http.StatusOK,
+			wantName: []string{"BBC News", "TechCrunch", "reuters"},
+			wantURL:  []string{"/search?q=go&sources=bbc-news", "/search?q=go&sources=techcrunch", "/search?q=go&sources=reuters"},
+		},
+		{
+			name:     "already filtered sources left out",
+			popular:  []string{"bbc-news", "techcrunch"},
+			sources:  []string{"bbc-news"},
+			status:   http.StatusOK,
+			wantName: []string{"TechCrunch"},
+			wantURL:  []string{"/search?q=go&sources=bbc-news%2Ctechcrunch"},
+		},
+		{
+			name:     "ids without the catalog",
+			popular:  []string{"bbc-news"},
+			status:   http.StatusInternalServerError,
+			wantName: []string{"bbc-news"},
+			wantURL:  []string{"/search?q=go&sources=bbc-news"},
+		},
+		{name: "none configured", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newSourcesAPI(t, tt.status, testCatalog)
+			useNewsAPI(t, srv)
+			setVar(t, &popularSources, tt.popular)
+			s := &Search{SearchKey: "go", Sources: tt.sources, PageSize: 20}
+			var names, urls []string
+			for _, f := range s.quickSources(context.Background()) {
+				names = append(names, f.Name)
+				urls = append(urls, f.URL)
+			}
+			if !slices.Equal(names, tt.wantName) {
+				t.Errorf("names = %q, want %q", names, tt.wantName)
+			}
+			if !slices.Equal(urls, tt.wantURL) {
+				t.Errorf("URLs = %q, want %q", urls, tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestRemoveSourceURL(t *testing.T) {
+	tests := []struct {
+		sources []string
+		id      string
+		want    string
+	}{
+		{sources: []string{"bbc-news", "techcrunch"}, id: "bbc-news", want: "/search?q=go&sources=techcrunch"},
+		{sources: []string{"bbc-news"}, id: "bbc-news", want: "/search?q=go"},
+	}
+	for _, tt := range tests {
+		s := &Search{SearchKey: "go", Sources: tt.sources, PageSize: 20}
+		if got := s.RemoveSourceURL(tt.id); got != tt.want {
+			t.Errorf("RemoveSourceURL(%q) with %q = %q, want %q", tt.id, tt.sources, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// statsCollector is a dependency-free set of counters for /stats, safe for concurrent use
+type statsCollector struct {
+	requests       atomic.Int64
+	searches       atomic.Int64
+	cacheHits      atomic.Int64
+	cacheMisses    atomic.Int64
+	upstreamErrors atomic.Int64
+
+	mu      sync.Mutex
+	latency latencySummary
+
+	// beacons are the events the pages report through /beacon
+	beacons beaconCounts
+
+	// cacheEntries reports the size of the in-memory result cache, nil with redis
+	cacheEntries func() int
+}
+
+// latencySummary tracks request durations without keeping every sample
+type latencySummary struct {
+	count int64
+	total time.Duration
+	max   time.Duration
+}
+
+// statsSnapshot is what /stats returns, latencies are in milliseconds
+type statsSnapshot struct {
+	Requests       int64 `json:"requests"`
+	Searches       int64 `json:"searches"`
+	CacheHits      int64 `json:"cacheHits"`
+	CacheMisses    int64 `json:"cacheMisses"`
+	UpstreamErrors int64 `json:"upstreamErrors"`
+	// CacheEntries is how many results the memory cache holds, left out with redis
+	CacheEntries  *int    `json:"cacheEntries,omitempty"`
+	LatencyMeanMs float64 `json:"latencyMeanMs"`
+	LatencyMaxMs  float64 `json:"latencyMaxMs"`
+	// Events counts beacon events by name, EventsByQuery by query hash and name
+	Events        map[string]int64            `json:"events"`
+	EventsByQuery map[string]map[string]int64 `json:"eventsByQuery"`
+}
+
+var stats = &statsCollector{}
+
+func (s *statsCollector) observe(d time.Duration) {
+	s.requests.Add(1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency.count++
+	s.latency.total += d
+	s.latency.max = max(s.latency.max, d)
+}
+
+func (s *statsCollector) Snapshot() statsSnapshot {
+	snap := statsSnapshot{
+		Requests:       s.requests.Load(),
+		Searches:       s.searches.Load(),
+		CacheHits:      s.cacheHits.Load(),
+		CacheMisses:    s.cacheMisses.Load(),
+		UpstreamErrors: s.upstreamErrors.Load(),
+	}
+	snap.Events, snap.EventsByQuery = s.beacons.snapshot()
+	if s.cacheEntries != nil {
+		n := s.cacheEntries()
+		snap.CacheEntries = &n
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latency.count > 0 {
+		snap.LatencyMeanMs = milliseconds(s.latency.total / time.Duration(s.latency.count))
+	}
+	snap.LatencyMaxMs = milliseconds(s.latency.max)
+	return snap
+}
+
+func (s *statsCollector) Reset() {
+	s.requests.Store(0)
+	s.searches.Store(0)
+	s.cacheHits.Store(0)
+	s.cacheMisses.Store(0)
+	s.upstreamErrors.Store(0)
+	s.beacons.reset()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latencySummary{}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// withStats counts every request and how long it took
+func withStats(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		stats.observe(time.Since(start))
+	})
+}
+
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(stats.Snapshot())
+}
+
+// statsResetHandler zeroes the counters, POST only
+func statsResetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	stats.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatsCollectorLatency(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		wantMean  float64
+		wantMax   float64
+	}{
+		{name: "none", durations: nil, wantMean: 0, wantMax: 0},
+		{name: "one", durations: []time.Duration{30 * time.Millisecond}, wantMean: 30, wantMax: 30},
+		{name: "several", durations: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 60 * time.Millisecond}, wantMean: 30, wantMax: 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &statsCollector{}
+			for _, d := range tt.durations {
+				s.observe(d)
+			}
+			snap := s.Snapshot()
+			if snap.Requests != int64(len(tt.durations)) {
+				t.Errorf("requests = %d, want %d", snap.Requests, len(tt.durations))
+			}
+			if snap.LatencyMeanMs != tt.wantMean || snap.LatencyMaxMs != tt.wantMax {
+				t.Errorf("latency mean/max = %v/%v, want %v/%v", snap.LatencyMeanMs, snap.LatencyMaxMs, tt.wantMean, tt.wantMax)
+			}
+			s.Reset()
+			if snap := s.Snapshot(); snap.Requests != 0 || snap.LatencyMaxMs != 0 {
+				t.Errorf("after Reset: %+v", snap)
+			}
+		})
+	}
+}
+
+func TestStatsCollectorConcurrent(t *testing.T) {
+	s := &statsCollector{}
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.observe(time.Millisecond)
+			s.searches.Add(1)
+		}()
+	}
+	wg.Wait()
+	if snap := s.Snapshot(); snap.Requests != 50 || snap.Searches != 50 {
+		t.Errorf("counted %d requests and %d searches, want 50 of each", snap.Requests, snap.Searches)
+	}
+}
+
+func TestCacheHitStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		searches   int
+		wantHits   int64
+		wantMisses int64
+	}{
+		{name: "first search misses", searches: 1, wantHits: 0, wantMisses: 1},
+		{name: "repeats hit", searches: 3, wantHits: 2, wantMisses: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats.Reset()
+			t.Cleanup(stats.Reset)
+			c := useNewsAPI(t, newFakeNewsAPI(t, 5).Server)
+			for range tt.searches {
+				if _, err := c.Everything(context.Background(), url.Values{"q": {"go"}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			snap := stats.Snapshot()
+			if snap.CacheHits != tt.wantHits || snap.CacheMisses != tt.wantMisses {
+				t.Errorf("hits/misses = %d/%d, want %d/%d", snap.CacheHits, snap.CacheMisses, tt.wantHits, tt.wantMisses)
+			}
+		})
+	}
+}
+
+func TestStatsResetHandler(t *testing.T) {
+	tests := []struct {
+		method     string
+		wantStatus int
+	}{
+		{method: http.MethodPost, wantStatus: http.StatusNoContent},
+		{method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		w := do(statsResetHandler, tt.method, "/admin/stats/reset")
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.method, w.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestStatsCacheEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries func() int
+		want    string
+	}{
+		{name: "memory cache", entries: func() int { return 7 }, want: `"cacheEntries":7`},
+		{name: "redis", entries: nil, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &statsCollector{cacheEntries: tt.entries}
+			body, err := json.Marshal(s.Snapshot())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(string(body), "cacheEntries"); got != (tt.want != "") {
+				t.Errorf("snapshot %s has cacheEntries = %v", body, got)
+			}
+			if tt.want != "" && !strings.Contains(string(body), tt.want) {
+				t.Errorf("snapshot %s lacks %s", body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// defaultSynonyms is the synonym map -synonyms uses unless -synonyms-file gives another
+var defaultSynonyms = map[string][]string{
+	"ai":               {"artificial intelligence"},
+	"ml":               {"machine learning"},
+	"ev":               {"electric vehicle"},
+	"uk":               {"united kingdom", "britain"},
+	"eu":               {"european union"},
+	"crypto":           {"cryptocurrency"},
+	"climate change":   {"global warming"},
+	"covid":            {"coronavirus", "covid-19"},
+	"stock market":     {"stocks", "wall street"},
+	"premier league":   {"epl"},
+	"semiconductors":   {"chips", "chipmakers"},
+	"renewable energy": {"renewables", "solar", "wind power"},
+}
+
+// synonyms is the map the synonym hook expands queries with, nil when -synonyms is off
+var synonyms map[string][]string
+
+// parseSynonyms reads "term: synonym, synonym" lines, as -synonyms-file holds them (through
+// readWordList, which drops blanks and comments). Terms are matched ignoring case.
+func parseSynonyms(lines []string) (map[string][]string, error) {
+	m := make(map[string][]string, len(lines))
+	for _, line := range lines {
+		term, alts, ok := strings.Cut(line, ":")
+		term = strings.ToLower(strings.Join(strings.Fields(term), " "))
+		if !ok || term == "" {
+			return nil, fmt.Errorf("synonym line %q should look like term: synonym, synonym", line)
+		}
+		for _, alt := range strings.Split(alts, ",") {
+			if alt = strings.Join(strings.Fields(alt), " "); alt != "" {
+				m[term] = append(m[term], alt)
+			}
+		}
+		if len(m[term]) == 0 {
+			return nil, fmt.Errorf("synonym line %q has no synonyms", line)
+		}
+	}
+	return m, nil
+}
+
+// expandSynonyms turns each term of q that has synonyms into an OR group, e.g. AI becomes
+// (AI OR "artificial intelligence"). Terms of several words are matched as a whole, the longest
+// first. Quoted phrases, operators, +/- prefixed words and anything already inside brackets are
+// left alone, so expanding an expanded query changes nothing.
+func expandSynonyms(q string, synonyms map[string][]string) string {
+	if len(synonyms) == 0 {
+		return q
+	}
+	longest := 1
+	for term := range synonyms {
+		longest = max(longest, len(strings.Fields(term)))
+	}
+
+	tokens := queryTokens(q)
+	var out []string
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if depth > 0 || strings.HasPrefix(t, "(") {
+			depth = max(0, depth+strings.Count(t, "(")-strings.Count(t, ")"))
+			out = append(out, t)
+			continue
+		}
+		if keptAsTyped(t) {
+			out = append(out, t)
+			continue
+		}
+
+		n, alts := matchSynonym(tokens[i:], longest, synonyms)
+		if n == 0 {
+			out = append(out, t)
+			continue
+		}
+		group := []string{quoteTerm(strings.Join(tokens[i:i+n], " "))}
+		for _, alt := range alts {
+			group = append(group, quoteTerm(alt))
+		}
+		out = append(out, "("+strings.Join(group, " OR ")+")")
+		i += n - 1
+	}
+	return strings.Join(out, " ")
+}
+
+// matchSynonym finds the longest term with synonyms that tokens start with, returning how
+// many tokens it covers (0 for none) and its synonyms
+func matchSynonym(tokens []string, longest int, synonyms map[string][]string) (int, []string) {
+	for n := min(longest, len(tokens)); n > 0; n-- {
+		words := tokens[:n]
+		if slices.ContainsFunc(words, keptAsTyped) {
+			continue
+		}
+		if alts, ok := synonyms[strings.ToLower(strings.Join(words, " "))]; ok {
+			return n, alts
+		}
+	}
+	return 0, nil
+}
+
+// keptAsTyped reports whether a token is syntax rather than a plain word: a phrase, bracket,
+// operator or +/- prefixed word, none of which get synonyms
+func keptAsTyped(t string) bool {
+	return strings.ContainsAny(t, `"()`) || queryOperators[t] || strings.HasPrefix(t, "+") || strings.HasPrefix(t, "-")
+}
+
+// quoteTerm quotes a term of several words so newsapi matches it as a phrase
+func quoteTerm(term string) string {
+	if strings.Contains(term, " ") {
+		return `"` + term + `"`
+	}
+	return term
+}
+
+// expandQuerySynonyms is the pipeline hook for expandSynonyms with the configured map
+func expandQuerySynonyms(q string) string {
+	return expandSynonyms(q, synonyms)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSynonyms(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    map[string][]string
+		wantErr string
+	}{
+		{
+			name:  "terms and synonyms",
+			lines: []string{"AI: artificial intelligence", "Climate   Change : global warming,  climate crisis "},
+			want:  map[string][]string{"ai": {"artificial intelligence"}, "climate change": {"global warming", "climate crisis"}},
+		},
+		{name: "repeated term adds up", lines: []string{"ev: electric vehicle", "ev: electric car"}, want: map[string][]string{"ev": {"electric vehicle", "electric car"}}},
+		{name: "no colon", lines: []string{"ai artificial intelligence"}, wantErr: "should look like"},
+		{name: "no term", lines: []string{": robots"}, wantErr: "should look like"},
+		{name: "no synonyms", lines: []string{"ai: , "}, wantErr: "has no synonyms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSynonyms(tt.lines)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("parseSynonyms error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseSynonyms = %v, want %v", got, tt.want)
+			}
+			for term, alts := range tt.want {
+				if !slices.Equal(got[term], alts) {
+					t.Errorf("%s: %q, want %q", term, got[term], alts)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandSynonyms(t *testing.T) {
+	tests := []struct {
+		name string
+		q    string
+		want string
+	}{
+		{name: "one word", q: "AI regulation", want: `(AI OR "artificial intelligence") regulation`},
+		{name: "several synonyms", q: "uk", want: `(uk OR "united kingdom" OR britain)`},
+		{name: "several words, longest first", q: "climate change policy", want: `("climate change" OR "global warming") policy`},
+		{name: "any case", q: "Stock Market news", want: `("Stock Market" OR stocks OR "wall street") news`},
+		{name: "quoted phrase kept", q: `"ai" chips`, want: `"ai" chips`},
+		{name: "prefixed words kept", q: "-crypto +ev", want: "-crypto +ev"},
+		{name: "operators kept", q: "ai OR ml", want: `(ai OR "artificial intelligence") OR (ml OR "machine learning")`},
+		{name: "inside brackets kept", q: "(ai news) ev", want: `(ai news) (ev OR "electric vehicle")`},
+		{name: "expanding twice changes nothing", q: `(AI OR "artificial intelligence") regulation`, want: `(AI OR "artificial intelligence") regulation`},
+		{name: "nothing to expand", q: "mars rover", want: "mars rover"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandSynonyms(tt.q, defaultSynonyms); got != tt.want {
+				t.Errorf("expandSynonyms(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandSynonymsOff(t *testing.T) {
+	if got := expandSynonyms("AI news", nil); got != "AI news" {
+		t.Errorf("expandSynonyms without a map = %q", got)
+	}
+}
+
+func TestSynonymsHookRunsLast(t *testing.T) {
+	setVar(t, &synonyms, defaultSynonyms)
+	setVar(t, &queryHooks, defaultQueryHooks(true, true))
+	s := &Search{SearchKey: "what is the latest on ai"}
+	if got, want := s.query(), `latest (ai OR "artificial intelligence")`; got != want {
+		t.Errorf("query() = %q, want %q", got, want)
+	}
+}
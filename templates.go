@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// templateStore holds a parsed page template that can be swapped while requests execute it,
+// as -dev does when the file changes
+type templateStore struct {
+	mu  sync.RWMutex
+	tpl *template.Template
+}
+
+// templateFS is where the page templates are read from, the working directory like the assets
+var templateFS fs.FS = os.DirFS(".")
+
+// templateFuncs are the functions every page template can call. A helper that works on plain
+// values rather than on one type belongs here, not in another method: add it under its
+// template name, lower camel case like the rest.
+var templateFuncs = template.FuncMap{
+	// join lists strings, e.g. {{ join .AlsoReportedBy ", " }}
+	"join": strings.Join,
+	// cleanText is newsapi text without its markup, see cleanText
+	"cleanText": cleanText,
+	// timeAgo words a time like the cards do, "" when it is over a week old or in the future
+	"timeAgo": func(t time.Time) string { return timeAgo(t, time.Now()) },
+	// imageURL routes a remote image through the /img proxy at the given size
+	"imageURL": imageProxyURL,
+}
+
+// parseTemplate parses file from templateFS with templateFuncs available to it
+func parseTemplate(file string) (*template.Template, error) {
+	return template.New(file).Funcs(templateFuncs).ParseFS(templateFS, file)
+}
+
+// newTemplateStore parses file, panicking if it doesn't parse, like template.Must. The stores
+// are set up before main runs, so a missing file, typically from starting the server outside
+// the repository, stops it with a message saying where the file was looked for instead.
+func newTemplateStore(file string) *templateStore {
+	// ParseFS reports a missing file as a pattern that matches nothing, look for it first
+	if _, err := fs.Stat(templateFS, file); errors.Is(err, fs.ErrNotExist) {
+		log.Fatal(missingTemplateError(file))
+	}
+	return &templateStore{tpl: template.Must(parseTemplate(file))}
+}
+
+// missingTemplateError explains a page template that isn't in the working directory
+func missingTemplateError(file string) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "the working directory"
+	}
+	return fmt.Errorf("template %s not found in %s: the page templates are read from the working directory, start the server from the directory that has %s and assets/", file, dir, file)
+}
+
+// Get returns the current template, handlers execute what it returns
+func (s *templateStore) Get() *template.Template {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tpl
+}
+
+// Set swaps in a new template, requests already executing the old one finish with it
+func (s *templateStore) Set(t *template.Template) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tpl = t
+}
+
+// pageTemplates are the page templates by the file they are parsed from
+var pageTemplates = map[string]*templateStore{
+	"index.html":       tpl,
+	"maintenance.html": maintenanceTpl,
+	"article.html":     articleTpl,
+	"saved.html":       savedTpl,
+	"presets.html":     presetsTpl,
+	"preview.html":     previewTpl,
+}
+
+// reloadTemplate reparses file into its store. A file that no longer parses is logged
+// and the previous template kept, so a half-saved edit doesn't break the page.
+func reloadTemplate(file string) {
+	store, ok := pageTemplates[file]
+	if !ok {
+		return
+	}
+	parsed, err := parseTemplate(file)
+	if err != nil {
+		log.Printf("reloading %s: %v", file, err)
+		return
+	}
+	store.Set(parsed)
+	log.Printf("reloaded %s", file)
+}
+
+// watchTemplates reparses the page templates in dir whenever one changes, for -dev. The
+// directory is watched rather than the files, editors often save by replacing the file.
+func watchTemplates(dir string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+					reloadTemplate(filepath.Base(event.Name))
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("watching templates: %v", err)
+			}
+		}
+	}()
+	return nil
+}
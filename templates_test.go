@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// render executes the store's current template with no data
+func render(t *testing.T, s *templateStore) string {
+	t.Helper()
+	var b strings.Builder
+	if err := s.Get().Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestReloadTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{name: "reparsed", file: "index.html", content: "new page", want: "new page"},
+		{name: "broken file keeps the old template", file: "index.html", content: "{{ if }", want: "old page"},
+		{name: "not a page template", file: "notes.html", content: "notes", want: "old page"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &templateStore{tpl: template.Must(template.New("index.html").Parse("old page"))}
+			setVar(t, &pageTemplates, map[string]*templateStore{"index.html": store})
+			setVar[fs.FS](t, &templateFS, fstest.MapFS{tt.file: {Data: []byte(tt.content)}})
+			reloadTemplate(tt.file)
+			if got := render(t, store); got != tt.want {
+				t.Errorf("index.html renders %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchTemplates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "index.html")
+	if err := os.WriteFile(path, []byte("old page"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := &templateStore{tpl: template.Must(template.New("index.html").Parse("old page"))}
+	setVar(t, &pageTemplates, map[string]*templateStore{"index.html": store})
+	setVar[fs.FS](t, &templateFS, os.DirFS(dir))
+	if err := watchTemplates(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("new page"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for render(t, store) != "new page" {
+		if time.Now().After(deadline) {
+			t.Fatal("index.html wasn't reloaded after it changed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTemplateStoreSwapWhileExecuting(t *testing.T) {
+	store := &templateStore{tpl: template.Must(template.New("page").Parse("old"))}
+	held := store.Get()
+	swap := template.Must(template.New("page").Parse("new"))
+	store.Set(swap)
+
+	// a request that got the old template finishes with it
+	var b strings.Builder
+	if err := held.Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "old" {
+		t.Errorf("held template renders %q, want old", b.String())
+	}
+	if store.Get() != swap {
+		t.Error("Get() doesn't return the swapped in template")
+	}
+}
+
+func TestTemplateStoreConcurrentUse(t *testing.T) {
+	store := &templateStore{tpl: template.Must(template.New("page").Parse("a"))}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for range 100 {
+				if i%2 == 0 {
+					store.Set(template.Must(template.New("page").Parse("b")))
+					continue
+				}
+				var b strings.Builder
+				if err := store.Get().Execute(&b, nil); err != nil {
+					t.Error(err)
+					return
+				}
+				if got := b.String(); got != "a" && got != "b" {
+					t.Errorf("rendered %q, want a or b", got)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	tests := []struct {
+		name string
+		tpl  string
+		data any
+		want string
+	}{
+		{name: "join", tpl: `{{ join . ", " }}`, data: []string{"BBC News", "Reuters"}, want: "BBC News, Reuters"},
+		{name: "join nothing", tpl: `{{ join . ", " }}`, data: []string(nil), want: ""},
+		{name: "cleanText", tpl: `{{ cleanText . }}`, data: "<p>Hello  <b>world</b></p>", want: "Hello world"},
+		{name: "timeAgo", tpl: `{{ timeAgo . }}`, data: time.Now().Add(-3 * time.Hour), want: "3 hours ago"},
+		{name: "timeAgo of an old time", tpl: `{{ timeAgo . }}`, data: time.Now().AddDate(0, -1, 0), want: ""},
+		{name: "imageURL", tpl: `{{ imageURL . 64 }}`, data: "https://images.example.com/a.jpg", want: "/img?size=64&amp;url=https%3A%2F%2Fimages.example.com%2Fa.jpg"},
+		{name: "imageURL of another host", tpl: `{{ imageURL . 64 }}`, data: "https://other.example.org/a.jpg", want: ""},
+		{name: "imageURL without an image", tpl: `{{ imageURL . 64 }}`, data: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setVar[fs.FS](t, &templateFS, fstest.MapFS{"page.html": {Data: []byte(tt.tpl)}})
+			setVar(t, &imageHosts, []string{"images.example.com"})
+			tpl, err := parseTemplate("page.html")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b strings.Builder
+			if err := tpl.Execute(&b, tt.data); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.tpl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTemplateUnknownFunc(t *testing.T) {
+	setVar[fs.FS](t, &templateFS, fstest.MapFS{"page.html": {Data: []byte(`{{ shout . }}`)}})
+	if _, err := parseTemplate("page.html"); err == nil || !strings.Contains(err.Error(), "shout") {
+		t.Errorf("parseTemplate error = %v, want one about the unknown function", err)
+	}
+}
+
+func TestPageTemplatesParse(t *testing.T) {
+	for file := range pageTemplates {
+		if _, err := parseTemplate(file); err != nil {
+			t.Errorf("parseTemplate(%s): %v", file, err)
+		}
+	}
+}
+
+func TestMissingTemplateError(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := missingTemplateError("index.html").Error()
+	for _, want := range []string{"template index.html not found in " + dir, "start the server from the directory that has index.html and assets/"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missingTemplateError = %q, lacks %q", msg, want)
+		}
+	}
+}
+
+func TestNewTemplateStore(t *testing.T) {
+	if file := os.Getenv("NEWS_ATGO_MISSING_TEMPLATE"); file != "" {
+		// the child process: newTemplateStore stops it for a missing file
+		setVar[fs.FS](t, &templateFS, fstest.MapFS{})
+		newTemplateStore(file)
+		return
+	}
+	setVar[fs.FS](t, &templateFS, fstest.MapFS{"page.html": {Data: []byte("{{ join . \"-\" }}")}})
+	var b strings.Builder
+	if err := newTemplateStore("page.html").Get().Execute(&b, []string{"a", "b"}); err != nil || b.String() != "a-b" {
+		t.Errorf("store renders %q, %v, want a-b", b.String(), err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestNewTemplateStore$")
+	cmd.Env = append(os.Environ(), "NEWS_ATGO_MISSING_TEMPLATE=gone.html")
+	out, err := cmd.CombinedOutput()
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 1 {
+		t.Fatalf("a missing template exited with %v, want status 1: %s", err, out)
+	}
+	if !strings.Contains(string(out), "template gone.html not found in") {
+		t.Errorf("a missing template logged %q, want it named", out)
+	}
+	if strings.Contains(string(out), "panic") {
+		t.Errorf("a missing template panicked: %s", out)
+	}
+}
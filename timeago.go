@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// relativeAgeLimit is how old an article may be for its date to be shown as "time ago", older
+// ones show the date itself
+const relativeAgeLimit = 7 * 24 * time.Hour
+
+// timeAgo describes how long before now t was, in the largest whole unit: "just now",
+// "5 minutes ago", "3 hours ago", "2 days ago". Beyond relativeAgeLimit, or for a t in the
+// future, it is "" and callers show the date instead. assets/timeago.js words it the same.
+func timeAgo(t, now time.Time) string {
+	age := now.Sub(t)
+	if age < 0 || age >= relativeAgeLimit {
+		return ""
+	}
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return plural(int(age/time.Minute), "minute")
+	case age < 24*time.Hour:
+		return plural(int(age/time.Hour), "hour")
+	default:
+		return plural(int(age/(24*time.Hour)), "day")
+	}
+}
+
+// TimeAgo is the card's publish date, relative while it is recent and the date after that
+func (a *Articles) TimeAgo() string {
+	if a.PublishedAt.IsZero() {
+		return a.FormatPublishedDate()
+	}
+	if ago := timeAgo(a.PublishedAt.Time, time.Now()); ago != "" {
+		return ago
+	}
+	return a.FormatPublishedDate()
+}
+
+// PublishedRFC3339 is the publish date for the time element's datetime and data-timestamp,
+// which timeago.js keeps TimeAgo fresh from; "" without a date
+func (a *Articles) PublishedRFC3339() string {
+	if a.PublishedAt.IsZero() {
+		return ""
+	}
+	return a.PublishedAt.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeAgo(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{age: 0, want: "just now"},
+		{age: 59 * time.Second, want: "just now"},
+		{age: time.Minute, want: "1 minute ago"},
+		{age: 2 * time.Minute, want: "2 minutes ago"},
+		{age: 59 * time.Minute, want: "59 minutes ago"},
+		{age: 59*time.Minute + 59*time.Second, want: "59 minutes ago"},
+		{age: time.Hour, want: "1 hour ago"},
+		{age: 61 * time.Minute, want: "1 hour ago"},
+		{age: 23*time.Hour + 59*time.Minute, want: "23 hours ago"},
+		{age: 24 * time.Hour, want: "1 day ago"},
+		{age: 6*24*time.Hour + 23*time.Hour, want: "6 days ago"},
+		{age: relativeAgeLimit, want: ""},
+		{age: -time.Minute, want: ""},
+	}
+	for _, tt := range tests {
+		if got := timeAgo(now.Add(-tt.age), now); got != tt.want {
+			t.Errorf("timeAgo(%s before now) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestArticleTimeAgo(t *testing.T) {
+	old := time.Date(2020, 3, 9, 8, 0, 0, 0, time.UTC)
+	recent := time.Now().Add(-5 * time.Minute)
+	tests := []struct {
+		name          string
+		published     time.Time
+		want          string
+		wantTimestamp string
+	}{
+		{name: "recent", published: recent, want: "5 minutes ago", wantTimestamp: recent.UTC().Format(time.RFC3339)},
+		{name: "old", published: old, want: "March 9, 2020", wantTimestamp: "2020-03-09T08:00:00Z"},
+		{name: "no date", want: "Unknown date", wantTimestamp: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Articles{PublishedAt: Timestamp{tt.published}}
+			if got := a.TimeAgo(); got != tt.want {
+				t.Errorf("TimeAgo() = %q, want %q", got, tt.want)
+			}
+			if got := a.PublishedRFC3339(); got != tt.wantTimestamp {
+				t.Errorf("PublishedRFC3339() = %q, want %q", got, tt.wantTimestamp)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// timelineLocation is the time zone the timeline view's days are counted in, set by -timezone
+var timelineLocation = time.UTC
+
+// dateGroup is one day of the timeline, Date is its midnight in the timeline's location and
+// zero for the articles without a publish date
+type dateGroup struct {
+	Date     time.Time
+	Articles []Articles
+}
+
+// Label is the heading shown above the day's articles
+func (g dateGroup) Label() string {
+	if g.Date.IsZero() {
+		return "Unknown date"
+	}
+	return g.Date.Format("Monday, January 2, 2006")
+}
+
+// groupByDate buckets articles by the day they were published in loc, newest day first and
+// articles without a date last. Within a day the articles keep their order.
+func groupByDate(articles []Articles, loc *time.Location) []dateGroup {
+	var groups []dateGroup
+	var undated []Articles
+	index := map[time.Time]int{}
+	for _, a := range articles {
+		if a.PublishedAt.IsZero() {
+			undated = append(undated, a)
+			continue
+		}
+		y, m, d := a.PublishedAt.In(loc).Date()
+		day := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		i, ok := index[day]
+		if !ok {
+			i = len(groups)
+			index[day] = i
+			groups = append(groups, dateGroup{Date: day})
+		}
+		groups[i].Articles = append(groups[i].Articles, a)
+	}
+
+	slices.SortStableFunc(groups, func(a, b dateGroup) int {
+		return b.Date.Compare(a.Date)
+	})
+	if len(undated) > 0 {
+		groups = append(groups, dateGroup{Articles: undated})
+	}
+	return groups
+}
+
+// timelineDay is a day of the timeline view with a copy of the search holding just its
+// articles, so the day renders through the usual results template
+type timelineDay struct {
+	Label  string
+	Search *Search
+}
+
+// Timeline groups the page's articles by publish day for the timeline view
+func (s *Search) Timeline() []timelineDay {
+	var days []timelineDay
+	for _, g := range groupByDate(s.Results.Articles, timelineLocation) {
+		day := *s
+		day.Results.Articles = g.Articles
+		days = append(days, timelineDay{Label: g.Label(), Search: &day})
+	}
+	return days
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGroupByDate(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(title string, published time.Time) Articles {
+		return Articles{Title: title, PublishedAt: Timestamp{published}}
+	}
+	articles := []Articles{
+		at("monday morning", time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)),
+		at("undated", time.Time{}),
+		at("tuesday late", time.Date(2026, 10, 13, 23, 30, 0, 0, time.UTC)),
+		at("monday night", time.Date(2026, 10, 12, 22, 0, 0, 0, time.UTC)),
+		at("tuesday early", time.Date(2026, 10, 13, 2, 0, 0, 0, time.UTC)),
+	}
+	tests := []struct {
+		name       string
+		loc        *time.Location
+		wantLabels []string
+		wantTitles [][]string
+	}{
+		{
+			name:       "UTC, newest day first and undated last",
+			loc:        time.UTC,
+			wantLabels: []string{"Tuesday, October 13, 2026", "Monday, October 12, 2026", "Unknown date"},
+			wantTitles: [][]string{{"tuesday late", "tuesday early"}, {"monday morning", "monday night"}, {"undated"}},
+		},
+		{
+			name:       "days counted in the location",
+			loc:        newYork,
+			wantLabels: []string{"Tuesday, October 13, 2026", "Monday, October 12, 2026", "Unknown date"},
+			wantTitles: [][]string{{"tuesday late"}, {"monday morning", "monday night", "tuesday early"}, {"undated"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := groupByDate(articles, tt.loc)
+			var labels []string
+			var got [][]string
+			for _, g := range groups {
+				labels = append(labels, g.Label())
+				got = append(got, titles(g.Articles))
+			}
+			if !slices.Equal(labels, tt.wantLabels) {
+				t.Errorf("labels = %q, want %q", labels, tt.wantLabels)
+			}
+			if !slices.EqualFunc(got, tt.wantTitles, slices.Equal) {
+				t.Errorf("articles = %q, want %q", got, tt.wantTitles)
+			}
+		})
+	}
+}
+
+func TestSearchTimeline(t *testing.T) {
+	setVar(t, &timelineLocation, time.UTC)
+	s := &Search{SearchKey: "go", Results: Results{Articles: []Articles{
+		{Title: "a", PublishedAt: Timestamp{time.Date(2026, 10, 13, 8, 0, 0, 0, time.UTC)}},
+		{Title: "b", PublishedAt: Timestamp{time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC)}},
+	}}}
+	days := s.Timeline()
+	if len(days) != 2 {
+		t.Fatalf("Timeline() has %d days, want 2", len(days))
+	}
+	for i, want := range []string{"a", "b"} {
+		if got := titles(days[i].Search.Results.Articles); !slices.Equal(got, []string{want}) {
+			t.Errorf("day %d articles = %q, want [%s]", i, got, want)
+		}
+		if days[i].Search.SearchKey != "go" {
+			t.Errorf("day %d lost the search key", i)
+		}
+	}
+	if len(s.Results.Articles) != 2 {
+		t.Errorf("Timeline() changed the page's articles to %q", titles(s.Results.Articles))
+	}
+}
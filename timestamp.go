@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// timestampLayouts are tried in order when decoding a publishedAt; NewsAPI mostly sends RFC 3339
+// but some sources come through without a zone or without the time
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02",
+}
+
+// Timestamp is a time.Time that decodes leniently: a value in none of timestampLayouts
+// becomes the zero time instead of failing the whole response
+type Timestamp struct {
+	time.Time
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	t.Time = time.Time{}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		// null, a number or anything else that isn't a string
+		return nil
+	}
+	for _, layout := range timestampLayouts {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return nil
+}
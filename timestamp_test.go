@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimestampUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want time.Time
+	}{
+		{name: "RFC 3339", json: `"2026-10-14T09:30:00Z"`, want: time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)},
+		{name: "fractional seconds", json: `"2026-10-14T09:30:00.5Z"`, want: time.Date(2026, 10, 14, 9, 30, 0, 5e8, time.UTC)},
+		{name: "no zone", json: `"2026-10-14T09:30:00"`, want: time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)},
+		{name: "space separated", json: `"2026-10-14 09:30:00"`, want: time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)},
+		{name: "RFC 1123", json: `"Wed, 14 Oct 2026 09:30:00 GMT"`, want: time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)},
+		{name: "date only", json: `"2026-10-14"`, want: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)},
+		{name: "garbage", json: `"yesterday"`, want: time.Time{}},
+		{name: "empty", json: `""`, want: time.Time{}},
+		{name: "null", json: `null`, want: time.Time{}},
+		{name: "number", json: `1760434200`, want: time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a struct {
+				PublishedAt Timestamp `json:"publishedAt"`
+			}
+			if err := json.Unmarshal([]byte(`{"publishedAt":`+tt.json+`}`), &a); err != nil {
+				t.Fatalf("decoding failed: %v", err)
+			}
+			if !a.PublishedAt.Equal(tt.want) {
+				t.Errorf("publishedAt %s = %v, want %v", tt.json, a.PublishedAt.Time, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPublishedDate(t *testing.T) {
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{at: time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC), want: "October 14, 2026"},
+		{at: time.Time{}, want: "Unknown date"},
+	}
+	for _, tt := range tests {
+		a := Articles{PublishedAt: Timestamp{tt.at}}
+		if got := a.FormatPublishedDate(); got != tt.want {
+			t.Errorf("FormatPublishedDate() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans points tracer at a recorder for the rest of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	setVar(t, &tracer, provider.Tracer("test"))
+	return rec
+}
+
+// spanAttr is the value of the attribute key on span, "" when it isn't set
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestWithTracing(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		status      int
+		traceparent string
+		wantName    string
+		wantStatus  string
+		wantError   bool
+		wantTraceID string
+	}{
+		{name: "route pattern names the span", target: "/search?q=go", status: http.StatusOK, wantName: "GET /search", wantStatus: "200"},
+		{name: "client error isn't a span error", target: "/search?q=g", status: http.StatusBadRequest, wantName: "GET /search", wantStatus: "400"},
+		{name: "server error is", target: "/search?q=go", status: http.StatusBadGateway, wantName: "GET /search", wantStatus: "502", wantError: true},
+		{name: "unrouted", target: "/nope", status: http.StatusOK, wantName: "GET", wantStatus: "404"},
+		{
+			name:        "continues the caller's trace",
+			target:      "/search?q=go",
+			status:      http.StatusOK,
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantName:    "GET /search",
+			wantStatus:  "200",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := recordSpans(t)
+			propagator := otel.GetTextMapPropagator()
+			otel.SetTextMapPropagator(propagation.TraceContext{})
+			t.Cleanup(func() { otel.SetTextMapPropagator(propagator) })
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(tt.status) })
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.traceparent != "" {
+				r.Header.Set("traceparent", tt.traceparent)
+			}
+			withTracing(mux).ServeHTTP(httptest.NewRecorder(), r)
+
+			spans := rec.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != tt.wantName {
+				t.Errorf("span name = %q, want %q", span.Name(), tt.wantName)
+			}
+			if got := spanAttr(span, "http.response.status_code"); got != tt.wantStatus {
+				t.Errorf("status attribute = %s, want %s", got, tt.wantStatus)
+			}
+			if got := span.Status().Code == codes.Error; got != tt.wantError {
+				t.Errorf("span error = %v, want %v", got, tt.wantError)
+			}
+			if tt.wantTraceID != "" && span.SpanContext().TraceID().String() != tt.wantTraceID {
+				t.Errorf("trace id = %s, want %s", span.SpanContext().TraceID(), tt.wantTraceID)
+			}
+		})
+	}
+}
+
+func TestNewsAPISpan(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantTotal string
+		wantError bool
+	}{
+		{name: "results", status: http.StatusOK, body: `{"status":"ok","totalResults":42,"articles":[]}`, wantTotal: "42"},
+		{name: "newsapi error", status: http.StatusBadRequest, body: `{"status":"error","code":"parameterInvalid","message":"Bad q"}`, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := recordSpans(t)
+			srv := newErrorNewsAPI(t, tt.status, tt.body)
+			c := NewNewsClient(srv.Client(), srv.URL, "test-key", newLRUCache(10), time.Minute)
+			c.Everything(context.Background(), url.Values{"q": {"go"}, "page": {"2"}})
+
+			var span sdktrace.ReadOnlySpan
+			for _, s := range rec.Ended() {
+				if s.Name() == "newsapi everything" {
+					span = s
+				}
+			}
+			if span == nil {
+				t.Fatal("no newsapi everything span")
+			}
+			if got := spanAttr(span, "newsapi.q"); got != "go" {
+				t.Errorf("newsapi.q = %q, want go", got)
+			}
+			if got := spanAttr(span, "url.full"); strings.Contains(got, "test-key") {
+				t.Errorf("url.full %q holds the API key", got)
+			}
+			if got := spanAttr(span, "newsapi.total_results"); got != tt.wantTotal {
+				t.Errorf("newsapi.total_results = %q, want %q", got, tt.wantTotal)
+			}
+			if got := span.Status().Code == codes.Error; got != tt.wantError {
+				t.Errorf("span error = %v, want %v", got, tt.wantError)
+			}
+		})
+	}
+}
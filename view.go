@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const viewCookie = "view"
+
+// viewModes are the layouts the results template knows, the first is the default
+var viewModes = []string{"grid", "list", "timeline"}
+
+// viewMode reads the layout preference cookie, falling back to the default for missing or unknown values
+func viewMode(r *http.Request) string {
+	c, err := r.Cookie(viewCookie)
+	if err == nil && validViewMode(c.Value) {
+		return c.Value
+	}
+	return viewModes[0]
+}
+
+func validViewMode(mode string) bool {
+	for _, m := range viewModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// variants are the alternative result layouts the variant param can pick for experiments,
+// the first is the standard layout everyone else gets
+var variants = []string{"a", "b"}
+
+// variantParam returns the requested layout variant, unknown or missing values get the standard one
+func variantParam(params url.Values) string {
+	v := params.Get("variant")
+	for _, known := range variants {
+		if v == known {
+			return v
+		}
+	}
+	return variants[0]
+}
+
+// ViewURL switches to mode and comes back to the current page of results
+func (s *Search) ViewURL(mode string) string {
+	return "/view?mode=" + mode + "&next=" + url.QueryEscape(s.PageURL(s.CurrentPage()))
+}
+
+// viewHandler stores the chosen layout in a cookie and redirects back to next
+func viewHandler(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	if !validViewMode(mode) {
+		http.Error(w, "Unknown view mode", http.StatusBadRequest)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     viewCookie,
+		Value:    mode,
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, localRedirect(r.URL.Query().Get("next")), http.StatusSeeOther)
+}
+
+// localRedirect only allows redirecting to a path on this site, anything else goes home
+func localRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
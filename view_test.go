@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestViewMode(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string
+		want   string
+	}{
+		{name: "no cookie", cookie: "", want: "grid"},
+		{name: "list", cookie: "list", want: "list"},
+		{name: "timeline", cookie: "timeline", want: "timeline"},
+		{name: "unknown", cookie: "carousel", want: "grid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: viewCookie, Value: tt.cookie})
+			}
+			if got := viewMode(r); got != tt.want {
+				t.Errorf("viewMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestViewHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		wantStatus   int
+		wantCookie   string
+		wantLocation string
+	}{
+		{name: "back to the results", query: "mode=list&next=%2Fsearch%3Fq%3Dgo", wantStatus: http.StatusSeeOther, wantCookie: "list", wantLocation: "/search?q=go"},
+		{name: "no next goes home", query: "mode=grid", wantStatus: http.StatusSeeOther, wantCookie: "grid", wantLocation: "/"},
+		{name: "other site goes home", query: "mode=list&next=https%3A%2F%2Fevil.test%2F", wantStatus: http.StatusSeeOther, wantCookie: "list", wantLocation: "/"},
+		{name: "unknown mode", query: "mode=carousel", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(viewHandler, "/view?"+tt.query)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusSeeOther {
+				return
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Name != viewCookie || cookies[0].Value != tt.wantCookie {
+				t.Errorf("cookies = %v, want view=%s", cookies, tt.wantCookie)
+			}
+		})
+	}
+}
+
+func TestLocalRedirect(t *testing.T) {
+	tests := []struct {
+		next string
+		want string
+	}{
+		{next: "/search?q=go", want: "/search?q=go"},
+		{next: "/", want: "/"},
+		{next: "", want: "/"},
+		{next: "https://evil.test/", want: "/"},
+		{next: "//evil.test/", want: "/"},
+		{next: "/\\evil.test/", want: "/"},
+		{next: "search", want: "/"},
+	}
+	for _, tt := range tests {
+		if got := localRedirect(tt.next); got != tt.want {
+			t.Errorf("localRedirect(%q) = %q, want %q", tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestVariantParam(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "", want: "a"},
+		{query: "variant=a", want: "a"},
+		{query: "variant=b", want: "b"},
+		{query: "variant=B", want: "a"},
+		{query: "variant=c", want: "a"},
+	}
+	for _, tt := range tests {
+		params, _ := url.ParseQuery(tt.query)
+		if got := variantParam(params); got != tt.want {
+			t.Errorf("variantParam(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestVariantKeptInLinks(t *testing.T) {
+	tests := []struct {
+		variant string
+		want    string
+	}{
+		{variant: "a", want: "/search?page=2&q=go"},
+		{variant: "b", want: "/search?page=2&q=go&variant=b"},
+	}
+	for _, tt := range tests {
+		s := &Search{SearchKey: "go", PageSize: 20, Variant: tt.variant}
+		if got := s.PageURL(2); got != tt.want {
+			t.Errorf("PageURL(2) with variant %s = %q, want %q", tt.variant, got, tt.want)
+		}
+	}
+}